@@ -0,0 +1,400 @@
+package devflow
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tinywasm/command"
+)
+
+// APIChange is one difference in the exported API of a module.
+type APIChange struct {
+	Symbol string // e.g. "pkg.Func", "Type.Method" (root package has no prefix)
+	Old    string // declaration at the previous tag ("" = added)
+	New    string // declaration at HEAD ("" = removed)
+}
+
+func (c APIChange) String() string {
+	switch {
+	case c.Old == "":
+		return "+ " + c.New
+	case c.New == "":
+		return "- " + c.Old
+	default:
+		return "~ " + c.Old + " → " + c.New
+	}
+}
+
+// APIDiff is the classified difference between two versions of a module's
+// exported API.
+type APIDiff struct {
+	From         string // previous tag
+	Incompatible []APIChange
+	Compatible   []APIChange
+}
+
+// IsCompatible reports whether consumers of From keep compiling.
+func (d APIDiff) IsCompatible() bool {
+	return len(d.Incompatible) == 0
+}
+
+// Summary is the one-line form used in the gopush summary.
+func (d APIDiff) Summary() string {
+	if d.IsCompatible() {
+		return fmt.Sprintf("✅ API compatible with %s (+%d)", d.From, len(d.Compatible))
+	}
+	var syms []string
+	for _, c := range d.Incompatible {
+		syms = append(syms, c.Symbol)
+	}
+	return fmt.Sprintf("❌ API incompatible with %s: %s", d.From, strings.Join(syms, ", "))
+}
+
+// String renders the full diff, one change per line.
+func (d APIDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "API changes since %s:\n", d.From)
+	for _, c := range d.Incompatible {
+		b.WriteString("  " + c.String() + "\n")
+	}
+	for _, c := range d.Compatible {
+		b.WriteString("  " + c.String() + "\n")
+	}
+	return b.String()
+}
+
+// SetSkipAPICheck disables the API compatibility gate of Push.
+func (g *Go) SetSkipAPICheck(skip bool) {
+	g.skipAPICheck = skip
+}
+
+// DiffAPI compares the exported API of the module at HEAD (the working tree)
+// with the one at fromTag, type-checked in a temporary git worktree.
+func (g *Go) DiffAPI(fromTag string) (APIDiff, error) {
+	modulePath, err := g.GetModulePath()
+	if err != nil {
+		return APIDiff{}, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gopush-api-*")
+	if err != nil {
+		return APIDiff{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	worktree := filepath.Join(tmpDir, "old")
+	if _, err := command.RunInDir(g.rootDir, "git", "worktree", "add", "--detach", worktree, fromTag); err != nil {
+		return APIDiff{}, fmt.Errorf("could not check out %s: %w", fromTag, err)
+	}
+	defer command.RunInDir(g.rootDir, "git", "worktree", "remove", "--force", worktree)
+
+	oldAPI, err := moduleAPI(worktree, modulePath)
+	if err != nil {
+		return APIDiff{}, err
+	}
+	newAPI, err := moduleAPI(g.rootDir, modulePath)
+	if err != nil {
+		return APIDiff{}, err
+	}
+
+	diff := APIDiff{From: fromTag}
+	for _, sym := range sortedKeys(oldAPI) {
+		newDecl, ok := newAPI[sym]
+		switch {
+		case !ok:
+			diff.Incompatible = append(diff.Incompatible, APIChange{Symbol: sym, Old: oldAPI[sym]})
+		case newDecl != oldAPI[sym]:
+			diff.Incompatible = append(diff.Incompatible, APIChange{Symbol: sym, Old: oldAPI[sym], New: newDecl})
+		}
+	}
+	for _, sym := range sortedKeys(newAPI) {
+		if _, ok := oldAPI[sym]; !ok {
+			diff.Compatible = append(diff.Compatible, APIChange{Symbol: sym, New: newAPI[sym]})
+		}
+	}
+	return diff, nil
+}
+
+// checkAPICompatibility is the pre-tag gate of Push: an incompatible API diff
+// needs a new major version (a minor one on v0), and v2+ needs the matching
+// /vN module path. Without a latest tag in the repository there is nothing to
+// compare with; once the tag resolves, a check that cannot run fails closed.
+func (g *Go) checkAPICompatibility(latest, next string) (APIDiff, error) {
+	if g.skipAPICheck || latest == "" || next == "" || !semverTagRe.MatchString(latest) {
+		return APIDiff{}, nil
+	}
+	if _, err := command.RunInDir(g.rootDir, "git", "rev-parse", "-q", "--verify", latest+"^{commit}"); err != nil {
+		g.log("API check skipped:", latest, "is not in the repository")
+		return APIDiff{}, nil
+	}
+	diff, err := g.DiffAPI(latest)
+	if err != nil {
+		return APIDiff{}, fmt.Errorf("API check against %s failed: %w (or use --skip-apicheck)", latest, err)
+	}
+	if diff.IsCompatible() {
+		return diff, nil
+	}
+	g.consoleOutput(diff.String())
+
+	oldMajor, oldMinor := semverMajorMinor(latest)
+	newMajor, newMinor := semverMajorMinor(next)
+	switch {
+	case oldMajor == 0 && newMajor == 0 && newMinor > oldMinor:
+		return diff, nil
+	case newMajor > oldMajor:
+		if newMajor >= 2 {
+			suffix := fmt.Sprintf("/v%d", newMajor)
+			modulePath, _ := g.GetModulePath()
+			if !strings.HasSuffix(modulePath, suffix) {
+				return diff, fmt.Errorf("%s requires module path with %s suffix (got %s)", next, suffix, modulePath)
+			}
+		}
+		return diff, nil
+	}
+	need := "a new major version"
+	if oldMajor == 0 {
+		need = "at least a minor version"
+	}
+	return diff, fmt.Errorf("%s: %s is not enough for incompatible API changes, %s is required (or use --skip-apicheck)", diff.Summary(), next, need)
+}
+
+// semverMajorMinor returns the major and minor numbers of vX.Y.Z[-pre].
+func semverMajorMinor(v string) (int, int) {
+	var major, minor int
+	fmt.Sscanf(strings.TrimPrefix(v, "v"), "%d.%d", &major, &minor)
+	return major, minor
+}
+
+// moduleAPI returns the exported API of every public package of the module
+// rooted at dir, keyed by symbol. internal/, testdata/, main packages and
+// nested modules are not part of the API.
+func moduleAPI(dir, modulePath string) (map[string]string, error) {
+	api := make(map[string]string)
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != dir {
+			if name == "testdata" || name == "vendor" || name == "internal" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		bp, err := build.Default.ImportDir(path, 0)
+		if err != nil || bp.Name == "main" || len(bp.GoFiles) == 0 {
+			return nil
+		}
+
+		var files []*ast.File
+		for _, f := range bp.GoFiles {
+			file, err := parser.ParseFile(fset, filepath.Join(path, f), nil, 0)
+			if err != nil {
+				return fmt.Errorf("parse %s: %w", f, err)
+			}
+			files = append(files, file)
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		importPath := modulePath
+		prefix := ""
+		if rel != "." {
+			importPath = modulePath + "/" + filepath.ToSlash(rel)
+			prefix = filepath.ToSlash(rel) + "."
+		}
+
+		// A package that does not type-check would compare invalid types and
+		// hide real changes, so it fails the check instead.
+		conf := types.Config{Importer: imp}
+		pkg, err := conf.Check(importPath, fset, files, nil)
+		if err != nil {
+			return fmt.Errorf("type-check %s: %w", importPath, err)
+		}
+		collectPackageAPI(api, pkg, modulePath, prefix)
+		return nil
+	})
+	return api, err
+}
+
+// collectPackageAPI adds the exported objects of pkg to api. Struct fields and
+// methods are separate symbols, so additions stay compatible while removals
+// and signature changes do not. Interfaces are one symbol: adding a method
+// breaks implementers. Parameter and result names are left out: renaming them
+// does not break callers.
+func collectPackageAPI(api map[string]string, pkg *types.Package, modulePath, prefix string) {
+	qual := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		if p.Path() == modulePath {
+			return p.Name()
+		}
+		if rest, ok := strings.CutPrefix(p.Path(), modulePath+"/"); ok {
+			return rest
+		}
+		return p.Path()
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		tn, isType := obj.(*types.TypeName)
+		if !isType {
+			api[prefix+name] = objectString(obj, qual)
+			continue
+		}
+
+		switch u := tn.Type().Underlying().(type) {
+		case *types.Struct:
+			api[prefix+name] = "type " + name + " struct"
+			for i := 0; i < u.NumFields(); i++ {
+				if f := u.Field(i); f.Exported() {
+					api[prefix+name+"."+f.Name()] = "field " + name + "." + f.Name() + " " + typeString(f.Type(), qual)
+				}
+			}
+		default:
+			api[prefix+name] = "type " + name + " " + typeString(u, qual)
+		}
+
+		if _, isIface := tn.Type().Underlying().(*types.Interface); isIface || tn.IsAlias() {
+			continue
+		}
+		mset := types.NewMethodSet(types.NewPointer(tn.Type()))
+		for i := 0; i < mset.Len(); i++ {
+			m := mset.At(i).Obj()
+			if !m.Exported() || m.Pkg() != pkg {
+				continue
+			}
+			api[prefix+name+"."+m.Name()] = objectString(m, qual)
+		}
+	}
+}
+
+// objectString is types.ObjectString without parameter and result names.
+func objectString(obj types.Object, qual types.Qualifier) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		recv := ""
+		if r := sig.Recv(); r != nil {
+			recv = "(" + typeString(r.Type(), qual) + ") "
+		}
+		return "func " + recv + obj.Name() + signatureString(sig, qual)
+	case *types.Var:
+		return "var " + obj.Name() + " " + typeString(obj.Type(), qual)
+	}
+	return types.ObjectString(obj, qual)
+}
+
+// typeString is types.TypeString without the parameter and result names of
+// the function types it contains.
+func typeString(t types.Type, qual types.Qualifier) string {
+	switch t := t.(type) {
+	case *types.Signature:
+		return "func" + signatureString(t, qual)
+	case *types.Pointer:
+		return "*" + typeString(t.Elem(), qual)
+	case *types.Slice:
+		return "[]" + typeString(t.Elem(), qual)
+	case *types.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), typeString(t.Elem(), qual))
+	case *types.Map:
+		return "map[" + typeString(t.Key(), qual) + "]" + typeString(t.Elem(), qual)
+	case *types.Chan:
+		prefix := "chan "
+		switch t.Dir() {
+		case types.SendOnly:
+			prefix = "chan<- "
+		case types.RecvOnly:
+			prefix = "<-chan "
+		}
+		return prefix + typeString(t.Elem(), qual)
+	case *types.Struct:
+		var fields []string
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if f.Embedded() {
+				fields = append(fields, typeString(f.Type(), qual))
+			} else {
+				fields = append(fields, f.Name()+" "+typeString(f.Type(), qual))
+			}
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}"
+	case *types.Interface:
+		var elems []string
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			elems = append(elems, typeString(t.EmbeddedType(i), qual))
+		}
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			m := t.ExplicitMethod(i)
+			elems = append(elems, m.Name()+signatureString(m.Type().(*types.Signature), qual))
+		}
+		return "interface{" + strings.Join(elems, "; ") + "}"
+	}
+	return types.TypeString(t, qual)
+}
+
+// signatureString renders the type parameters, parameters and results of sig
+// with types only, e.g. "[T any](string, ...int) (T, error)".
+func signatureString(sig *types.Signature, qual types.Qualifier) string {
+	var b strings.Builder
+	if tparams := sig.TypeParams(); tparams.Len() > 0 {
+		var list []string
+		for i := 0; i < tparams.Len(); i++ {
+			tp := tparams.At(i)
+			list = append(list, tp.Obj().Name()+" "+typeString(tp.Constraint(), qual))
+		}
+		b.WriteString("[" + strings.Join(list, ", ") + "]")
+	}
+
+	var params []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		t := sig.Params().At(i).Type()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			params = append(params, "..."+typeString(t.(*types.Slice).Elem(), qual))
+			continue
+		}
+		params = append(params, typeString(t, qual))
+	}
+	b.WriteString("(" + strings.Join(params, ", ") + ")")
+
+	switch results := sig.Results(); results.Len() {
+	case 0:
+	case 1:
+		b.WriteString(" " + typeString(results.At(0).Type(), qual))
+	default:
+		var list []string
+		for i := 0; i < results.Len(); i++ {
+			list = append(list, typeString(results.At(i).Type(), qual))
+		}
+		b.WriteString(" (" + strings.Join(list, ", ") + ")")
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
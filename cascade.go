@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"golang.org/x/mod/modfile"
 )
 
 const MaxCascadeDepth = 10
@@ -206,6 +208,15 @@ func topologicalSort(nodes []CascadeNode) ([]CascadeNode, error) {
 
 // RunCascade executes the topological cascade
 func (g *Go) RunCascade(rootModule, rootVersion, rootCause, searchPath string) CascadeReport {
+	report := g.runCascade(rootModule, map[string]string{rootModule: rootVersion}, nil, rootCause, searchPath)
+	g.printCascadeReport(report)
+	return report
+}
+
+// runCascade runs the cascade over the dependents of rootModule, starting
+// from the versions already published (rootModule's included). The modules
+// in done were handled before and are left out.
+func (g *Go) runCascade(rootModule string, published map[string]string, done map[string]bool, rootCause, searchPath string) CascadeReport {
 	nodes, err := g.BuildDependentGraph(rootModule, searchPath)
 	if err != nil {
 		return CascadeReport{Entries: []CascadeEntry{{ModulePath: rootModule, Status: CascadeStatusFailed, Detail: err.Error()}}}
@@ -214,7 +225,9 @@ func (g *Go) RunCascade(rootModule, rootVersion, rootCause, searchPath string) C
 	report := CascadeReport{}
 	// publishedVersions tracks what version each module published in this wave
 	publishedVersions := make(map[string]string)
-	publishedVersions[rootModule] = rootVersion
+	for m, v := range published {
+		publishedVersions[m] = v
+	}
 
 	// Use default processor if none set
 	processor := cascadeProcessFn
@@ -223,6 +236,9 @@ func (g *Go) RunCascade(rootModule, rootVersion, rootCause, searchPath string) C
 	}
 
 	for _, node := range nodes {
+		if done[node.ModulePath] {
+			continue
+		}
 		// Collect bumps available for this node
 		var bumps []gitmod.DepBump
 		for _, dep := range node.DependsOn {
@@ -254,8 +270,6 @@ func (g *Go) RunCascade(rootModule, rootVersion, rootCause, searchPath string) C
 			Detail:     detail,
 		})
 	}
-
-	g.printCascadeReport(report)
 	return report
 }
 
@@ -283,9 +297,25 @@ func (g *Go) printCascadeReport(report CascadeReport) {
 	g.consoleOutput("--------------------------------------------------")
 }
 
-// findAllModules finds all go.mod files in searchPath
+// findAllModules finds all go.mod files in searchPath.
+// When a go.work is active its use entries are the module universe and
+// searchPath is not walked: only the entries inside searchPath are kept.
 func (g *Go) findAllModules(searchPath string) (map[string]string, error) {
 	modules := make(map[string]string)
+	if uses := g.workspaceModulesIn(searchPath); uses != nil {
+		absRoot, _ := filepath.Abs(g.rootDir)
+		for _, dir := range uses {
+			if dir == absRoot {
+				continue
+			}
+			goHandler, _ := NewGo(nil)
+			goHandler.SetRootDir(dir)
+			if modPath, err := goHandler.GetModulePath(); err == nil {
+				modules[dir] = modPath
+			}
+		}
+		return modules, nil
+	}
 	err := filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip errors
@@ -346,3 +376,29 @@ func (g *Go) getModuleDependencies(dir string) ([]string, error) {
 	}
 	return deps, nil
 }
+
+// Requirement is a single require directive of a go.mod file.
+type Requirement struct {
+	ModulePath string
+	Version    string
+}
+
+// getModuleRequirements returns the require directives of dir/go.mod together
+// with the required versions, in file order.
+func (g *Go) getModuleRequirements(dir string) ([]Requirement, error) {
+	path := filepath.Join(dir, "go.mod")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]Requirement, 0, len(f.Require))
+	for _, r := range f.Require {
+		reqs = append(reqs, Requirement{ModulePath: r.Mod.Path, Version: r.Mod.Version})
+	}
+	return reqs, nil
+}
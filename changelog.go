@@ -0,0 +1,243 @@
+package devflow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// ChangelogFile is the changelog gopush keeps at the repository root.
+const ChangelogFile = "CHANGELOG.md"
+
+// Changelog is the categorized list of changes of one release.
+type Changelog struct {
+	Version  string
+	Date     time.Time
+	Breaking []string
+	Features []string
+	Fixes    []string
+	Deps     []string // dependency bumps (BuildDepsCommitMessage commits)
+	Other    []string
+}
+
+// BuildChangelog categorizes commit messages (newest first) into a Changelog.
+func BuildChangelog(version string, date time.Time, messages []string) Changelog {
+	c := Changelog{Version: version, Date: date}
+	for _, msg := range messages {
+		msg = strings.TrimSpace(msg)
+		if msg == "" {
+			continue
+		}
+		header, body, _ := strings.Cut(msg, "\n")
+		header = strings.TrimSpace(header)
+
+		if strings.HasPrefix(header, gitmod.DepsCommitPrefix) {
+			entry := strings.TrimPrefix(header, gitmod.DepsCommitPrefix)
+			for _, line := range strings.Split(body, "\n") {
+				if cause, ok := strings.CutPrefix(strings.TrimSpace(line), gitmod.CauseLinePrefix); ok {
+					entry += " — " + cause
+				}
+			}
+			c.Deps = append(c.Deps, entry)
+			continue
+		}
+
+		entry := changelogEntry(header)
+		if CommitBumpLevel(msg) == BumpMajor {
+			for _, line := range strings.Split(body, "\n") {
+				for _, footer := range []string{"BREAKING CHANGE:", "BREAKING-CHANGE:"} {
+					if note, ok := strings.CutPrefix(line, footer); ok {
+						entry += " — " + strings.TrimSpace(note)
+					}
+				}
+			}
+			c.Breaking = append(c.Breaking, entry)
+			continue
+		}
+
+		m := conventionalHeaderRe.FindStringSubmatch(header)
+		switch {
+		case m != nil && strings.EqualFold(m[1], "feat"):
+			c.Features = append(c.Features, entry)
+		case m != nil && strings.EqualFold(m[1], "fix"):
+			c.Fixes = append(c.Fixes, entry)
+		default:
+			c.Other = append(c.Other, entry)
+		}
+	}
+	return c
+}
+
+// changelogEntry turns "feat(api): add X" into "**api:** add X".
+func changelogEntry(header string) string {
+	m := conventionalHeaderRe.FindStringSubmatch(header)
+	if m == nil {
+		return header
+	}
+	subject := strings.TrimSpace(header[len(m[0]):])
+	if scope := strings.Trim(m[2], "()"); scope != "" {
+		return "**" + scope + ":** " + subject
+	}
+	return subject
+}
+
+// Markdown renders the release section, starting with "## <version> (<date>)".
+func (c Changelog) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s (%s)\n", c.Version, c.Date.Format("2006-01-02"))
+	sections := []struct {
+		title   string
+		entries []string
+	}{
+		{"⚠ Breaking changes", c.Breaking},
+		{"Features", c.Features},
+		{"Fixes", c.Fixes},
+		{"Dependencies", c.Deps},
+		{"Other changes", c.Other},
+	}
+	empty := true
+	for _, s := range sections {
+		if len(s.entries) == 0 {
+			continue
+		}
+		empty = false
+		fmt.Fprintf(&b, "\n### %s\n\n", s.title)
+		for _, e := range s.entries {
+			b.WriteString("- " + e + "\n")
+		}
+	}
+	if empty {
+		b.WriteString("\nNo changes.\n")
+	}
+	return b.String()
+}
+
+// commitMessagesBetween returns the full messages of from..to, newest first.
+// from == "" means the whole history up to to.
+func (g *Go) commitMessagesBetween(from, to string) ([]string, error) {
+	logRange := to
+	if from != "" {
+		logRange = from + ".." + to
+	}
+	out, err := command.RunInDir(g.rootDir, "git", "log", "--format=%B%x1e", logRange)
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	for _, m := range strings.Split(out, "\x1e") {
+		if m = strings.TrimSpace(m); m != "" {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// SetSkipChangelog stops Push from prepending the release section to
+// CHANGELOG.md.
+func (g *Go) SetSkipChangelog(skip bool) {
+	g.skipChangelog = skip
+}
+
+// writeChangelog prepends the section for version (commits since previous
+// plus the message about to be committed) to CHANGELOG.md, so it lands in the
+// release commit. A section for version left by a failed push is replaced.
+func (g *Go) writeChangelog(previous, version, message string) error {
+	messages := []string{message}
+	if history, err := g.commitMessagesBetween(previous, "HEAD"); err == nil {
+		messages = append(messages, history...)
+	}
+	section := BuildChangelog(version, time.Now(), messages).Markdown()
+
+	path := filepath.Join(g.rootDir, ChangelogFile)
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	const title = "# Changelog\n"
+	rest := strings.TrimPrefix(string(existing), title)
+	rest = removeChangelogSection(rest, version)
+	content := title + "\n" + section
+	if rest = strings.TrimLeft(rest, "\n"); rest != "" {
+		content += "\n" + rest
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// ReleaseNotes returns the changelog section of tag: from CHANGELOG.md when
+// present, otherwise built from the commits since the previous release tag.
+// Returns "" when neither source is available.
+func (g *Go) ReleaseNotes(tag string) string {
+	if data, err := os.ReadFile(filepath.Join(g.rootDir, ChangelogFile)); err == nil {
+		if section := changelogSection(string(data), tag); section != "" {
+			return section
+		}
+	}
+
+	out, err := command.RunInDir(g.rootDir, "git", "tag", "--list", "v*")
+	if err != nil {
+		return ""
+	}
+	previous := ""
+	for _, t := range strings.Fields(out) {
+		if semverTagRe.MatchString(t) && gitmod.CompareVersions(t, tag) < 0 && (previous == "" || gitmod.CompareVersions(t, previous) > 0) {
+			previous = t
+		}
+	}
+	messages, err := g.commitMessagesBetween(previous, tag)
+	if err != nil || len(messages) == 0 {
+		return ""
+	}
+	return BuildChangelog(tag, time.Now(), messages).Markdown()
+}
+
+// changelogSection extracts the "## <tag> ..." section of a changelog.
+func changelogSection(changelog, tag string) string {
+	var b strings.Builder
+	in := false
+	for _, line := range strings.SplitAfter(changelog, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			if in {
+				break
+			}
+			fields := strings.Fields(line)
+			in = len(fields) > 1 && fields[1] == tag
+		}
+		if in {
+			b.WriteString(line)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// removeChangelogSection returns changelog without its "## <tag> ..." section.
+func removeChangelogSection(changelog, tag string) string {
+	var b strings.Builder
+	in := false
+	for _, line := range strings.SplitAfter(changelog, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			fields := strings.Fields(line)
+			in = len(fields) > 1 && fields[1] == tag
+		}
+		if !in {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// releaseCause is the root-cause line carried by dependent bump commits: the
+// upstream change plus a link to its release notes when hosted on GitHub.
+func releaseCause(modulePath, tag, message string) string {
+	header, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	cause := fmt.Sprintf("%s (%s@%s", header, modulePath, tag)
+	if parts := strings.Split(modulePath, "/"); len(parts) >= 3 && parts[0] == "github.com" {
+		cause += fmt.Sprintf(", https://github.com/%s/%s/releases/tag/%s", parts[1], parts[2], tag)
+	}
+	return cause + ")"
+}
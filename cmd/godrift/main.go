@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tinywasm/devflow"
+)
+
+func main() {
+	fs := flag.NewFlagSet("godrift", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `godrift - Report version drift across the local modules of a workspace
+
+Usage:
+    godrift [searchPath]          Scan searchPath (default: "..")
+    godrift -max 3                Exit 1 only when more than 3 issues are found
+    godrift -h                    Show this help
+
+Reports, for every module found under searchPath:
+    behind    requirement on a sibling module older than the sibling's latest tag
+    replace   lingering local replace directive
+    dirty     uncommitted changes in the working tree
+`)
+	}
+
+	maxFlag := fs.Int("max", 0, "Maximum drift issues tolerated before exiting with status 1")
+	helpFlag := fs.Bool("h", false, "Show help")
+	fs.BoolVar(helpFlag, "help", false, "Show help")
+
+	fs.Parse(os.Args[1:])
+
+	if *helpFlag {
+		fs.Usage()
+		os.Exit(0)
+	}
+
+	searchPath := ".."
+	if fs.NArg() > 0 {
+		searchPath = fs.Arg(0)
+	}
+
+	goHandler, err := devflow.NewGo(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	report, err := goHandler.WorkspaceDrift(searchPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(report.Table())
+
+	if report.Exceeds(*maxFlag) {
+		os.Exit(1)
+	}
+}
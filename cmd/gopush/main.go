@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	gitmod "github.com/tinywasm/git"
 	"os"
+	"strings"
 
 	"github.com/tinywasm/devflow"
 	keyring "github.com/tinywasm/keyring/auto"
@@ -15,17 +17,33 @@ func main() {
 
 Usage:
     gopush 'commit message' [tag]
+    gopush --rollback ['reason']
 
 Arguments:
     message    Commit message (required)
-    tag        Tag name (optional, auto-generated if not provided)
+    tag        Tag name (optional, derived from the commits since the latest tag)
 
 Examples:
     gopush 'feat: new feature'
     gopush 'fix: bug' 'v1.2.3'
 
 Flags:
-    --no-cascade   Publish this module only; do not update dependent modules
+    --no-cascade         Publish this module only; do not update dependent modules
+    --major              Move the module to the next /vN path, tag vN.0.0 and
+                         migrate the imports of dependent modules
+    --no-changelog       Do not prepend the release section to CHANGELOG.md
+    --skip-apicheck      Do not block incompatible API changes on a non-major tag
+    --skip-generate      Do not check that go generate leaves the tree unchanged
+    --tag-submodules     Version every nested module with prefixed tags
+                         (cmd/x/v0.3.1), not only those already tagged
+    --pre=<label>        Publish a pre-release, e.g. --pre=rc → v1.3.0-rc.1
+    --remote-dependents  Also open dependency-bump PRs on remote dependents
+                         (index configured in .devflow/dependents.json)
+    --sign               Require signed commits and tags (key from
+                         .devflow/signing.json or git config)
+    --rollback           Undo the last push (tag, commit and dependent bumps)
+                         after confirming the plan; the optional argument is
+                         the retraction rationale
 
 `)
 	}
@@ -33,12 +51,39 @@ Flags:
 	// Pre-process flags to keep positional args consistent
 	var skipRace bool
 	var noCascade bool
+	var remoteDependents bool
+	var preRelease string
+	var skipAPICheck bool
+	var skipGenerate bool
+	var tagSubmodules bool
+	var noChangelog bool
+	var major bool
+	var sign bool
+	var rollback bool
 	filteredArgs := []string{os.Args[0]}
 	for _, arg := range os.Args[1:] {
 		if arg == "--skip-race" || arg == "-R" {
 			skipRace = true
 		} else if arg == "--no-cascade" {
 			noCascade = true
+		} else if arg == "--major" {
+			major = true
+		} else if arg == "--no-changelog" {
+			noChangelog = true
+		} else if arg == "--skip-apicheck" {
+			skipAPICheck = true
+		} else if arg == "--skip-generate" {
+			skipGenerate = true
+		} else if arg == "--tag-submodules" {
+			tagSubmodules = true
+		} else if strings.HasPrefix(arg, "--pre=") {
+			preRelease = strings.TrimPrefix(arg, "--pre=")
+		} else if arg == "--remote-dependents" {
+			remoteDependents = true
+		} else if arg == "--sign" {
+			sign = true
+		} else if arg == "--rollback" {
+			rollback = true
 		} else {
 			filteredArgs = append(filteredArgs, arg)
 		}
@@ -46,6 +91,11 @@ Flags:
 
 	message, tag, isHelp, _ := devflow.ParseCLIArgs(filteredArgs)
 
+	if rollback && !isHelp {
+		runRollback(message)
+		return
+	}
+
 	if isHelp || (len(filteredArgs) == 1 && !devflow.IsEnvironmentValid(".env")) {
 		usage()
 		os.Exit(0)
@@ -78,6 +128,53 @@ Flags:
 		os.Exit(1)
 	}
 
+	goHandler.SetPreRelease(preRelease)
+	goHandler.SetSkipAPICheck(skipAPICheck)
+	goHandler.SetSkipGenerateCheck(skipGenerate)
+	goHandler.SetTagSubmodules(tagSubmodules)
+	goHandler.SetSkipChangelog(noChangelog)
+
+	signing, err := devflow.LoadSigningConfig(".")
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if sign {
+		if signing == nil {
+			signing = &devflow.SigningConfig{}
+		}
+		signing.Required = true
+	}
+	goHandler.SetSigning(signing)
+	goHandler.SetSecretStore(kr) // proxy upload token
+
+	if remoteDependents {
+		gh, err := gitmod.NewGitHub(func(args ...any) { fmt.Println(args...) }, kr)
+		if err != nil {
+			fmt.Println("GitHub error:", err)
+			os.Exit(1)
+		}
+		idx, err := devflow.LoadDependentsIndex(".", gh.SecretRunner)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		goHandler.SetRemoteDependents(idx, gh)
+	}
+
+	if major {
+		if tag != "" {
+			fmt.Println("Error: --major picks the tag itself (vN.0.0)")
+			os.Exit(1)
+		}
+		mig, err := goHandler.MigrateMajor()
+		if err != nil {
+			fmt.Println("Major migration failed:", err)
+			os.Exit(1)
+		}
+		tag = mig.Tag
+	}
+
 	// Run Push with parsed options
 	summary, err := goHandler.Push(message, tag, false, skipRace, noCascade, false, false, false, "..")
 	if err != nil {
@@ -87,3 +184,36 @@ Flags:
 
 	fmt.Println(summary.Summary)
 }
+
+// runRollback prints the plan undoing the last push and runs it once confirmed.
+func runRollback(rationale string) {
+	git, err := gitmod.NewGit()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	goHandler, err := devflow.NewGo(git)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	plan, err := goHandler.PlanRollback()
+	if err != nil {
+		fmt.Println("Rollback failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println(plan)
+	fmt.Print("\nProceed? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		fmt.Println("Rollback cancelled")
+		return
+	}
+
+	if _, err := goHandler.Rollback(plan, rationale); err != nil {
+		fmt.Println("Rollback failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Rolled back ✅")
+}
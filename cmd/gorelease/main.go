@@ -4,6 +4,7 @@ import (
 	"fmt"
 	gitmod "github.com/tinywasm/git"
 	"os"
+	"strings"
 
 	"github.com/tinywasm/devflow"
 	keyring "github.com/tinywasm/keyring/auto"
@@ -15,10 +16,28 @@ func main() {
 
 Usage:
     gorelease [tag]
+    gorelease --check
+    gorelease --verify-reproducible [tag]
+    gorelease --local <dir> [tag]
+    gorelease keygen
+    gorelease verify <dir> [--key <release.pub>]
 
 Arguments:
     tag        Tag name (optional, uses latest tag if not provided)
 
+Commands:
+    keygen     Create the release signing key: private key in the devflow keyring,
+               public key in .devflow/release.pub
+    verify     Check downloaded assets in <dir> against checksums.txt and its
+               signature (key: --key, else .devflow/release.pub)
+
+Flags:
+    --check                 Validate .devflow/release.json and print the build matrix
+    --verify-reproducible   Build the release assets twice and compare their digests
+    --local <dir>           Write the release assets, binaries and manifest.json to <dir>
+                            instead of publishing (no GitHub access)
+    --tinygo                Build the "wasm" packages with TinyGo instead of Go
+
 Publication:
     If the current repository is PRIVATE, gorelease automatically attempts to
     publish the release to a PUBLIC repository named after the current directory.
@@ -26,17 +45,65 @@ Publication:
 
 Features:
     - Automatic target resolution (private source -> public distribution).
+    - Reproducible .tar.gz/.zip archives with LICENSE and README (.devflow/release.json).
+    - SPDX SBOM of each binary from its embedded build info and the module graph.
+    - install.sh asset: installs the platform's archive after checking checksums.txt.
     - SHA256 checksums generation and upload.
-    - Version injection (main.Version) and binary optimization (-s -w -trimpath).
+    - OCI images of cmd/ services ("image"): <cmd>.oci.tar asset and registry push.
+    - Homebrew formula and Scoop manifest commits to a tap/bucket ("homebrew", "scoop").
+    - Size-optimized WASM builds ("wasm"): .wasm, .wasm.gz/.br and wasm_exec.js,
+      optional wasm-opt pass, and a size report in the release notes.
+    - Local releases (--local) to inspect and smoke-run artifacts offline.
+    - checksums.txt.sig: minisign signature with the release key ("sign": true).
+    - SLSA provenance (provenance.intoto.json) of the builds behind the checksums.
+    - Version injection (main.Version) and reproducible builds (-trimpath, -buildvcs,
+      SOURCE_DATE_EPOCH or the tag's commit time as timestamp).
+    - Targets, ldflags, build tags and cgo per command (.devflow/release.json).
 
 Examples:
     gorelease
     gorelease v1.2.3
+    gorelease --verify-reproducible v1.2.3
+    gorelease --local ./dist v0.0.0-test
+    gorelease --tinygo v1.2.3
 
 `)
 	}
 
-	tag, isHelp := devflow.ParseReleaseArgs(os.Args)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			runVerify(os.Args[2:], usage)
+			return
+		case "keygen":
+			runKeygen()
+			return
+		}
+	}
+
+	check, verify, tinygo := false, false, false
+	localDir := ""
+	filteredArgs := []string{os.Args[0]}
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--check":
+			check = true
+		case arg == "--verify-reproducible":
+			verify = true
+		case arg == "--tinygo":
+			tinygo = true
+		case arg == "--local" && i+1 < len(args):
+			i++
+			localDir = args[i]
+		case strings.HasPrefix(arg, "--local="):
+			localDir = strings.TrimPrefix(arg, "--local=")
+		default:
+			filteredArgs = append(filteredArgs, arg)
+		}
+	}
+
+	tag, isHelp := devflow.ParseReleaseArgs(filteredArgs)
 
 	if isHelp {
 		usage()
@@ -49,6 +116,58 @@ Examples:
 		os.Exit(1)
 	}
 
+	if check {
+		goHandler, err := devflow.NewGo(git)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		matrix, err := goHandler.CheckRelease()
+		if err != nil {
+			fmt.Println("Invalid release config:", err)
+			os.Exit(1)
+		}
+		fmt.Println(matrix)
+		return
+	}
+
+	if verify {
+		goHandler, err := devflow.NewGo(git)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		goHandler.UseTinygo(tinygo)
+		report, err := goHandler.VerifyReproducible(tag)
+		if report != "" {
+			fmt.Println(report)
+		}
+		if err != nil {
+			fmt.Println("Verification failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if localDir != "" {
+		goHandler, err := devflow.NewGo(git)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		goHandler.UseTinygo(tinygo)
+		goHandler.SetLog(func(args ...any) { fmt.Println(args...) })
+		goHandler.SetConsoleOutput(func(s string) { fmt.Println(s) })
+		if kr, err := keyring.NewKeyring("devflow"); err == nil {
+			goHandler.SetSecretStore(kr)
+		}
+		if _, err := goHandler.ReleaseLocal(tag, localDir); err != nil {
+			fmt.Println("Local release failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	auth := gitmod.NewGitHubOAuth()
 	kr, err := keyring.NewKeyring("devflow")
 	if err != nil {
@@ -64,10 +183,19 @@ Examples:
 		os.Exit(1)
 	}
 
+	goHandler.UseTinygo(tinygo)
 	log := func(args ...any) { fmt.Println(args...) }
 	goHandler.SetLog(log)
 	goHandler.SetConsoleOutput(func(s string) { fmt.Println(s) })
 
+	signing, err := devflow.LoadSigningConfig(".")
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	goHandler.SetSigning(signing)
+	goHandler.SetSecretStore(kr)
+
 	gh, err := gitmod.NewGitHub(log, kr)
 	if err != nil {
 		fmt.Println("GitHub error:", err)
@@ -79,3 +207,58 @@ Examples:
 		os.Exit(1)
 	}
 }
+
+// runVerify checks a directory of downloaded release assets.
+func runVerify(args []string, usage func()) {
+	var dir, key string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--key" && i+1 < len(args):
+			i++
+			data, err := os.ReadFile(args[i])
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			key = string(data)
+		case dir == "":
+			dir = args[i]
+		default:
+			usage()
+			os.Exit(1)
+		}
+	}
+	if dir == "" {
+		usage()
+		os.Exit(1)
+	}
+	if key == "" {
+		key = devflow.ReleasePublicKey(".")
+	}
+
+	report, err := devflow.VerifyRelease(dir, key)
+	if report != "" {
+		fmt.Println(report)
+	}
+	if err != nil {
+		fmt.Println("Verification failed:", err)
+		os.Exit(1)
+	}
+}
+
+// runKeygen creates the release signing key of the current repository.
+func runKeygen() {
+	kr, err := keyring.NewKeyring("devflow")
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	key, err := devflow.GenerateReleaseKeyFor(".", kr)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Release key %s saved in the devflow keyring\n", key.KeyID())
+	fmt.Printf("Public key written to %s/%s, commit it:\n%s", devflow.DevflowConfigDir, devflow.ReleasePubKeyFile, key.PublicKey())
+	fmt.Println(`Set "sign": true in .devflow/release.json to sign checksums.txt.`)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tinywasm/devflow"
+	gitmod "github.com/tinywasm/git"
+	keyring "github.com/tinywasm/keyring/auto"
+)
+
+func main() {
+	fs := flag.NewFlagSet("goretract", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `goretract - Retract bad releases and publish the fix
+
+Usage:
+    goretract -m 'rationale' <version>          Retract a single version
+    goretract -m 'rationale' <low> <high>       Retract the range [low, high]
+    goretract -h                                Show this help
+
+Adds a retract directive (with the rationale as comment) to go.mod and
+publishes a new patch release through the gopush workflow. Local dependents
+still requiring a retracted version are then bumped past it.
+
+Flags:
+    -m string       Rationale shown to users by 'go get' (required)
+    -no-cascade     Do not bump dependents
+
+Examples:
+    goretract -m 'Published with a broken build tag.' v1.4.2
+    goretract -m 'Data race in Pool.' v1.3.0 v1.3.4
+`)
+	}
+
+	rationale := fs.String("m", "", "Rationale for the retraction")
+	noCascade := fs.Bool("no-cascade", false, "Do not bump dependents")
+	helpFlag := fs.Bool("h", false, "Show help")
+	fs.BoolVar(helpFlag, "help", false, "Show help")
+
+	fs.Parse(os.Args[1:])
+
+	if *helpFlag || fs.NArg() < 1 || fs.NArg() > 2 || *rationale == "" {
+		fs.Usage()
+		os.Exit(0)
+	}
+
+	low, high := fs.Arg(0), fs.Arg(0)
+	if fs.NArg() == 2 {
+		high = fs.Arg(1)
+	}
+
+	git, err := gitmod.NewGit()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	auth := gitmod.NewGitHubOAuth()
+	kr, err := keyring.NewKeyring("devflow")
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	auth.SetStore(kr)
+	git.SetAuthRetrier(auth)
+
+	goHandler, err := devflow.NewGo(git)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	summary, err := goHandler.Retract(low, high, *rationale, !*noCascade, "..")
+	if err != nil {
+		fmt.Println("Retract failed:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(summary.Summary)
+}
@@ -0,0 +1,51 @@
+package devflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DevflowConfigDir is the per-repository directory holding devflow's optional
+// JSON configuration files (e.g. .devflow/dependents.json).
+const DevflowConfigDir = ".devflow"
+
+// readDevflowConfig decodes rootDir/.devflow/<name> into v.
+// It returns false (and no error) when the file does not exist: every devflow
+// config file is optional.
+func readDevflowConfig(rootDir, name string, v any) (bool, error) {
+	path := filepath.Join(rootDir, DevflowConfigDir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid %s: %w", filepath.Join(DevflowConfigDir, name), err)
+	}
+	return true, nil
+}
+
+// loadPublishConfig reads the signing and proxy configuration of the module
+// when SetSigning or SetProxy did not provide one, so every way of publishing
+// (gopush, goretract, rollback, the MCP tools) signs and resolves the same.
+func (g *Go) loadPublishConfig() error {
+	if g.signing == nil {
+		cfg, err := LoadSigningConfig(g.rootDir)
+		if err != nil {
+			return err
+		}
+		g.signing = cfg
+	}
+	if g.proxy == nil {
+		cfg, err := LoadProxyConfig(g.rootDir)
+		if err != nil {
+			return err
+		}
+		g.proxy = cfg
+	}
+	return nil
+}
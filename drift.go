@@ -0,0 +1,175 @@
+package devflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// DriftDep is a requirement on a sibling module that lags behind the
+// sibling's latest tag.
+type DriftDep struct {
+	ModulePath string
+	Required   string
+	Latest     string
+}
+
+// ModuleDrift describes what is stale in a single workspace module.
+type ModuleDrift struct {
+	Dir        string
+	ModulePath string
+	Behind     []DriftDep     // sibling requirements older than the sibling's latest tag
+	Replaces   []ReplaceEntry // lingering local replace directives
+	Dirty      bool           // uncommitted changes in the working tree
+}
+
+// Issues returns the number of drift findings for the module.
+func (m ModuleDrift) Issues() int {
+	n := len(m.Behind) + len(m.Replaces)
+	if m.Dirty {
+		n++
+	}
+	return n
+}
+
+// DriftReport is the workspace-wide version drift view produced by WorkspaceDrift.
+type DriftReport struct {
+	Modules []ModuleDrift
+}
+
+// Issues returns the total number of drift findings across all modules.
+func (r DriftReport) Issues() int {
+	n := 0
+	for _, m := range r.Modules {
+		n += m.Issues()
+	}
+	return n
+}
+
+// Exceeds reports whether the total drift is above threshold.
+func (r DriftReport) Exceeds(threshold int) bool {
+	return r.Issues() > threshold
+}
+
+// WorkspaceDrift scans every module under searchPath (including the current
+// one) and reports sibling requirements behind the sibling's latest tag,
+// lingering local replace directives and uncommitted changes.
+func (g *Go) WorkspaceDrift(searchPath string) (DriftReport, error) {
+	if searchPath == "" {
+		searchPath = ".."
+	}
+
+	modules, err := g.findAllModules(searchPath)
+	if err != nil {
+		return DriftReport{}, err
+	}
+	if modPath, err := g.GetModulePath(); err == nil {
+		modules[g.rootDir] = modPath
+	}
+
+	// Latest tag and dirty state per module; a module that is not a git repo
+	// (or has no tags) simply yields no "behind" findings.
+	latest := make(map[string]string)
+	dirty := make(map[string]bool)
+	for dir, modPath := range modules {
+		git, err := gitmod.NewGit()
+		if err != nil {
+			return DriftReport{}, fmt.Errorf("git init failed: %w", err)
+		}
+		git.SetRootDir(dir)
+		if tag := g.latestModuleTag(dir, modPath); tag != "" {
+			latest[modPath] = tag
+		}
+		if pending, err := git.HasPendingChanges(); err == nil {
+			dirty[dir] = pending
+		}
+	}
+
+	var report DriftReport
+	for dir, modPath := range modules {
+		entry := ModuleDrift{Dir: dir, ModulePath: modPath, Dirty: dirty[dir]}
+
+		reqs, err := g.getModuleRequirements(dir)
+		if err != nil {
+			continue // Skip broken modules
+		}
+		for _, req := range reqs {
+			tag, ok := latest[req.ModulePath]
+			if !ok {
+				continue // not a sibling, or sibling has no tags
+			}
+			if gitmod.CompareVersions(req.Version, tag) < 0 {
+				entry.Behind = append(entry.Behind, DriftDep{ModulePath: req.ModulePath, Required: req.Version, Latest: tag})
+			}
+		}
+
+		gomod := NewGoModHandler()
+		gomod.SetRootDir(dir)
+		if replaces, err := gomod.GetReplacePaths(); err == nil {
+			for _, r := range replaces {
+				// A self-reference ("=> ./") is how subpackages pull in their
+				// parent; it is not drift.
+				if abs, _ := filepath.Abs(dir); r.LocalPath == abs {
+					continue
+				}
+				entry.Replaces = append(entry.Replaces, r)
+			}
+		}
+
+		report.Modules = append(report.Modules, entry)
+	}
+
+	sort.Slice(report.Modules, func(i, j int) bool {
+		return report.Modules[i].ModulePath < report.Modules[j].ModulePath
+	})
+	return report, nil
+}
+
+// latestModuleTag returns the latest release of the module in dir. A module
+// in a subdirectory of its repository is released with the directory as tag
+// prefix (sub/vX.Y.Z), so only those tags count; the prefix is stripped.
+func (g *Go) latestModuleTag(dir, modulePath string) string {
+	top, err := command.RunInDir(dir, "git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return ""
+	}
+	top = evalPath(top)
+	rel, err := filepath.Rel(top, evalPath(dir))
+	if err != nil {
+		return ""
+	}
+	prefix := ""
+	if rel != "." {
+		prefix = submoduleTagPrefix(filepath.ToSlash(rel), modulePath)
+	}
+	return g.latestPrefixedTag(top, prefix)
+}
+
+// Table renders the report with one line per finding, in the same layout as
+// the cascade report. Modules without findings get a single ✅ line.
+func (r DriftReport) Table() string {
+	var b strings.Builder
+	b.WriteString("--------------------------------------------------\n")
+	for _, m := range r.Modules {
+		if m.Issues() == 0 {
+			fmt.Fprintf(&b, "✅ %-30s %-10s\n", m.ModulePath, "ok")
+			continue
+		}
+		for _, d := range m.Behind {
+			fmt.Fprintf(&b, "⚠ %-30s %-10s %s %s → %s\n", m.ModulePath, "behind", d.ModulePath, d.Required, d.Latest)
+		}
+		for _, rep := range m.Replaces {
+			fmt.Fprintf(&b, "⚠ %-30s %-10s %s => %s\n", m.ModulePath, "replace", rep.ModulePath, rep.LocalPath)
+		}
+		if m.Dirty {
+			fmt.Fprintf(&b, "⚠ %-30s %-10s %s\n", m.ModulePath, "dirty", "uncommitted changes")
+		}
+	}
+	b.WriteString("--------------------------------------------------\n")
+	fmt.Fprintf(&b, "%d modules, %d drift issues", len(r.Modules), r.Issues())
+	return b.String()
+}
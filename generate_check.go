@@ -0,0 +1,211 @@
+package devflow
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tinywasm/command"
+)
+
+// SetSkipGenerateCheck disables the go generate gate of Push.
+func (g *Go) SetSkipGenerateCheck(skip bool) {
+	g.skipGenerateCheck = skip
+}
+
+// StaleGenerated runs `go generate ./...` on a scratch copy of the module and
+// returns the files it changed or created: generated files that are out of
+// date in the tree about to be committed (tracked files plus untracked ones
+// not ignored). The working tree itself is never touched. Modules without
+// //go:generate directives are not checked.
+func (g *Go) StaleGenerated() ([]string, error) {
+	return staleGenerated(g.rootDir, g.goEnv())
+}
+
+// staleGenerated is StaleGenerated for dir, running go generate with env.
+func staleGenerated(dir string, env []string) ([]string, error) {
+	if !hasGenerateDirectives(dir) {
+		return nil, nil
+	}
+
+	prefix, err := command.RunInDir(dir, "git", "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+	files, err := command.RunInDir(dir, "git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("could not list files: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gopush-generate-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	worktree := filepath.Join(tmpDir, "tree")
+	if _, err := command.RunInDir(dir, "git", "worktree", "add", "--detach", worktree, "HEAD"); err != nil {
+		return nil, fmt.Errorf("could not create scratch worktree: %w", err)
+	}
+	defer command.RunInDir(dir, "git", "worktree", "remove", "--force", worktree)
+
+	// Mirror the working tree (uncommitted edits included) and stage it, so
+	// whatever go generate changes shows up as unstaged.
+	scratch := filepath.Join(worktree, filepath.FromSlash(prefix))
+	for _, name := range strings.Split(files, "\x00") {
+		if name == "" {
+			continue
+		}
+		if err := mirrorFile(filepath.Join(dir, name), filepath.Join(scratch, name)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := command.RunInDir(scratch, "git", "add", "-A", "."); err != nil {
+		return nil, fmt.Errorf("could not stage scratch tree: %w", err)
+	}
+
+	if _, err := runInDirModuleMode(scratch, env, "go", "generate", "./..."); err != nil {
+		return nil, fmt.Errorf("go generate failed: %w", err)
+	}
+
+	// Not command.RunInDir: trimming would eat the leading status column.
+	cmd := command.Exec("git", "status", "--porcelain", "-z", "--untracked-files=all", ".")
+	cmd.Dir = scratch
+	status, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not diff generated files: %w", err)
+	}
+	return parseUnstaged(string(status), prefix), nil
+}
+
+// hasGenerateDirectives reports whether a .go file of the module (nested
+// modules, vendor and hidden directories excluded, like ./...) holds a
+// //go:generate directive.
+func hasGenerateDirectives(dir string) bool {
+	found := false
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if found {
+			return filepath.SkipAll
+		}
+		if info.IsDir() {
+			if path == dir {
+				return nil
+			}
+			name := info.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "//go:generate ") {
+				found = true
+				return filepath.SkipAll
+			}
+		}
+		return nil
+	})
+	return found
+}
+
+// mirrorFile makes dst a copy of src; a deleted src deletes dst.
+func mirrorFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() { // submodule
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	os.Remove(dst)
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// parseUnstaged returns the paths of `git status --porcelain -z` with
+// unstaged or untracked changes, relative to prefix (the module directory
+// within the repository).
+func parseUnstaged(status, prefix string) []string {
+	var paths []string
+	entries := strings.Split(status, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		x, y, path := entry[0], entry[1], entry[3:]
+		if x == 'R' || x == 'C' {
+			i++ // the source path follows
+		}
+		if y == ' ' && x != '?' {
+			continue
+		}
+		paths = append(paths, strings.TrimPrefix(path, prefix))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// checkGenerated is the go generate gate of Push.
+func (g *Go) checkGenerated() error {
+	if g.skipGenerateCheck {
+		return nil
+	}
+	stale, err := g.StaleGenerated()
+	if err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("generated files are stale, run go generate ./... and commit: %s", strings.Join(stale, ", "))
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ module github.com/tinywasm/devflow
 go 1.25.2
 
 require (
+	github.com/andybalholm/brotli v1.2.6
 	github.com/tinywasm/command v0.0.2
 	github.com/tinywasm/git v0.0.5
 	github.com/tinywasm/gorun v0.0.24
@@ -10,6 +11,7 @@ require (
 	github.com/tinywasm/markdown v0.0.2
 	github.com/tinywasm/mcp v0.2.4
 	github.com/tinywasm/model v0.1.4
+	golang.org/x/mod v0.40.0
 	golang.org/x/term v0.45.0
 )
 
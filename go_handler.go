@@ -9,14 +9,16 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	gitmod "github.com/tinywasm/git"
 	"github.com/tinywasm/gorun"
 )
 
-// CrossTarget represents a compilation target platform
-type CrossTarget struct{ GOOS, GOARCH string }
+// CrossTarget represents a compilation target platform. Variant is the
+// GOARCH sub-architecture, e.g. "7" for GOARM=7 or "v3" for GOAMD64=v3.
+type CrossTarget struct{ GOOS, GOARCH, Variant string }
 
 // Go handler for Go operations
 type Go struct {
@@ -30,6 +32,23 @@ type Go struct {
 	crossCompileFn        func(tmpDir string, cmds []string, targets []CrossTarget, repoDir string) ([]string, error)
 	extraPublishObjectors []gitmod.PublishObjector
 	useTinygo             bool
+	preRelease            string // "" = final releases; "rc", "beta", ... (see SetPreRelease)
+	skipAPICheck          bool
+	skipGenerateCheck     bool
+	tagSubmodules         bool // version never-tagged submodules too (see SetTagSubmodules)
+	skipChangelog         bool
+	migratedFrom          string            // module path before MigrateMajor ("" = no migration)
+	majorOriginals        map[string][]byte // files edited by MigrateMajor, until released
+	majorHead             string            // HEAD when MigrateMajor ran
+	remoteIndex           DependentsIndex   // nil = remote-dependents mode off
+	github                *gitmod.GitHub
+	hooks                 HooksConfig    // in-process hooks (see AddHook)
+	signing               *SigningConfig // nil = unsigned commits and tags
+	signingActive         bool           // git commands run with the signing config (gitEnv)
+	proxy                 *ProxyConfig   // nil = the go environment as is
+	secrets               SecretStore    // devflow keyring: release signing key
+	pushedDependents      []PushedModule // dependents committed by the running cascade
+	pushedMu              sync.Mutex
 }
 
 // GoVersion reads the Go version from the go.mod file in the current directory.
@@ -73,6 +92,7 @@ const (
 	CascadeStatusDepsOnly  = "deps only"
 	CascadeStatusSkipped   = "skipped"
 	CascadeStatusFailed    = "failed"
+	CascadeStatusPROpened  = "pr opened" // remote dependent: bump proposed as a pull request
 )
 
 // NewGo creates a new Go handler and verifies Go installation
@@ -166,6 +186,11 @@ const ErrPushBlockedActiveCodejob = "gopush blocked: active codejob session (COD
 //	skipTag: If true, skips tag generation and pushes without tags
 //	searchPath: Path to search for dependent modules (default: "..")
 func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skipBackup, skipTag, skipVerify bool, searchPath string) (gitmod.PushResult, error) {
+	// A push failing before the release commit undoes MigrateMajor
+	if g.majorOriginals != nil {
+		defer g.restoreMajorMigration()
+	}
+
 	// Validate message
 	if err := gitmod.ValidateCommitMessage(message); err != nil {
 		return gitmod.PushResult{}, err
@@ -191,15 +216,37 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 		return gitmod.PushResult{Summary: "Nothing to push"}, nil
 	}
 
+	// Hooks: a broken .devflow/hooks.json must fail before anything runs
+	if _, err := LoadHooks(g.rootDir); err != nil {
+		return gitmod.PushResult{}, err
+	}
+	if err := g.loadPublishConfig(); err != nil {
+		return gitmod.PushResult{}, err
+	}
+	hookCtx := HookContext{Message: message}
+
+	// Signing: refuse to publish unsigned when it is required
+	if g.signing != nil && !g.signingActive {
+		restore, err := g.enableSigning()
+		if err != nil {
+			return gitmod.PushResult{}, err
+		}
+		defer restore()
+	}
+
 	// UNIVERSAL: If not a Go project, skip Go-specific steps
 	if !g.ModExists() {
 		var res gitmod.PushResult
 		var err error
+		hookSummary, err := g.runHooks(HookPreTag, hookCtx)
+		if err != nil {
+			return gitmod.PushResult{}, err
+		}
+		committed := true
 		if skipTag {
-			if err := g.git.Add(); err != nil {
-				return gitmod.PushResult{}, fmt.Errorf("git add failed: %w", err)
+			if committed, err = g.commit(message); err != nil {
+				return gitmod.PushResult{}, err
 			}
-			committed, _ := g.git.Commit(message)
 			pulled, pushErr := g.git.PushWithoutTags()
 			err = pushErr
 			res.Summary = "Pushed ✅"
@@ -210,7 +257,17 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 				res.Summary = "No changes to commit"
 			}
 		} else {
-			res, err = g.git.Push(message, tag)
+			res, err = g.pushRelease(message, tag)
+		}
+		if err == nil && committed {
+			g.newPushRecord("", res.Tag, nil)
+		}
+		if err == nil {
+			hookCtx.Tag = res.Tag
+			hookSummary = append(hookSummary, g.runPostHooks(HookPostPush, hookCtx)...)
+			if len(hookSummary) > 0 {
+				res.Summary += ", " + strings.Join(hookSummary, ", ")
+			}
 		}
 
 		if !skipBackup && err == nil {
@@ -221,6 +278,15 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 		return res, err
 	}
 
+	modulePath, _ := g.GetModulePath()
+	hookCtx.ModulePath = modulePath
+
+	hookSummary, err := g.runHooks(HookPreVerify, hookCtx)
+	if err != nil {
+		return gitmod.PushResult{}, err
+	}
+	summary = append(summary, hookSummary...)
+
 	// 1. Verify go.mod (skip when dispatching to an agent that will fix the repo)
 	if !skipVerify {
 		if err := g.Verify(); err != nil {
@@ -239,17 +305,29 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 		summary = append(summary, "Tests skipped")
 	}
 
+	// 2b. Generated files, checked before anything edits the tree
+	if err := g.checkGenerated(); err != nil {
+		return gitmod.PushResult{}, err
+	}
+
+	hookSummary, err = g.runHooks(HookPostTest, hookCtx)
+	if err != nil {
+		return gitmod.PushResult{}, err
+	}
+	summary = append(summary, hookSummary...)
+
 	// 3. Prepare internal submodules and execute git push workflow
 	var pushResult gitmod.PushResult
-	var err error
-
-	modulePath, _ := g.GetModulePath()
+	var record *PushRecord
 
 	if skipTag {
-		if err := g.git.Add(); err != nil {
-			return gitmod.PushResult{}, fmt.Errorf("git add failed: %w", err)
+		hookSummary, err := g.runHooks(HookPreTag, hookCtx)
+		if err != nil {
+			return gitmod.PushResult{}, err
 		}
-		committed, commitErr := g.git.Commit(message)
+		summary = append(summary, hookSummary...)
+
+		committed, commitErr := g.commit(message)
 		if commitErr != nil {
 			return gitmod.PushResult{}, fmt.Errorf("git commit failed: %w", commitErr)
 		}
@@ -263,9 +341,26 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 		}
 		if !committed {
 			pushResult.Summary = "No changes to commit"
+		} else {
+			record = g.newPushRecord(modulePath, "", nil)
 		}
 	} else {
-		// Hoist tag computation so we can sync internal submodules BEFORE commit
+		// Hoist tag computation so we can sync internal submodules BEFORE commit.
+		// Without an explicit tag the version follows the Conventional Commits
+		// since the latest release.
+		latest, planned := "", false
+		if tag == "" {
+			plan, err := g.PlanNextVersion(message)
+			if err != nil {
+				return gitmod.PushResult{}, err
+			}
+			if plan.Next != "" {
+				tag = plan.Next
+				latest, planned = plan.Previous, true
+				summary = append(summary, plan.Explain())
+			}
+		}
+
 		nextTag := tag
 		if nextTag == "" && g.git != nil {
 			var err error
@@ -275,12 +370,61 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 			}
 		}
 
+		// The previous release (changelog base, API gate) comes from the same
+		// tags as the version plan: the local ones and origin's.
+		if !planned {
+			latest, _, _ = g.latestRelease()
+		}
+
+		// Dependents the cascade would refuse stop the push before the release
+		if !skipDependents && modulePath != "" {
+			cascadeFrom := modulePath
+			if g.migratedFrom != "" {
+				cascadeFrom = g.migratedFrom
+			}
+			if err := g.checkDependentVersions(cascadeFrom, searchPath); err != nil {
+				return gitmod.PushResult{}, err
+			}
+		}
+
+		// API gate: incompatible changes need a major (v0: minor) bump
+		if latest != "" && nextTag != "" {
+			diff, err := g.checkAPICompatibility(latest, nextTag)
+			if err != nil {
+				return gitmod.PushResult{}, err
+			}
+			if diff.From != "" {
+				summary = append(summary, diff.Summary())
+			}
+		}
+
 		if nextTag != "" && modulePath != "" {
 			if err := g.syncInternalSubmodules(modulePath, nextTag); err != nil {
 				g.log("Warning: failed to sync internal submodules:", err)
 			}
 		}
 
+		// Independently versioned submodules that changed get prefixed tags
+		subReleases, err := g.prepareSubmoduleReleases(message, modulePath, nextTag)
+		if err != nil {
+			return gitmod.PushResult{}, err
+		}
+
+		// pre-tag hooks may regenerate files: they go into the release commit
+		hookCtx.Tag = nextTag
+		hookSummary, err := g.runHooks(HookPreTag, hookCtx)
+		if err != nil {
+			return gitmod.PushResult{}, err
+		}
+		summary = append(summary, hookSummary...)
+
+		// Changelog section goes into the release commit, once the gates passed
+		if nextTag != "" && !g.skipChangelog {
+			if err := g.writeChangelog(latest, nextTag, message); err != nil {
+				return gitmod.PushResult{}, fmt.Errorf("could not update %s: %w", ChangelogFile, err)
+			}
+		}
+
 		// Phase 2: Append shortstat to commit message
 		if g.git != nil {
 			if stat, err := g.git.DiffShortStat(); err == nil && stat != "" {
@@ -288,10 +432,28 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 			}
 		}
 
-		pushResult, err = g.git.Push(message, tag)
+		pushResult, err = g.pushRelease(message, tag)
 		if err != nil {
 			return gitmod.PushResult{}, fmt.Errorf("push workflow failed: %w", err)
 		}
+		g.majorOriginals = nil // released
+		var subTags []PushedModule
+		if len(subReleases) > 0 {
+			tags, err := g.pushSubmoduleTags(subReleases)
+			if err != nil {
+				tags = fmt.Sprintf("Warning: %v", err)
+			} else {
+				for _, r := range subReleases {
+					commit, _ := command.RunInDir(g.rootDir, "git", "rev-list", "-n", "1", r.Tag())
+					subTags = append(subTags, PushedModule{Dir: r.Dir, ModulePath: r.ModulePath, Commit: commit, Tag: r.Tag()})
+				}
+			}
+			pushResult.Summary += ", " + tags
+		}
+		if upload := g.uploadRelease(modulePath, pushResult.Tag, subReleases); upload != "" {
+			pushResult.Summary += ", " + upload
+		}
+		record = g.newPushRecord(modulePath, pushResult.Tag, subTags)
 	}
 	summary = append(summary, pushResult.Summary)
 
@@ -305,6 +467,9 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 		}
 	}
 
+	hookCtx.Tag = createdTag
+	summary = append(summary, g.runPostHooks(HookPostPush, hookCtx)...)
+
 	// 5. Get module name
 	modulePath, err = g.GetModulePath()
 	if err != nil {
@@ -314,9 +479,45 @@ func (g *Go) Push(message, tag string, skipTests, skipRace, skipDependents, skip
 
 	// 6. Update dependent modules (only if we have a valid tag)
 	if !skipDependents && createdTag != "" {
-		if err := g.UpdateDependents(modulePath, createdTag, searchPath); err != nil {
+		rootCause := releaseCause(modulePath, createdTag, message)
+		var local []string
+		if g.migratedFrom != "" {
+			// Dependents still import the old path: rewrite them instead of bumping
+			var failed []string
+			for _, e := range g.MigrateDependents(g.migratedFrom, modulePath, createdTag, rootCause, searchPath).Entries {
+				if e.Status == CascadeStatusFailed {
+					failed = append(failed, e.ModulePath)
+				}
+			}
+			if len(failed) > 0 {
+				summary = append(summary, fmt.Sprintf("Warning: dependents not migrated: %s", strings.Join(failed, ", ")))
+			}
+		} else if local, err = g.updateDependents(modulePath, createdTag, rootCause, searchPath); err != nil {
 			summary = append(summary, fmt.Sprintf("Warning: failed to scan dependents: %v", err))
 		}
+		// 6b. Remote dependents: propose the bump as pull requests, except to
+		// the ones just updated locally
+		if g.remoteIndex != nil {
+			entries, err := g.bumpRemoteDependents(modulePath, createdTag, rootCause, local)
+			if err != nil {
+				summary = append(summary, fmt.Sprintf("Warning: remote dependents: %v", err))
+			} else {
+				opened := 0
+				for _, e := range entries {
+					if e.Status == CascadeStatusPROpened {
+						opened++
+					}
+				}
+				if opened > 0 {
+					summary = append(summary, fmt.Sprintf("%d dependency PRs opened", opened))
+				}
+			}
+			g.printCascadeReport(CascadeReport{Entries: entries})
+		}
+		summary = append(summary, g.runPostHooks(HookPostCascade, hookCtx)...)
+		if record != nil {
+			g.finishPushRecord(record)
+		}
 	}
 
 	// 7. Execute backup (asynchronous, non-blocking)
@@ -419,7 +620,10 @@ func (g *Go) UpdateDependentModule(depDir string, bumps []gitmod.DepBump, rootCa
 	// 4. Check if already up-to-date AND no replace to remove
 	anyChange := false
 	for _, bump := range bumps {
-		canRemove := gomod.RemoveReplace(bump.ModulePath)
+		canRemove, err := gomod.RemoveReplaceErr(bump.ModulePath)
+		if err != nil {
+			return g.reportFail(depName, fmt.Errorf("failed to read go.mod: %w", err))
+		}
 		currentVer, err := g.GetCurrentVersion(depDir, bump.ModulePath)
 		if err == nil {
 			if gitmod.CompareVersions(currentVer, bump.NewVersion) < 0 || canRemove {
@@ -437,28 +641,35 @@ func (g *Go) UpdateDependentModule(depDir string, bumps []gitmod.DepBump, rootCa
 		return CascadeOutcome{Status: CascadeStatusSkipped, Reason: reason}, nil
 	}
 
-	// 5. Mutate
+	// 5. Mutate: the requirements are edited in go.mod directly (keeping
+	// their comments and place) and a single tidy resolves them all, where
+	// a go get per bump resolved the build list once per module before tidy
+	// resolved it again. tidy downloads the new versions and rewrites go.sum.
+	for _, bump := range bumps {
+		if err := gomod.SetRequire(bump.ModulePath, bump.NewVersion); err != nil {
+			return g.reportFail(depName, fmt.Errorf("failed to update %s: %w", bump.ModulePath, err))
+		}
+	}
 	if gomod.Modified {
 		if err := gomod.Save(); err != nil {
 			return g.reportFail(depName, fmt.Errorf("failed to save go.mod: %w", err))
 		}
 	}
 
-	for _, bump := range bumps {
-		target := fmt.Sprintf("%s@%s", bump.ModulePath, bump.NewVersion)
-		if _, err := command.RunWithRetry(depDir, "go", []string{"get", target}, g.retryAttempts, g.retryDelay); err != nil {
-			return g.reportFail(depName, fmt.Errorf("go get failed after retries: %w", err))
-		}
-	}
-
-	if output, err := command.RunInDir(depDir, "go", "mod", "tidy"); err != nil {
-		return g.reportFail(depName, fmt.Errorf("go mod tidy failed: %s", extractFirstFailure(output)))
+	// tidy/test run with GOWORK=off: an active go.work would resolve the
+	// bumped modules from local checkouts and hide a go.mod/go.sum that does
+	// not work for consumers of the published version. Retried because a
+	// fresh tag can take a while to reach the proxy.
+	if _, err := runWithRetryModuleMode(depDir, g.goEnv(), "go", []string{"mod", "tidy"}, g.retryAttempts, g.retryDelay); err != nil {
+		return g.reportFail(depName, fmt.Errorf("go mod tidy failed after retries: %w", err))
 	}
 
-	_, _ = command.RunInDir(depDir, "go", "generate", "./...")
+	// Generated code may depend on the bumped modules: it is regenerated and
+	// published with the bump (the stale-files gate is for the pushed module).
+	_, _ = runInDirModuleMode(depDir, g.goEnv(), "go", "generate", "./...")
 
 	// 6. gotest (gate)
-	if output, err := command.RunInDir(depDir, "gotest", "-t", "60", "-no-cache"); err != nil {
+	if output, err := runInDirModuleMode(depDir, g.goEnv(), "gotest", "-t", "60", "-no-cache"); err != nil {
 		cause := extractFirstFailure(output)
 		g.consoleOutput(fmt.Sprintf("📦 %s → %s ❌", depName, cause))
 		return CascadeOutcome{}, fmt.Errorf("tests failed: %w", err)
@@ -470,11 +681,19 @@ func (g *Go) UpdateDependentModule(depDir string, bumps []gitmod.DepBump, rootCa
 		return g.reportFail(depName, fmt.Errorf("go handler init failed: %w", err))
 	}
 	depHandler.SetRootDir(depDir)
+	depHandler.SetSkipGenerateCheck(true) // regenerated above
+	g.inheritSigning(depHandler)
+	g.inheritProxy(depHandler)
 
 	commitMsg := gitmod.BuildDepsCommitMessage(bumps, rootCause)
 
 	if action == gitmod.ActionDepsOnly {
-		committed, err := git.CommitPaths(commitMsg, "go.mod", "go.sum")
+		var committed bool
+		if depHandler.signingActive {
+			committed, err = depHandler.commitSigned(depDir, commitMsg, "go.mod", "go.sum")
+		} else {
+			committed, err = git.CommitPaths(commitMsg, "go.mod", "go.sum")
+		}
 		if err != nil {
 			return g.reportFail(depName, fmt.Errorf("deps-only commit failed: %w", err))
 		}
@@ -482,6 +701,7 @@ func (g *Go) UpdateDependentModule(depDir string, bumps []gitmod.DepBump, rootCa
 			if _, err := git.PushWithoutTags(); err != nil {
 				return g.reportFail(depName, fmt.Errorf("deps-only push failed: %w", err))
 			}
+			g.recordDependent(depDir, "")
 		}
 		g.consoleOutput(fmt.Sprintf("📦 %s → %s (%s) ⚠", depName, CascadeStatusDepsOnly, reason))
 		success = true
@@ -495,6 +715,7 @@ func (g *Go) UpdateDependentModule(depDir string, bumps []gitmod.DepBump, rootCa
 		return CascadeOutcome{}, fmt.Errorf("push failed: %w", err)
 	}
 
+	g.recordDependent(depDir, pushRes.Tag)
 	g.consoleOutput(fmt.Sprintf("📦 %s → updated ✅", depName))
 	success = true
 	return CascadeOutcome{Status: CascadeStatusPublished, Version: pushRes.Tag}, nil
@@ -502,8 +723,9 @@ func (g *Go) UpdateDependentModule(depDir string, bumps []gitmod.DepBump, rootCa
 
 // GetCurrentVersion returns the current version of a dependency in a module
 func (g *Go) GetCurrentVersion(moduleDir, dependencyPath string) (string, error) {
-	// Use go list -m -json dependencyPath directly in moduleDir
-	output, err := command.RunInDir(moduleDir, "go", "list", "-m", "-json", dependencyPath)
+	// Use go list -m -json dependencyPath directly in moduleDir. GOWORK=off:
+	// in workspace mode a workspace module is reported without a version.
+	output, err := runInDirModuleMode(moduleDir, g.goEnv(), "go", "list", "-m", "-json", dependencyPath)
 	if err != nil {
 		return "", err
 	}
@@ -597,7 +819,7 @@ func (g *Go) Install(version string) error {
 		}
 		args = append(args, pkg)
 
-		if _, err := command.RunInDir(installDir, "go", args...); err != nil {
+		if _, err := runInDirEnv(installDir, g.goEnv(), "go", args...); err != nil {
 			return fmt.Errorf("failed to install %s: %w", cmd, err)
 		}
 	}
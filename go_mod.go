@@ -8,15 +8,21 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
 )
 
 // GoModHandler represents a parsed go.mod file and handles file events
 type GoModHandler struct {
-	Lines    []string // all lines of the file
-	Modified bool     // track if changes were made
+	Lines    []string      // all lines of the file; setting them replaces the parsed go.mod
+	Modified bool          // track if changes were made
+	file     *modfile.File // parsed go.mod, read on first use
+	lines    []string      // Lines as of the last parse
 
 	// Handler fields
 	rootDir         string
@@ -49,269 +55,500 @@ func (g *GoModHandler) load() error {
 	if err != nil {
 		return err
 	}
+	return g.SetContent(content)
+}
 
-	g.Lines = strings.Split(string(content), "\n")
+// SetContent replaces the handler's go.mod with content, as if read from
+// rootDir/go.mod, discarding unsaved changes.
+func (m *GoModHandler) SetContent(content []byte) error {
+	f, err := modfile.Parse(filepath.Join(m.rootDir, "go.mod"), content, nil)
+	if err != nil {
+		return err
+	}
+	m.file = f
+	m.Lines = strings.Split(string(content), "\n")
+	m.lines = slices.Clone(m.Lines)
+	m.Modified = false
 	return nil
 }
 
-// RemoveReplace removes a replace directive for the given module.
-// Local replace directives (target starting with "." or "/", e.g. "=> ./")
-// are preserved: subpackages (tests/, cmd/, etc.) commonly use a self-referencing
-// local replace to pull in the parent module without polluting the root go.mod,
-// and that must survive dependent-module updates.
-// Returns true if a replace was found and removed
-func (m *GoModHandler) RemoveReplace(modulePath string) bool {
-	// check if loaded
-	if len(m.Lines) == 0 {
+// parse returns the parsed go.mod, loading the file first if needed. Lines
+// changed by the caller are parsed again.
+func (m *GoModHandler) parse() (*modfile.File, error) {
+	if m.file == nil && m.Lines == nil {
 		if err := m.load(); err != nil {
-			return false
+			return nil, err
+		}
+	} else if m.file == nil || !slices.Equal(m.Lines, m.lines) {
+		modified := m.Modified
+		if err := m.SetContent([]byte(strings.Join(m.Lines, "\n"))); err != nil {
+			return nil, err
 		}
+		m.Modified = modified
 	}
+	return m.file, nil
+}
 
-	originalCount := len(m.Lines)
-	var newLines []string
-	inReplaceBlock := false
-	removed := false
+// edit applies fn to the parsed go.mod and marks it modified when fn reports
+// a change. Save formats it: the formatter keeps comments and blocks, so only
+// the edited directives change.
+func (m *GoModHandler) edit(fn func(f *modfile.File) (bool, error)) (bool, error) {
+	f, err := m.parse()
+	if err != nil {
+		return false, err
+	}
+	changed, err := fn(f)
+	if err != nil || !changed {
+		return false, err
+	}
+	f.Cleanup()
+	// Some edits (AddRetract) only change the syntax tree: parse it again so
+	// the directives match it.
+	if err := m.SetContent(modfile.Format(f.Syntax)); err != nil {
+		return false, err
+	}
+	m.Modified = true
+	return true, nil
+}
 
-	for _, line := range m.Lines {
-		trimmed := strings.TrimSpace(line)
+// GetModulePath returns the path of the module directive.
+func (m *GoModHandler) GetModulePath() (string, error) {
+	f, err := m.parse()
+	if err != nil {
+		return "", err
+	}
+	if f.Module == nil {
+		return "", fmt.Errorf("module directive not found in go.mod")
+	}
+	return f.Module.Mod.Path, nil
+}
 
-		// Detect start/end of replace block
-		if strings.HasPrefix(trimmed, "replace (") {
-			inReplaceBlock = true
-			newLines = append(newLines, line)
-			continue
-		}
-		if inReplaceBlock && trimmed == ")" {
-			inReplaceBlock = false
-			// Check if we just emptied the block (last line was "replace (")
-			if len(newLines) > 0 && strings.HasPrefix(strings.TrimSpace(newLines[len(newLines)-1]), "replace (") {
-				newLines = newLines[:len(newLines)-1] // remove "replace ("
-				removed = true
-				continue
-			}
-			newLines = append(newLines, line)
-			continue
+// SetModulePath rewrites the module directive.
+func (m *GoModHandler) SetModulePath(modulePath string) error {
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		if f.Module != nil && f.Module.Mod.Path == modulePath {
+			return false, nil
 		}
+		return true, f.AddModuleStmt(modulePath)
+	})
+	return err
+}
 
-		// Check for the module in replace
-		if strings.HasPrefix(trimmed, "replace ") || inReplaceBlock {
-			// Extract module path from line
-			mod, _ := parseReplaceLine(trimmed, inReplaceBlock)
-			if mod == modulePath {
-				if isLocalReplaceTarget(trimmed) {
-					newLines = append(newLines, line)
-					continue
-				}
-				removed = true
-				continue // skip this line
-			}
-		}
+// RequireEntry represents a require directive.
+type RequireEntry struct {
+	ModulePath string
+	Version    string
+	Indirect   bool
+}
 
-		newLines = append(newLines, line)
+// GetRequires returns the require directives in go.mod, in file order.
+func (m *GoModHandler) GetRequires() ([]RequireEntry, error) {
+	f, err := m.parse()
+	if err != nil {
+		return nil, err
 	}
-
-	if removed || len(newLines) != originalCount {
-		m.Lines = newLines
-		m.Modified = true
-		return true
+	var entries []RequireEntry
+	for _, r := range f.Require {
+		entries = append(entries, RequireEntry{ModulePath: r.Mod.Path, Version: r.Mod.Version, Indirect: r.Indirect})
 	}
-
-	return false
+	return entries, nil
 }
 
-func parseReplaceLine(line string, inBlock bool) (modPath, targetPath string) {
-	s := line
-	if !inBlock {
-		s = strings.TrimPrefix(s, "replace ")
-	}
-	parts := strings.Split(s, "=>")
-	if len(parts) != 2 {
-		return "", ""
-	}
-	modPath = strings.TrimSpace(parts[0])
-	targetPath = strings.TrimSpace(parts[1])
-	if idx := strings.Index(targetPath, "//"); idx != -1 {
-		targetPath = strings.TrimSpace(targetPath[:idx])
+// GetRequire returns the required version of modulePath, or "" if go.mod
+// does not require it.
+func (m *GoModHandler) GetRequire(modulePath string) string {
+	entries, _ := m.GetRequires()
+	for _, r := range entries {
+		if r.ModulePath == modulePath {
+			return r.Version
+		}
 	}
-	return modPath, targetPath
+	return ""
 }
 
-// isLocalReplaceTarget reports whether a replace directive line's target
-// (the part after "=>") is a self-reference to the current directory (e.g.
-// "=> ./"). Subpackages (tests/, cmd/, etc.) commonly declare their own
-// go.mod with a replace like this to pull in the parent module locally
-// without touching the root go.mod; other local paths (e.g. "../lib",
-// pointing at an unrelated sibling checkout) are still eligible for removal.
-func isLocalReplaceTarget(line string) bool {
-	parts := strings.SplitN(line, "=>", 2)
-	if len(parts) != 2 {
-		return false
+// SetRequire requires modulePath at version, updating the existing
+// directive in place or adding a new one. Only go.mod is edited: go.sum is
+// left to 'go mod tidy'.
+func (m *GoModHandler) SetRequire(modulePath, version string) error {
+	if v := strings.TrimSuffix(version, "+incompatible"); semver.Canonical(v) != v {
+		return fmt.Errorf("require %s: version %q is not a canonical semantic version", modulePath, version)
 	}
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		for _, r := range f.Require {
+			if r.Mod.Path == modulePath && r.Mod.Version == version {
+				return false, nil
+			}
+		}
+		return true, f.AddRequire(modulePath, version)
+	})
+	return err
+}
 
-	target := strings.TrimSpace(parts[1])
-	if idx := strings.Index(target, "//"); idx != -1 {
-		target = strings.TrimSpace(target[:idx])
+// DropRequire removes the require directive for modulePath, if any.
+func (m *GoModHandler) DropRequire(modulePath string) error {
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		for _, r := range f.Require {
+			if r.Mod.Path == modulePath {
+				return true, f.DropRequire(modulePath)
+			}
+		}
+		return false, nil
+	})
+	return err
+}
+
+// RemoveReplace removes a replace directive for the given module.
+// Local replace directives (target starting with "." or "/", e.g. "=> ./")
+// are preserved: subpackages (tests/, cmd/, etc.) commonly use a self-referencing
+// local replace to pull in the parent module without polluting the root go.mod,
+// and that must survive dependent-module updates.
+// Returns true if a replace was found and removed; false when go.mod cannot be
+// read (RemoveReplaceErr returns why).
+func (m *GoModHandler) RemoveReplace(modulePath string) bool {
+	removed, err := m.RemoveReplaceErr(modulePath)
+	if err != nil {
+		m.log("RemoveReplace:", err)
 	}
+	return removed
+}
 
-	return filepath.Clean(target) == "."
+// RemoveReplaceErr is RemoveReplace returning the error reading or editing
+// go.mod.
+func (m *GoModHandler) RemoveReplaceErr(modulePath string) (bool, error) {
+	return m.edit(func(f *modfile.File) (bool, error) {
+		removed := false
+		for _, r := range f.Replace {
+			if r.Old.Path != modulePath || isLocalReplaceTarget(r) {
+				continue
+			}
+			if err := f.DropReplace(r.Old.Path, r.Old.Version); err != nil {
+				return false, err
+			}
+			removed = true
+		}
+		return removed, nil
+	})
+}
+
+// DropReplace removes every replace directive for modulePath, self-references
+// included (see RemoveReplace for the cascade-safe variant).
+func (m *GoModHandler) DropReplace(modulePath string) error {
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		removed := false
+		for _, r := range f.Replace {
+			if r.Old.Path != modulePath {
+				continue
+			}
+			if err := f.DropReplace(r.Old.Path, r.Old.Version); err != nil {
+				return false, err
+			}
+			removed = true
+		}
+		return removed, nil
+	})
+	return err
+}
+
+// isLocalReplaceTarget reports whether a replace directive's target is a
+// self-reference to the current directory (e.g. "=> ./"). Subpackages
+// (tests/, cmd/, etc.) commonly declare their own go.mod with a replace like
+// this to pull in the parent module locally without touching the root go.mod;
+// other local paths (e.g. "../lib", pointing at an unrelated sibling
+// checkout) are still eligible for removal.
+func isLocalReplaceTarget(r *modfile.Replace) bool {
+	return r.New.Version == "" && filepath.Clean(r.New.Path) == "."
 }
 
 // GetReplacePaths returns absolute paths from local replace directives.
 // Relative paths are resolved starting from the directory containing go.mod.
 func (m *GoModHandler) GetReplacePaths() ([]ReplaceEntry, error) {
-	// check if loaded
-	if len(m.Lines) == 0 {
-		if err := m.load(); err != nil {
-			return nil, err
-		}
+	f, err := m.parse()
+	if err != nil {
+		return nil, err
 	}
 
 	var entries []ReplaceEntry
-	inReplaceBlock := false
-	rootDir := m.rootDir
-
-	for _, line := range m.Lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Detect start/end of replace block
-		if strings.HasPrefix(trimmed, "replace (") {
-			inReplaceBlock = true
-			continue
-		}
-		if inReplaceBlock && trimmed == ")" {
-			inReplaceBlock = false
+	for _, r := range f.Replace {
+		// Only local paths: in go.mod they MUST start with ./ or ../ or be
+		// absolute, and carry no version.
+		if r.New.Version != "" || !modfile.IsDirectoryPath(r.New.Path) {
 			continue
 		}
 
-		if strings.HasPrefix(trimmed, "replace ") || inReplaceBlock {
-			modPath, localPath := parseReplaceLine(trimmed, inReplaceBlock)
-			if modPath == "" || localPath == "" {
-				continue
-			}
-
-			// Check if localPath is actually a local path or a versioned module.
-			// Local paths in go.mod MUST start with ./ or ../ or be absolute.
-			isLocal := strings.HasPrefix(localPath, ".") || strings.HasPrefix(localPath, "/")
-			if !isLocal {
-				continue
-			}
-
-			// Resolve to absolute path
-			absPath := localPath
-			if !filepath.IsAbs(localPath) {
-				absPath = filepath.Join(rootDir, localPath)
-			}
-			absPath, _ = filepath.Abs(absPath)
-
-			entries = append(entries, ReplaceEntry{
-				ModulePath: modPath,
-				LocalPath:  absPath,
-			})
+		// Resolve to absolute path
+		absPath := r.New.Path
+		if !filepath.IsAbs(absPath) {
+			absPath = filepath.Join(m.rootDir, absPath)
 		}
+		absPath, _ = filepath.Abs(absPath)
+
+		entries = append(entries, ReplaceEntry{
+			ModulePath: r.Old.Path,
+			LocalPath:  absPath,
+		})
 	}
 
 	return entries, nil
 }
 
 // HasOtherReplaces returns true if there are replace directives
-// other than the specified modules
+// other than the specified modules; false when go.mod cannot be read
+// (HasOtherReplacesErr returns why).
 func (m *GoModHandler) HasOtherReplaces(exceptModules ...string) bool {
-	// check if loaded
-	if len(m.Lines) == 0 {
-		if err := m.load(); err != nil {
-			return false
+	other, err := m.HasOtherReplacesErr(exceptModules...)
+	if err != nil {
+		m.log("HasOtherReplaces:", err)
+	}
+	return other
+}
+
+// HasOtherReplacesErr is HasOtherReplaces returning the error reading go.mod.
+func (m *GoModHandler) HasOtherReplacesErr(exceptModules ...string) (bool, error) {
+	f, err := m.parse()
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range f.Replace {
+		isExcept := false
+		for _, ex := range exceptModules {
+			if ex != "" && r.Old.Path == ex {
+				isExcept = true
+				break
+			}
+		}
+		if !isExcept {
+			return true, nil
 		}
 	}
+	return false, nil
+}
 
-	inReplaceBlock := false
-	for _, line := range m.Lines {
-		trimmed := strings.TrimSpace(line)
+// EnsureReplace ensures that a replace directive exists for the given module path
+// pointing to the given local path. Returns true if the file was modified;
+// false when go.mod cannot be read (EnsureReplaceErr returns why).
+func (m *GoModHandler) EnsureReplace(modulePath, localPath string) bool {
+	changed, err := m.EnsureReplaceErr(modulePath, localPath)
+	if err != nil {
+		m.log("EnsureReplace:", err)
+	}
+	return changed
+}
 
-		if strings.HasPrefix(trimmed, "replace (") {
-			inReplaceBlock = true
-			continue
+// EnsureReplaceErr is EnsureReplace returning the error reading or editing
+// go.mod.
+func (m *GoModHandler) EnsureReplaceErr(modulePath, localPath string) (bool, error) {
+	return m.edit(func(f *modfile.File) (bool, error) {
+		for _, r := range f.Replace {
+			if r.Old.Path == modulePath && r.New.Version == "" && filepath.Clean(r.New.Path) == filepath.Clean(localPath) {
+				return false, nil
+			}
 		}
-		if inReplaceBlock && trimmed == ")" {
-			inReplaceBlock = false
-			continue
+		existing := len(f.Replace)
+		for _, r := range f.Replace {
+			if r.Old.Path == modulePath {
+				existing = -1
+			}
+		}
+		if err := f.AddReplace(modulePath, "", localPath, ""); err != nil {
+			return false, err
 		}
+		if existing >= 0 {
+			moveIntoBlock(f.Syntax, f.Replace[existing].Syntax)
+		}
+		return true, nil
+	})
+}
 
-		if (strings.HasPrefix(trimmed, "replace ") || inReplaceBlock) && trimmed != "" {
-			mod, _ := parseReplaceLine(trimmed, inReplaceBlock)
-			isExcept := false
-			for _, ex := range exceptModules {
-				if ex != "" && mod == ex {
-					isExcept = true
-					break
-				}
+// moveIntoBlock moves line, just added as a standalone directive, into the
+// last block of the same verb if there is one: AddReplace (unlike
+// AddNewRequire) always appends a standalone line.
+func moveIntoBlock(syntax *modfile.FileSyntax, line *modfile.Line) {
+	var block *modfile.LineBlock
+	idx := -1
+	for i, stmt := range syntax.Stmt {
+		switch s := stmt.(type) {
+		case *modfile.LineBlock:
+			if len(s.Token) > 0 && s.Token[0] == line.Token[0] {
+				block = s
 			}
-			if isExcept {
-				continue
+		case *modfile.Line:
+			if s == line {
+				idx = i
 			}
-			return true
 		}
 	}
-	return false
+	if block == nil || idx < 0 {
+		return
+	}
+	syntax.Stmt = append(syntax.Stmt[:idx], syntax.Stmt[idx+1:]...)
+	line.Token = line.Token[1:]
+	line.InBlock = true
+	block.Line = append(block.Line, line)
 }
 
-// EnsureReplace ensures that a replace directive exists for the given module path
-// pointing to the given local path. Returns true if the file was modified.
-func (m *GoModHandler) EnsureReplace(modulePath, localPath string) bool {
-	// check if loaded
-	if len(m.Lines) == 0 {
-		if err := m.load(); err != nil {
+// AddExclude adds an exclude directive for modulePath at version.
+func (m *GoModHandler) AddExclude(modulePath, version string) error {
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		for _, x := range f.Exclude {
+			if x.Mod.Path == modulePath && x.Mod.Version == version {
+				return false, nil
+			}
 		}
+		return true, f.AddExclude(modulePath, version)
+	})
+	return err
+}
+
+// DropExclude removes the exclude directive for modulePath at version, if any.
+func (m *GoModHandler) DropExclude(modulePath, version string) error {
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		for _, x := range f.Exclude {
+			if x.Mod.Path == modulePath && x.Mod.Version == version {
+				return true, f.DropExclude(modulePath, version)
+			}
+		}
+		return false, nil
+	})
+	return err
+}
+
+// GetToolchain returns the toolchain directive (e.g. "go1.25.2"), or "".
+func (m *GoModHandler) GetToolchain() string {
+	f, err := m.parse()
+	if err != nil || f.Toolchain == nil {
+		return ""
 	}
+	return f.Toolchain.Name
+}
 
-	// Check if already exists
-	found := false
-	inReplaceBlock := false
-	for _, line := range m.Lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "replace (") {
-			inReplaceBlock = true
-			continue
+// SetToolchain sets the toolchain directive; an empty name drops it.
+func (m *GoModHandler) SetToolchain(name string) error {
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		current := ""
+		if f.Toolchain != nil {
+			current = f.Toolchain.Name
 		}
-		if inReplaceBlock && trimmed == ")" {
-			inReplaceBlock = false
-			continue
+		if current == name {
+			return false, nil
 		}
+		if name == "" {
+			f.DropToolchainStmt()
+			return true, nil
+		}
+		return true, f.AddToolchainStmt(name)
+	})
+	return err
+}
 
-		if strings.HasPrefix(trimmed, "replace ") || inReplaceBlock {
-			mod, target := parseReplaceLine(trimmed, inReplaceBlock)
-			if mod == modulePath && filepath.Clean(target) == filepath.Clean(localPath) {
-				found = true
-				break
+// GetGodebug returns the value of the godebug setting key, or "".
+func (m *GoModHandler) GetGodebug(key string) string {
+	f, err := m.parse()
+	if err != nil {
+		return ""
+	}
+	for _, g := range f.Godebug {
+		if g.Key == key {
+			return g.Value
+		}
+	}
+	return ""
+}
+
+// SetGodebug sets the godebug setting key=value; an empty value drops it.
+func (m *GoModHandler) SetGodebug(key, value string) error {
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		current := ""
+		for _, g := range f.Godebug {
+			if g.Key == key {
+				current = g.Value
 			}
 		}
+		if current == value {
+			return false, nil
+		}
+		if value == "" {
+			return true, f.DropGodebug(key)
+		}
+		return true, f.AddGodebug(key, value)
+	})
+	return err
+}
+
+// RetractEntry represents a retract directive: a single version (Low == High)
+// or a closed range [Low, High].
+type RetractEntry struct {
+	Low       string
+	High      string
+	Rationale string // comment on the preceding line(s) or at the end of the line
+}
+
+// String returns the directive argument: "v1.2.3" or "[v1.2.0, v1.2.3]".
+func (r RetractEntry) String() string {
+	if r.Low == r.High {
+		return r.Low
 	}
+	return "[" + r.Low + ", " + r.High + "]"
+}
 
-	if found {
-		return false
+// GetRetracts returns the retract directives in go.mod, in file order.
+func (m *GoModHandler) GetRetracts() ([]RetractEntry, error) {
+	f, err := m.parse()
+	if err != nil {
+		return nil, err
 	}
 
-	// Add it. If there's a replace block, add it there. Otherwise, add a single-line replace.
+	var entries []RetractEntry
+	for _, r := range f.Retract {
+		entries = append(entries, RetractEntry{Low: r.Low, High: r.High, Rationale: r.Rationale})
+	}
+	return entries, nil
+}
 
-	// Try to find a place to insert
-	inserted := false
-	var newLines []string
-	for _, line := range m.Lines {
-		newLines = append(newLines, line)
-		if !inserted && strings.HasPrefix(strings.TrimSpace(line), "replace (") {
-			newLines = append(newLines, "\t"+modulePath+" => "+localPath)
-			inserted = true
-		}
+// AddRetract adds a retract directive for low (single version, high == "" or
+// high == low) or the range [low, high], with rationale as a comment on the
+// line above, the form the go command reports. Entries are appended to an
+// existing retract block when there is one. Returns false if go.mod cannot be
+// read, a version is not canonical, or the exact entry is already retracted.
+func (m *GoModHandler) AddRetract(low, high, rationale string) bool {
+	if high == "" {
+		high = low
 	}
 
-	if !inserted {
-		// Just append at the end
-		newLines = append(newLines, "", "replace "+modulePath+" => "+localPath)
+	var comment []string
+	for _, l := range strings.Split(strings.TrimSpace(rationale), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			comment = append(comment, l)
+		}
 	}
 
-	m.Lines = newLines
-	m.Modified = true
-	return true
+	added, _ := m.edit(func(f *modfile.File) (bool, error) {
+		for _, r := range f.Retract {
+			if r.Low == low && r.High == high {
+				return false, nil
+			}
+		}
+		vi := modfile.VersionInterval{Low: low, High: high}
+		return true, f.AddRetract(vi, strings.Join(comment, "\n"))
+	})
+	return added
+}
+
+// DropRetract removes the retract directive for [low, high], if any.
+func (m *GoModHandler) DropRetract(low, high string) error {
+	if high == "" {
+		high = low
+	}
+	_, err := m.edit(func(f *modfile.File) (bool, error) {
+		vi := modfile.VersionInterval{Low: low, High: high}
+		for _, r := range f.Retract {
+			if r.VersionInterval == vi {
+				return true, f.DropRetract(vi)
+			}
+		}
+		return false, nil
+	})
+	return err
 }
 
 // Save writes changes back to the file if modified
@@ -319,9 +556,11 @@ func (m *GoModHandler) Save() error {
 	if !m.Modified {
 		return nil
 	}
-
-	content := strings.Join(m.Lines, "\n")
-	return os.WriteFile(filepath.Join(m.rootDir, "go.mod"), []byte(content), 0644)
+	f, err := m.parse()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.rootDir, "go.mod"), modfile.Format(f.Syntax), 0644)
 }
 
 // RunTidy executes 'go mod tidy' in the directory of the go.mod file
@@ -333,7 +572,13 @@ func (m *GoModHandler) RunTidy() error {
 	return err
 }
 
+// SetRootDir points the handler at the go.mod of path; a different path
+// discards the go.mod read so far.
 func (g *GoModHandler) SetRootDir(path string) {
+	if path != g.rootDir {
+		g.file, g.Lines, g.lines = nil, nil, nil
+		g.Modified = false
+	}
 	g.rootDir = path
 }
 
@@ -351,7 +596,11 @@ func (g *GoModHandler) SetOnSSRFileChange(fn func(string)) {
 
 func (m *GoModHandler) ObjectsToPublish(ctx gitmod.PublishContext) (gitmod.PublishAction, string) {
 	m.SetRootDir(ctx.RepoDir)
-	if m.HasOtherReplaces(ctx.ModulePaths...) {
+	other, err := m.HasOtherReplacesErr(ctx.ModulePaths...)
+	if err != nil {
+		return gitmod.ActionSkip, err.Error()
+	}
+	if other {
 		return gitmod.ActionDepsOnly, gitmod.ObjectionOtherReplaces
 	}
 	return gitmod.ActionNone, ""
@@ -395,14 +644,16 @@ func (g *GoModHandler) NewFileEvent(fileName, extension, filePath, event string)
 		}
 	}
 
-	// Refresh lines from file
+	// Refresh from file
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		g.log("Error reading go.mod:", err)
 		return err
 	}
-	g.Lines = strings.Split(string(content), "\n")
-	g.Modified = false
+	if err := g.SetContent(content); err != nil {
+		g.log("Error parsing go.mod:", err)
+		return err
+	}
 
 	entries, err := g.GetReplacePaths()
 	if err != nil {
@@ -519,7 +770,7 @@ func (g *Go) Verify() error {
 		return fmt.Errorf("go.mod not found")
 	}
 
-	output, err := command.RunInDir(g.rootDir, "go", "mod", "verify")
+	output, err := runInDirEnv(g.rootDir, g.goEnv(), "go", "mod", "verify")
 	if err == nil {
 		return nil
 	}
@@ -563,7 +814,9 @@ func extractModuleRef(line string) string {
 	return "unknown module"
 }
 
-// WaitForVersionAvailable waits for a module version to be available on Go proxy
+// WaitForVersionAvailable waits for a module version to be available on Go proxy.
+// `go list` resolves it like the dependents will: through GOPROXY, or
+// directly for GOPRIVATE/GONOPROXY modules.
 func (g *Go) WaitForVersionAvailable(modulePath, version string) error {
 	target := fmt.Sprintf("%s@%s", modulePath, version)
 
@@ -578,7 +831,7 @@ func (g *Go) WaitForVersionAvailable(modulePath, version string) error {
 	}
 
 	for i := 0; i < maxRetries; i++ {
-		_, err := command.Run("go", "list", "-m", target)
+		_, err := runInDirEnv(g.rootDir, g.goEnv(), "go", "list", "-m", target)
 		if err == nil {
 			return nil
 		}
@@ -587,27 +840,42 @@ func (g *Go) WaitForVersionAvailable(modulePath, version string) error {
 			time.Sleep(delay)
 		}
 	}
+	proxy := os.Getenv("GOPROXY")
+	if proxy == "" && g.proxy != nil {
+		proxy = g.proxy.GOPROXY
+	}
+	if proxy != "" {
+		return fmt.Errorf("version %s not available on %s after %d attempts", version, proxy, maxRetries)
+	}
 	return fmt.Errorf("version %s not available after %d attempts", version, maxRetries)
 }
 
 // UpdateDependents updates modules that depend on the current one
 func (g *Go) UpdateDependents(modulePath, version, searchPath string) error {
+	_, err := g.updateDependents(modulePath, version, "", searchPath)
+	return err
+}
+
+// updateDependents is UpdateDependents with the root-cause line recorded in
+// each dependent's bump commit. It returns the directories of the dependents
+// found locally.
+func (g *Go) updateDependents(modulePath, version, rootCause, searchPath string) ([]string, error) {
 	if searchPath == "" {
 		searchPath = ".."
 	}
 
 	dependents, err := g.FindDependentModules(modulePath, searchPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(dependents) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	if err := g.WaitForVersionAvailable(modulePath, version); err != nil {
 		g.consoleOutput(fmt.Sprintf("⏳ %s", err))
-		return nil
+		return dependents, nil
 	}
 
 	var wg sync.WaitGroup
@@ -624,21 +892,34 @@ func (g *Go) UpdateDependents(modulePath, version, searchPath string) error {
 			// Every outcome — success, skip and failure alike — is streamed via
 			// consoleOutput inside UpdateDependentModule, so one line per dependent
 			// always reaches the terminal and the count above stays honest.
-			_, _ = g.UpdateDependentModule(dir, []gitmod.DepBump{{ModulePath: modulePath, NewVersion: version}}, "")
+			_, _ = g.UpdateDependentModule(dir, []gitmod.DepBump{{ModulePath: modulePath, NewVersion: version}}, rootCause)
 		}(depDir)
 	}
 
 	wg.Wait()
-	return nil
+	return dependents, nil
 }
 
 // FindDependentModules searches for modules that have modulePath as dependency.
 // It excludes modules located inside the current project's root directory.
+// When a go.work is active only its use entries are considered.
 func (g *Go) FindDependentModules(modulePath, searchPath string) ([]string, error) {
 	var dependents []string
 
 	absRoot, _ := filepath.Abs(g.rootDir)
 
+	if uses := g.workspaceModulesIn(searchPath); uses != nil {
+		for _, dir := range uses {
+			if strings.HasPrefix(dir, absRoot+string(os.PathSeparator)) || dir == absRoot {
+				continue
+			}
+			if g.HasDependency(filepath.Join(dir, "go.mod"), modulePath) {
+				dependents = append(dependents, dir)
+			}
+		}
+		return dependents, nil
+	}
+
 	err := filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Continue despite errors
@@ -2,7 +2,6 @@ package devflow
 
 import (
 	"fmt"
-	"github.com/tinywasm/command"
 	"os"
 	"path/filepath"
 )
@@ -40,39 +39,56 @@ func (g *Go) syncInternalSubmodules(parentModulePath, nextTag string) error {
 		}
 
 		g.log(fmt.Sprintf("Syncing internal submodule: %s", filepath.Base(subDir)))
-
-		// 1. Ensure relative replace
-		rel, err := filepath.Rel(subDir, absRoot)
-		if err != nil {
-			continue
+		if err := g.pinInternalRequire(subDir, absRoot, parentModulePath, nextTag); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+// pinInternalRequire makes the module in subDir require modulePath (the
+// module in targetDir, same repo) at version, which is not published yet:
+// a relative replace resolves it, then go mod tidy runs. With an active
+// go.work that lists subDir only the requirement is recorded.
+func (g *Go) pinInternalRequire(subDir, targetDir, modulePath, version string) error {
+	gomod := NewGoModHandler()
+	gomod.SetRootDir(subDir)
 
-		gomod := NewGoModHandler()
-		gomod.SetRootDir(subDir)
-		gomod.EnsureReplace(parentModulePath, rel)
+	// With an active go.work that lists the submodule, the target already
+	// resolves locally: no replace juggling, just record the new
+	// requirement (no network, the tag is not published yet).
+	if g.inWorkspace(subDir) {
+		if err := gomod.SetRequire(modulePath, version); err != nil {
+			return fmt.Errorf("failed to update requirement in %s: %w", subDir, err)
+		}
 		if err := gomod.Save(); err != nil {
 			return fmt.Errorf("failed to save submodule go.mod: %w", err)
 		}
+		return nil
+	}
 
-		// 2. Bump requirement to nextTag
-		// Note: We use go get parent@tag. Since we have a replace, this just updates go.mod
-		// without needing the tag to be published yet.
-		target := fmt.Sprintf("%s@%s", parentModulePath, nextTag)
-		if _, err := command.RunInDir(subDir, "go", "get", target); err != nil {
-			// If it fails (e.g. tag doesn't exist yet and proxy is used),
-			// we might need to use a different approach or just ignore if it's not strictly needed
-			// for the build (because of the replace).
-			// But gopush's goal is to have the correct version in go.mod.
-			// Try with GOPROXY=off or similar?
-			// Actually, 'go get' should work if the replace is there and we are just updating the version string.
-			g.log(fmt.Sprintf("Warning: go get %s failed in %s: %v", target, subDir, err))
-		}
+	// 1. Ensure relative replace
+	rel, err := filepath.Rel(subDir, targetDir)
+	if err != nil {
+		return nil
+	}
+	if _, err := gomod.EnsureReplaceErr(modulePath, rel); err != nil {
+		return fmt.Errorf("failed to add replace in %s: %w", subDir, err)
+	}
 
-		// 3. Tidy
-		if _, err := command.RunInDir(subDir, "go", "mod", "tidy"); err != nil {
-			return fmt.Errorf("go mod tidy failed in %s: %w", subDir, err)
-		}
+	// 2. Bump requirement. go.mod is edited directly: the tag is not
+	// published yet, the replace resolves it for tidy.
+	if err := gomod.SetRequire(modulePath, version); err != nil {
+		return fmt.Errorf("failed to update requirement in %s: %w", subDir, err)
+	}
+	if err := gomod.Save(); err != nil {
+		return fmt.Errorf("failed to save submodule go.mod: %w", err)
 	}
 
+	// 3. Tidy
+	if _, err := runInDirEnv(subDir, g.goEnv(), "go", "mod", "tidy"); err != nil {
+		return fmt.Errorf("go mod tidy failed in %s: %w", subDir, err)
+	}
 	return nil
 }
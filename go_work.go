@@ -0,0 +1,160 @@
+package devflow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/command"
+	"golang.org/x/mod/modfile"
+)
+
+// findGoWork returns the go.work file governing dir, following the go command's
+// rules: $GOWORK wins when set ("off" disables workspaces), otherwise the
+// nearest go.work in dir or any of its parents. Returns "" when no workspace
+// is active.
+func findGoWork(dir string) string {
+	if env, ok := os.LookupEnv("GOWORK"); ok && env != "" {
+		if env == "off" {
+			return ""
+		}
+		return env
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(abs, "go.work")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return ""
+		}
+		abs = parent
+	}
+}
+
+// parseGoWorkUses returns the absolute module directories listed in the
+// use directives of a go.work file. Relative paths are resolved from the
+// directory containing go.work.
+func parseGoWorkUses(goWorkPath string) ([]string, error) {
+	data, err := os.ReadFile(goWorkPath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseWork(goWorkPath, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	base := filepath.Dir(goWorkPath)
+	var dirs []string
+	for _, use := range f.Use {
+		path := use.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, abs)
+	}
+	return dirs, nil
+}
+
+// workspaceModules returns the use entries of the go.work governing the
+// current module, or nil when no workspace is active.
+func (g *Go) workspaceModules() []string {
+	goWork := findGoWork(g.rootDir)
+	if goWork == "" {
+		return nil
+	}
+	dirs, err := parseGoWorkUses(goWork)
+	if err != nil {
+		g.log("Warning: could not read", goWork, err)
+		return nil
+	}
+	return dirs
+}
+
+// workspaceModulesIn returns the workspace modules inside searchPath, or nil
+// when no workspace is active. The use entries replace the walk of
+// searchPath, so a dependent search still stays within it.
+func (g *Go) workspaceModulesIn(searchPath string) []string {
+	uses := g.workspaceModules()
+	if uses == nil {
+		return nil
+	}
+	absSearch, _ := filepath.Abs(searchPath)
+	dirs := []string{}
+	for _, dir := range uses {
+		if rel, err := filepath.Rel(absSearch, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// inWorkspace reports whether dir is one of the workspace modules, i.e. the go
+// command resolves it locally without a replace directive.
+func (g *Go) inWorkspace(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for _, use := range g.workspaceModules() {
+		if use == abs {
+			return true
+		}
+	}
+	return false
+}
+
+// runInDirModuleMode runs name in dir with env and GOWORK=off, so go.mod and
+// go.sum are computed for the module alone — exactly as consumers of the
+// published version will see them — even when a go.work is active.
+func runInDirModuleMode(dir string, env []string, name string, args ...string) (string, error) {
+	if env == nil {
+		env = os.Environ()
+	}
+	return runInDirEnv(dir, append(env, "GOWORK=off"), name, args...)
+}
+
+// runInDirEnv runs name in dir with the environment env (nil: the process
+// environment).
+func runInDirEnv(dir string, env []string, name string, args ...string) (string, error) {
+	cmd := command.Exec(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		return output, fmt.Errorf("%s %s in %s: %w: %s", name, strings.Join(args, " "), dir, err, output)
+	}
+	return output, nil
+}
+
+// runWithRetryModuleMode is runInDirModuleMode retried like command.RunWithRetry.
+func runWithRetryModuleMode(dir string, env []string, name string, args []string, attempts int, delay time.Duration) (string, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var output string
+	var err error
+	for i := 0; i < attempts; i++ {
+		if output, err = runInDirModuleMode(dir, env, name, args...); err == nil {
+			return output, nil
+		}
+		if i < attempts-1 {
+			time.Sleep(delay)
+		}
+	}
+	return output, err
+}
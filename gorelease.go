@@ -1,27 +1,34 @@
 package devflow
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"github.com/tinywasm/command"
 	gitmod "github.com/tinywasm/git"
-	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // ReleaseOnly creates a gitmod.GitHub Release with cross-platform binaries for an existing tag.
 // If tag is empty, it uses the latest tag from git.GetLatestTag().
 func (g *Go) ReleaseOnly(tag string, gh *gitmod.GitHub) error {
 	// 1. Resolve tag first
-	if tag == "" {
-		var err error
-		tag, err = g.git.GetLatestTag()
-		if err != nil {
-			return fmt.Errorf("failed to get latest tag: %w", err)
-		}
-		if tag == "" {
-			return fmt.Errorf("no tags found in repository")
+	tag, err := g.releaseTag(tag)
+	if err != nil {
+		return err
+	}
+
+	// 1b. With signing configured the release must point at the signed tag,
+	// already pushed: gh would otherwise create a lightweight one.
+	if g.signing != nil {
+		if err := g.checkReleaseTag(tag); err != nil {
+			if g.signing.Required {
+				return fmt.Errorf("refusing to release %s: %w", tag, err)
+			}
+			g.consoleOutput(fmt.Sprintf("⚠ %v", err))
 		}
 	}
 
@@ -37,12 +44,19 @@ func (g *Go) ReleaseOnly(tag string, gh *gitmod.GitHub) error {
 	}
 
 	// 3. List cmd/ directories before starting
-	cmds, err := g.listCmdDirs(g.rootDir)
+	cmds, release, err := g.releaseCommands()
 	if err != nil {
 		return err
 	}
-	if len(cmds) == 0 {
-		return fmt.Errorf("no cmd/ found in %s", g.rootDir)
+
+	// 3b. A missing signing key must fail before anything is built
+	var key *ReleaseKey
+	if release.Sign {
+		k, err := LoadReleaseKey(g.secrets)
+		if err != nil {
+			return err
+		}
+		key = &k
 	}
 
 	// 3. Create temp directory for artifacts
@@ -52,61 +66,223 @@ func (g *Go) ReleaseOnly(tag string, gh *gitmod.GitHub) error {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// 4. Cross-compile
-	var assets []string
-	if g.crossCompileFn != nil {
-		assets, err = g.crossCompileFn(tmpDir, cmds, DefaultTargets(), g.rootDir)
-	} else {
-		// Pass tag to CrossCompile for version injection
-		assets, err = g.CrossCompileWithTag(tmpDir, cmds, DefaultTargets(), g.rootDir, tag)
+	// 3c. Repository install.sh downloads from
+	repo := target
+	if repo == "" {
+		if owner, name, _, err := gh.RepoInfo(""); err == nil {
+			repo = owner + "/" + name
+		} else {
+			repo = g.originRepo()
+		}
 	}
 
+	// 4-5. Binaries, archives, SBOMs, images, install.sh, checksums, signature and provenance
+	artifacts, err := g.releaseArtifacts(tmpDir, filepath.Join(tmpDir, "dist"), cmds, tag, release, key, repo)
 	if err != nil {
-		return fmt.Errorf("cross-compilation failed: %w", err)
+		return err
 	}
 
-	// 5. Generate Checksums
-	checksumsPath := filepath.Join(tmpDir, "checksums.txt")
-	f, err := os.Create(checksumsPath)
+	// 6. Create gitmod.GitHub Release
+	url, err := gh.CreateRelease(tag, artifacts.Assets, target)
 	if err != nil {
-		return fmt.Errorf("failed to create checksums.txt: %w", err)
+		return fmt.Errorf("failed to create gitmod.GitHub release: %w", err)
 	}
-	for _, asset := range assets {
-		h := sha256.New()
-		af, err := os.Open(asset)
-		if err != nil {
-			f.Close()
-			return fmt.Errorf("failed to open asset for checksum: %w", err)
+
+	// 7. Release notes: CreateRelease publishes an empty body, so the
+	// changelog section (and the wasm sizes) is set right after. A release
+	// left without them fails the step.
+	if notes := g.releaseNotes(tag, artifacts); notes != "" {
+		args := []string{"release", "edit", tag, "--notes", notes}
+		if target != "" {
+			args = append(args, "--repo", target)
 		}
-		if _, err := io.Copy(h, af); err != nil {
-			af.Close()
-			f.Close()
-			return fmt.Errorf("failed to calculate checksum: %w", err)
+		if _, err := githubRunner(gh).Run("gh", args...); err != nil {
+			return fmt.Errorf("release %s published, but setting its notes failed: %w", tag, err)
 		}
-		af.Close()
-		fmt.Fprintf(f, "%x  %s\n", h.Sum(nil), filepath.Base(asset))
 	}
-	f.Close()
+
+	g.consoleOutput(fmt.Sprintf("✅ Release → %s", url))
+
+	// 8. Container images, tagged once the release exists
+	if release.Image != nil {
+		if err := g.PushImages(artifacts.Images, tag, *release.Image); err != nil {
+			return fmt.Errorf("release %s published, but: %w", tag, err)
+		}
+	}
+
+	// 9. Homebrew tap and Scoop bucket, pointing at the published assets
+	if err := g.updateTaps(gh, repo, tag, release, artifacts); err != nil {
+		return fmt.Errorf("release %s published, but: %w", tag, err)
+	}
+	return nil
+}
+
+// releaseNotes returns the release notes of tag followed by the sizes of
+// its wasm builds.
+func (g *Go) releaseNotes(tag string, files releaseFiles) string {
+	notes := g.ReleaseNotes(tag)
+	if report := wasmSizeReport(files.Wasm); report != "" {
+		notes = strings.TrimSpace(notes + "\n\n" + report)
+	}
+	return notes
+}
+
+// releaseTag returns tag, the latest tag when empty.
+func (g *Go) releaseTag(tag string) (string, error) {
+	if tag != "" {
+		return tag, nil
+	}
+	tag, err := g.git.GetLatestTag()
+	if err != nil {
+		return "", fmt.Errorf("failed to get latest tag: %w", err)
+	}
+	if tag == "" {
+		return "", fmt.Errorf("no tags found in repository")
+	}
+	return tag, nil
+}
+
+// releaseCommands returns the commands of cmd/ and the release config,
+// checked against them. A repository without cmd/ only releases its wasm
+// builds.
+func (g *Go) releaseCommands() ([]string, ReleaseConfig, error) {
+	cmds, err := g.listCmdDirs(g.rootDir)
+	if err != nil {
+		return nil, ReleaseConfig{}, err
+	}
+	release, err := LoadReleaseConfig(g.rootDir)
+	if err != nil {
+		return nil, ReleaseConfig{}, err
+	}
+	if len(cmds) == 0 {
+		if release.Wasm == nil {
+			return nil, ReleaseConfig{}, fmt.Errorf("no cmd/ found in %s", g.rootDir)
+		}
+		if release.Image != nil || release.Homebrew != nil || release.Scoop != nil {
+			return nil, ReleaseConfig{}, fmt.Errorf("no cmd/ found in %s: image, homebrew and scoop need commands to release", g.rootDir)
+		}
+	}
+	if err := release.checkCommands(cmds); err != nil {
+		return nil, ReleaseConfig{}, err
+	}
+	return cmds, release, nil
+}
+
+// releaseFiles are the files of a release build.
+type releaseFiles struct {
+	Binaries []string // the cross-compiled binaries
+	Assets   []string // the published files: archives, SBOMs, images, wasm, checksums.txt, its signature, provenance
+	Images   []ReleaseImage
+	Wasm     []WasmArtifact
+}
+
+// releaseArtifacts builds the binaries of cmds into buildDir and writes the
+// release assets into distDir. checksums.txt is signed with key unless nil;
+// install.sh downloads from the GitHub repository repo unless "".
+func (g *Go) releaseArtifacts(buildDir, distDir string, cmds []string, tag string, release ReleaseConfig, key *ReleaseKey, repo string) (releaseFiles, error) {
+	if err := g.checkReleaseSource(tag); err != nil {
+		return releaseFiles{}, fmt.Errorf("refusing to build %s: %w", tag, err)
+	}
+
+	// 4. Cross-compile with the targets and flags of each command, then
+	// package each binary with LICENSE/README: .tar.gz, .zip on windows,
+	// and describe its modules in an SBOM
+	started := time.Now()
+	files, err := g.buildArtifacts(buildDir, distDir, cmds, tag, release)
+	if err != nil {
+		return releaseFiles{}, err
+	}
+	binaries, assets := files.Binaries, files.Assets
+
+	// 4b. install.sh for the archives: it checks them against checksums.txt
+	if release.InstallEnabled() && len(binaries) > 0 {
+		if repo == "" {
+			g.log("Warning: install.sh skipped, origin is not a GitHub repository")
+		} else {
+			script, err := writeInstallScript(distDir, repo, tag, binaries, assets[:len(binaries)])
+			if err != nil {
+				return releaseFiles{}, err
+			}
+			assets = append(assets, script)
+		}
+	}
+
+	// 5. Generate Checksums
+	checksumsPath := filepath.Join(distDir, "checksums.txt")
+	digests, err := writeChecksums(checksumsPath, assets)
+	if err != nil {
+		return releaseFiles{}, err
+	}
 	assets = append(assets, checksumsPath)
+	if key != nil {
+		sigPath, err := key.signChecksums(checksumsPath, g.sourceDate(tag))
+		if err != nil {
+			return releaseFiles{}, fmt.Errorf("failed to sign checksums.txt: %w", err)
+		}
+		assets = append(assets, sigPath)
+	}
 
-	// 6. Create gitmod.GitHub Release
-	url, err := gh.CreateRelease(tag, assets, target)
+	// 5b. Provenance: the builds behind the checksummed assets
+	provenancePath := filepath.Join(distDir, ProvenanceFile)
+	if err := g.writeProvenance(provenancePath, tag, cmds, release, digests, started); err != nil {
+		return releaseFiles{}, fmt.Errorf("failed to write provenance: %w", err)
+	}
+	assets = append(assets, provenancePath)
+
+	files.Assets = assets
+	return files, nil
+}
+
+// buildArtifacts cross-compiles cmds into buildDir and packages the
+// binaries, with an SBOM each, the images and the wasm builds in distDir.
+func (g *Go) buildArtifacts(buildDir, distDir string, cmds []string, tag string, release ReleaseConfig) (releaseFiles, error) {
+	binaries, err := g.BuildRelease(buildDir, cmds, tag, release)
 	if err != nil {
-		return fmt.Errorf("failed to create gitmod.GitHub release: %w", err)
+		return releaseFiles{}, fmt.Errorf("cross-compilation failed: %w", err)
+	}
+	assets, err := g.PackageArchives(distDir, binaries, tag, release.Archive)
+	if err != nil {
+		return releaseFiles{}, fmt.Errorf("packaging failed: %w", err)
+	}
+	if release.SBOM != "none" {
+		sboms, err := g.WriteSBOMs(distDir, binaries, assets, tag)
+		if err != nil {
+			return releaseFiles{}, fmt.Errorf("sbom generation failed: %w", err)
+		}
+		assets = append(slices.Clip(assets), sboms...)
+	}
+	images, err := g.BuildImages(filepath.Join(distDir, "oci"), distDir, binaries, tag, release)
+	if err != nil {
+		return releaseFiles{}, fmt.Errorf("image build failed: %w", err)
+	}
+	for _, img := range images {
+		assets = append(slices.Clip(assets), img.Tarball)
 	}
+	wasm, wasmAssets, err := g.BuildWasm(distDir, tag, release)
+	if err != nil {
+		return releaseFiles{}, fmt.Errorf("wasm build failed: %w", err)
+	}
+	assets = append(slices.Clip(assets), wasmAssets...)
+	return releaseFiles{Binaries: binaries, Assets: assets, Images: images, Wasm: wasm}, nil
+}
 
-	g.consoleOutput(fmt.Sprintf("✅ Release → %s", url))
-	return nil
+// githubRunner returns the runner gh uses for its own commands, so extra gh
+// invocations share its authentication (and its fakes in tests).
+func githubRunner(gh *gitmod.GitHub) gitmod.Runner {
+	if gh != nil && gh.SecretRunner != nil {
+		return gh.SecretRunner
+	}
+	return gitmod.RealRunner{}
 }
 
 // DefaultTargets returns the standard set of platforms for release
 func DefaultTargets() []CrossTarget {
 	return []CrossTarget{
-		{"linux", "amd64"},
-		{"linux", "arm64"},
-		{"darwin", "arm64"},
-		{"darwin", "amd64"},
-		{"windows", "amd64"},
+		{GOOS: "linux", GOARCH: "amd64"},
+		{GOOS: "linux", GOARCH: "arm64"},
+		{GOOS: "darwin", GOARCH: "arm64"},
+		{GOOS: "darwin", GOARCH: "amd64"},
+		{GOOS: "windows", GOARCH: "amd64"},
 	}
 }
 
@@ -135,51 +311,35 @@ func (g *Go) resolvePublishTarget(folderName string, gh *gitmod.GitHub) (string,
 	return candidate, nil
 }
 
-func crossBuildArgs(tag, cmd, outputPath string) []string {
-	ldflags := "-s -w"
-	if tag != "" {
-		ldflags += fmt.Sprintf(" -X main.Version=%s", tag)
-	}
-
-	return []string{
+func crossBuildArgs(cmd, outputPath, ldflags string, tags []string, vcs bool) []string {
+	args := []string{
 		"build",
 		"-o", outputPath,
 		"-trimpath",
-		"-ldflags=" + ldflags,
-		"./cmd/" + cmd,
+		"-buildvcs=" + strconv.FormatBool(vcs),
+	}
+	if len(tags) > 0 {
+		args = append(args, "-tags="+strings.Join(tags, ","))
 	}
+	return append(args, "-ldflags="+ldflags, "./cmd/"+cmd)
 }
 
 // CrossCompileWithTag builds the specified commands for multiple platforms with version injection
 func (g *Go) CrossCompileWithTag(tmpDir string, cmds []string, targets []CrossTarget, repoDir, tag string) ([]string, error) {
 	var assets []string
+	date := g.sourceDate(tag)
+	vcs := isGitCheckout(repoDir)
 
 	for _, target := range targets {
 		for _, cmd := range cmds {
-			name := cmd
-			suffix := fmt.Sprintf("-%s-%s", target.GOOS, target.GOARCH)
-			if target.GOOS == "windows" {
-				suffix += ".exe"
+			b, err := newReleaseBuild(cmd, target, BuildConfig{}, ldflagsData{Version: tag}, vcs, date)
+			if err != nil {
+				return nil, err
 			}
-			outputName := name + suffix
-			outputPath := filepath.Join(tmpDir, outputName)
-
-			// Use crossBuildArgs for versioning and optimization flags
-			args := crossBuildArgs(tag, cmd, outputPath)
-			buildCmd := command.Exec("go", args...)
-			buildCmd.Dir = repoDir
-			buildCmd.Env = append(os.Environ(),
-				"CGO_ENABLED=0",
-				"GOOS="+target.GOOS,
-				"GOARCH="+target.GOARCH,
-			)
-
-			outputBytes, err := buildCmd.CombinedOutput()
+			outputPath, err := crossBuild(tmpDir, repoDir, b, date)
 			if err != nil {
-				return nil, fmt.Errorf("failed to build %s for %s/%s: %w\nOutput: %s",
-					cmd, target.GOOS, target.GOARCH, err, string(outputBytes))
+				return nil, err
 			}
-
 			assets = append(assets, outputPath)
 		}
 	}
@@ -187,6 +347,27 @@ func (g *Go) CrossCompileWithTag(tmpDir string, cmds []string, targets []CrossTa
 	return assets, nil
 }
 
+// crossBuild runs b in repoDir, writing its binary into tmpDir with mtime as
+// modification time, and returns the binary path.
+func crossBuild(tmpDir, repoDir string, b ReleaseBuild, mtime time.Time) (string, error) {
+	outputPath := filepath.Join(tmpDir, b.Binary())
+
+	// Use crossBuildArgs for versioning and optimization flags
+	buildCmd := command.Exec("go", b.Args(outputPath)...)
+	buildCmd.Dir = repoDir
+	buildCmd.Env = append(os.Environ(), b.Env...)
+
+	outputBytes, err := buildCmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to build %s for %s: %w\nOutput: %s",
+			b.Cmd, b.Target, err, string(outputBytes))
+	}
+	if err := os.Chtimes(outputPath, mtime, mtime); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
 // CrossCompile builds the specified commands for multiple platforms
 func CrossCompile(tmpDir string, cmds []string, targets []CrossTarget, repoDir string) ([]string, error) {
 	g := &Go{}
@@ -97,7 +97,7 @@ func (g *Go) runFullTestSuite(moduleName string, skipRace bool, timeoutSec int,
 			vetArgs = append(vetArgs, "-tags=integration")
 		}
 		vetArgs = append(vetArgs, "./...")
-		vetOutput, vetErr = command.RunInDir(g.rootDir, "go", vetArgs...)
+		vetOutput, vetErr = runInDirEnv(g.rootDir, g.goEnv(), "go", vetArgs...)
 	}()
 
 	// Check for WASM test files (async)
@@ -114,6 +114,7 @@ func (g *Go) runFullTestSuite(moduleName string, skipRace bool, timeoutSec int,
 		nativeArgs = append(nativeArgs, "./...")
 		nativeCmd := exec.Command("go", nativeArgs...)
 		nativeCmd.Dir = g.rootDir
+		nativeCmd.Env = g.goEnv()
 		nativeOut, _ := nativeCmd.CombinedOutput()
 
 		// 2. Get WASM test files
@@ -124,8 +125,7 @@ func (g *Go) runFullTestSuite(moduleName string, skipRace bool, timeoutSec int,
 		wasmArgs = append(wasmArgs, "./...")
 		wasmCmd := exec.Command("go", wasmArgs...)
 		wasmCmd.Dir = g.rootDir
-		wasmCmd.Env = os.Environ()
-		wasmCmd.Env = append(wasmCmd.Env, "GOOS=js", "GOARCH=wasm")
+		wasmCmd.Env = g.goEnv("GOOS=js", "GOARCH=wasm")
 		wasmOut, _ := wasmCmd.CombinedOutput()
 
 		// 3. Decision logic
@@ -205,6 +205,7 @@ func (g *Go) runFullTestSuite(moduleName string, skipRace bool, timeoutSec int,
 	defer wd.Stop()
 
 	testCmd := GoTestCmdFn(testCtx, g.rootDir, "go", testArgs...)
+	testCmd.Env = g.goEnv()
 
 	testBuffer := &bytes.Buffer{}
 
@@ -247,6 +248,7 @@ func (g *Go) runFullTestSuite(moduleName string, skipRace bool, timeoutSec int,
 		wd.Start()
 
 		subCmd := GoTestCmdFn(subCtx, subDir, "go", subArgs...)
+		subCmd.Env = g.goEnv()
 		subCmd.Stdout = testPipe
 		subCmd.Stderr = testPipe
 		if err := subCmd.Run(); err != nil && testErr == nil {
@@ -315,8 +317,7 @@ func (g *Go) runFullTestSuite(moduleName string, skipRace bool, timeoutSec int,
 			wasmCtx, wasmCancel := context.WithTimeout(context.Background(), g.wasmTimeout(timeoutSec))
 			defer wasmCancel()
 			wasmCmd := GoTestCmdFn(wasmCtx, g.rootDir, "go", testArgs...)
-			wasmCmd.Env = os.Environ()
-			wasmCmd.Env = append(wasmCmd.Env, "GOOS=js", "GOARCH=wasm")
+			wasmCmd.Env = g.goEnv("GOOS=js", "GOARCH=wasm")
 
 			var wasmOut bytes.Buffer
 
@@ -471,6 +472,7 @@ func (g *Go) runCustomTests(customArgs []string, moduleName string, timeoutSec i
 		nativeArgs = append(nativeArgs, "./...")
 		nativeCmd := exec.Command("go", nativeArgs...)
 		nativeCmd.Dir = g.rootDir
+		nativeCmd.Env = g.goEnv()
 		nativeOut, _ := nativeCmd.CombinedOutput()
 
 		wasmArgs := []string{"list", "-f", "{{.ImportPath}} {{.TestGoFiles}} {{.XTestGoFiles}}"}
@@ -480,8 +482,7 @@ func (g *Go) runCustomTests(customArgs []string, moduleName string, timeoutSec i
 		wasmArgs = append(wasmArgs, "./...")
 		wasmCmd := exec.Command("go", wasmArgs...)
 		wasmCmd.Dir = g.rootDir
-		wasmCmd.Env = os.Environ()
-		wasmCmd.Env = append(wasmCmd.Env, "GOOS=js", "GOARCH=wasm")
+		wasmCmd.Env = g.goEnv("GOOS=js", "GOARCH=wasm")
 		wasmOut, _ := wasmCmd.CombinedOutput()
 
 		enableWasmTests = ShouldEnableWasm(string(nativeOut), string(wasmOut))
@@ -532,6 +533,7 @@ func (g *Go) runCustomTests(customArgs []string, moduleName string, timeoutSec i
 	defer wd.Stop()
 
 	testCmd := GoTestCmdFn(customCtx, g.rootDir, "go", testArgs...)
+	testCmd.Env = g.goEnv()
 	testBuffer := &bytes.Buffer{}
 
 	// CRITICAL: Keep ConsoleFilter for clean output
@@ -569,6 +571,7 @@ func (g *Go) runCustomTests(customArgs []string, moduleName string, timeoutSec i
 		wd.Start()
 
 		subCmd := GoTestCmdFn(subCtx, subDir, "go", subArgs...)
+		subCmd.Env = g.goEnv()
 		subCmd.Stdout = testPipe
 		subCmd.Stderr = testPipe
 		if err := subCmd.Run(); err != nil && testErr == nil {
@@ -674,8 +677,7 @@ func (g *Go) runCustomTests(customArgs []string, moduleName string, timeoutSec i
 			wasmCtx, wasmCancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec+10)*time.Second)
 			defer wasmCancel()
 			wasmCmd := GoTestCmdFn(wasmCtx, g.rootDir, "go", wasmTestArgs...)
-			wasmCmd.Env = os.Environ()
-			wasmCmd.Env = append(wasmCmd.Env, "GOOS=js", "GOARCH=wasm")
+			wasmCmd.Env = g.goEnv("GOOS=js", "GOARCH=wasm")
 
 			var wasmOut bytes.Buffer
 			wasmFilter := NewConsoleFilter(g.consoleOutput)
@@ -910,7 +912,7 @@ func (g *Go) installWasmBrowserTest() error {
 		return nil
 	}
 
-	_, err := command.RunInDir(g.rootDir, "go", "install", "github.com/tinywasm/wasmbrowsertest@latest")
+	_, err := runInDirEnv(g.rootDir, g.goEnv(), "go", "install", "github.com/tinywasm/wasmbrowsertest@latest")
 	if err != nil {
 		return fmt.Errorf("go install failed: %w", err)
 	}
@@ -937,7 +939,7 @@ func (g *Go) wasmTestPackages(runAll bool) []string {
 
 	cmd := exec.Command("go", args...)
 	cmd.Dir = g.rootDir
-	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	cmd.Env = g.goEnv("GOOS=js", "GOARCH=wasm")
 	out, _ := cmd.Output() // stderr carries the excluded packages: expected, not fatal
 
 	pkgs := ParseWasmTestPackages(string(out))
@@ -1222,7 +1224,7 @@ func (g *Go) findWasmTimeoutCulprit(timeoutSec int) []string {
 	for _, name := range names {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
 		cmd := GoTestCmdFn(ctx, g.rootDir, "go", "test", "-exec", "wasmbrowsertest", "-run", "^"+name+"$", "-v", "./...")
-		cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+		cmd.Env = g.goEnv("GOOS=js", "GOARCH=wasm")
 		cmd.Stdout = nil
 		cmd.Stderr = nil
 		cmd.Run()
@@ -1240,7 +1242,7 @@ func (g *Go) discoverWasmTestNames() []string {
 		`{{range .TestGoFiles}}{{$.Dir}}/{{.}} {{end}}{{range .XTestGoFiles}}{{$.Dir}}/{{.}} {{end}}`,
 		"./...")
 	listCmd.Dir = g.rootDir
-	listCmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	listCmd.Env = g.goEnv("GOOS=js", "GOARCH=wasm")
 	out, err := listCmd.Output()
 	if err != nil {
 		return nil
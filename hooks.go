@@ -0,0 +1,187 @@
+package devflow
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/tinywasm/command"
+)
+
+// HooksFile is the optional hooks declaration in .devflow/.
+const HooksFile = "hooks.json"
+
+// HookPoint names a step of the Push pipeline where hooks run.
+type HookPoint string
+
+const (
+	HookPreVerify   HookPoint = "pre-verify"   // before go mod verify
+	HookPostTest    HookPoint = "post-test"    // after the tests (also when skipped)
+	HookPreTag      HookPoint = "pre-tag"      // before the release commit and tag: generated files are committed
+	HookPostPush    HookPoint = "post-push"    // after the push and the binaries install
+	HookPostCascade HookPoint = "post-cascade" // after the dependents were updated
+)
+
+// HookPoints lists the hook points in pipeline order.
+var HookPoints = []HookPoint{HookPreVerify, HookPostTest, HookPreTag, HookPostPush, HookPostCascade}
+
+// Hook is a project-specific step. Exactly one of Run (a shell command), Go
+// (a Go package run with `go run`) or Fn (in-process, see AddHook) is set.
+type Hook struct {
+	Name string                                `json:"name,omitempty"`
+	Run  string                                `json:"run,omitempty"`
+	Go   string                                `json:"go,omitempty"`
+	Fn   func(ctx HookContext) (string, error) `json:"-"`
+}
+
+// HookContext describes the push a hook runs for. Commands receive it as
+// DEVFLOW_HOOK, DEVFLOW_MODULE, DEVFLOW_TAG and DEVFLOW_MESSAGE.
+type HookContext struct {
+	Point      HookPoint
+	RootDir    string
+	ModulePath string
+	Tag        string // tag about to be created (pre-tag) or created; "" when unknown
+	Message    string
+}
+
+// HooksConfig maps hook points to their hooks, as declared in
+// .devflow/hooks.json:
+//
+//	{
+//	  "pre-tag": [{"run": "go generate ./..."}, {"name": "openapi", "go": "./tools/openapi"}],
+//	  "post-push": [{"run": "make deploy-docs"}]
+//	}
+type HooksConfig map[HookPoint][]Hook
+
+// LoadHooks reads rootDir/.devflow/hooks.json. A missing file means no hooks.
+func LoadHooks(rootDir string) (HooksConfig, error) {
+	var cfg HooksConfig
+	if _, err := readDevflowConfig(rootDir, HooksFile, &cfg); err != nil {
+		return nil, err
+	}
+	for point, hooks := range cfg {
+		if !validHookPoint(point) {
+			return nil, fmt.Errorf("%s/%s: unknown hook point %q (expected one of %v)", DevflowConfigDir, HooksFile, point, HookPoints)
+		}
+		for _, h := range hooks {
+			if (h.Run == "") == (h.Go == "") {
+				return nil, fmt.Errorf("%s/%s: %s hook must set exactly one of \"run\" or \"go\"", DevflowConfigDir, HooksFile, point)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func validHookPoint(point HookPoint) bool {
+	for _, p := range HookPoints {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
+
+// AddHook registers an in-process hook, run after the hooks declared in
+// .devflow/hooks.json for the same point.
+func (g *Go) AddHook(point HookPoint, hook Hook) {
+	if g.hooks == nil {
+		g.hooks = HooksConfig{}
+	}
+	g.hooks[point] = append(g.hooks[point], hook)
+}
+
+// runHooks runs the hooks of point in order and returns one summary entry
+// per hook. A failing pre-* hook stops the run and returns its error, which
+// aborts the push; a failing post-* hook is only reported, the release is
+// already out.
+func (g *Go) runHooks(point HookPoint, ctx HookContext) ([]string, error) {
+	declared, err := LoadHooks(g.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	hooks := append(declared[point], g.hooks[point]...)
+	ctx.Point, ctx.RootDir = point, g.rootDir
+
+	var summary []string
+	for _, h := range hooks {
+		name := h.displayName()
+		output, err := g.runHook(h, ctx)
+		if err != nil {
+			if strings.HasPrefix(string(point), "pre-") {
+				return summary, fmt.Errorf("%s hook %q failed: %w", point, name, err)
+			}
+			summary = append(summary, fmt.Sprintf("⚠ %s hook %s failed: %v", point, name, err))
+			continue
+		}
+		entry := fmt.Sprintf("🪝 %s ✅", name)
+		if line := lastLine(output); line != "" {
+			entry = fmt.Sprintf("🪝 %s: %s", name, line)
+		}
+		summary = append(summary, entry)
+	}
+	return summary, nil
+}
+
+func (g *Go) runHook(h Hook, ctx HookContext) (string, error) {
+	if h.Fn != nil {
+		return h.Fn(ctx)
+	}
+
+	name, args := "go", []string{"run", h.Go}
+	if h.Run != "" {
+		name, args = shellArgs(h.Run)
+	}
+	cmd := command.Exec(name, args...)
+	cmd.Dir = g.rootDir
+	cmd.Env = g.goEnv(append(g.signingVars(),
+		"DEVFLOW_HOOK="+string(ctx.Point),
+		"DEVFLOW_MODULE="+ctx.ModulePath,
+		"DEVFLOW_TAG="+ctx.Tag,
+		"DEVFLOW_MESSAGE="+ctx.Message,
+	)...)
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if line := lastLine(output); line != "" {
+			return output, fmt.Errorf("%w: %s", err, line)
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+func (h Hook) displayName() string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.Run != "":
+		return h.Run
+	case h.Go != "":
+		return "go run " + h.Go
+	}
+	return "hook"
+}
+
+// shellArgs returns the command running line through the platform shell.
+func shellArgs(line string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/C", line}
+	}
+	return "sh", []string{"-c", line}
+}
+
+// lastLine returns the last non-empty line of output.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// runPostHooks is runHooks for post-* points: every problem is a summary
+// entry, never an error.
+func (g *Go) runPostHooks(point HookPoint, ctx HookContext) []string {
+	summary, err := g.runHooks(point, ctx)
+	if err != nil {
+		summary = append(summary, fmt.Sprintf("⚠ %s hooks: %v", point, err))
+	}
+	return summary
+}
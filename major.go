@@ -0,0 +1,388 @@
+package devflow
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// majorSuffixRe matches the /vN suffix of a v2+ module path.
+var majorSuffixRe = regexp.MustCompile(`/v(\d+)$`)
+
+// MajorPath returns modulePath moved to major version n (n >= 2):
+// "example.com/lib" → "example.com/lib/v2", "example.com/lib/v2" → "example.com/lib/v3".
+func MajorPath(modulePath string, n int) string {
+	return majorSuffixRe.ReplaceAllString(modulePath, "") + fmt.Sprintf("/v%d", n)
+}
+
+// MajorMigration describes a /vN module path rewrite done by MigrateMajor.
+type MajorMigration struct {
+	OldPath string
+	NewPath string
+	Tag     string // vN.0.0
+	Files   int    // .go files whose imports were rewritten
+}
+
+// MigrateMajor moves the module to the next major version path: it rewrites
+// the module directive, every import of the module inside it and inside its
+// internal submodules, and the submodules' requirement on it. Nothing is
+// committed; the returned Tag is meant for Push, which then also migrates the
+// dependents' imports (see MigrateDependents). If MigrateMajor, or the Push
+// that follows, fails before the release commit, the edited files are
+// restored.
+func (g *Go) MigrateMajor() (mig MajorMigration, err error) {
+	oldPath, err := g.GetModulePath()
+	if err != nil {
+		return MajorMigration{}, err
+	}
+
+	g.majorOriginals = map[string][]byte{}
+	g.majorHead, _ = command.RunInDir(g.rootDir, "git", "rev-parse", "HEAD")
+	defer func() {
+		if err != nil {
+			g.restoreMajorMigration()
+		}
+	}()
+
+	latest, _ := g.git.GetLatestTag()
+	tagMajor, _ := semverMajorMinor(latest)
+	if latest == "" || tagMajor == 0 {
+		return MajorMigration{}, fmt.Errorf("latest tag %q is v0: v1.0.0 needs no module path change, tag it explicitly", latest)
+	}
+	n := tagMajor + 1
+	if m := majorSuffixRe.FindStringSubmatch(oldPath); m != nil {
+		if pathMajor, _ := strconv.Atoi(m[1]); pathMajor+1 > n {
+			n = pathMajor + 1
+		}
+	}
+	mig = MajorMigration{OldPath: oldPath, NewPath: MajorPath(oldPath, n), Tag: fmt.Sprintf("v%d.0.0", n)}
+
+	gomod := NewGoModHandler()
+	gomod.SetRootDir(g.rootDir)
+	if err := gomod.SetModulePath(mig.NewPath); err != nil {
+		return mig, fmt.Errorf("failed to rewrite module directive: %w", err)
+	}
+	g.saveMajorOriginal(filepath.Join(g.rootDir, "go.mod"))
+	if err := gomod.Save(); err != nil {
+		return mig, fmt.Errorf("failed to save go.mod: %w", err)
+	}
+
+	submods, err := nestedModules(g.rootDir)
+	if err != nil {
+		return mig, err
+	}
+	var keep []string // submodule paths keep their own identity
+	for _, dir := range submods {
+		sub := NewGoModHandler()
+		sub.SetRootDir(dir)
+		if p, err := sub.GetModulePath(); err == nil {
+			keep = append(keep, p)
+		}
+	}
+
+	originals, err := rewriteImports(g.rootDir, oldPath, mig.NewPath, keep)
+	for path, data := range originals {
+		g.majorOriginals[path] = data
+	}
+	if err != nil {
+		return mig, err
+	}
+	mig.Files = len(originals)
+
+	for _, dir := range submods {
+		if !g.HasDependency(filepath.Join(dir, "go.mod"), oldPath) {
+			continue
+		}
+		// The replace for the new path is added by syncInternalSubmodules
+		// when Push runs.
+		sub := NewGoModHandler()
+		sub.SetRootDir(dir)
+		err := sub.DropRequire(oldPath)
+		if err == nil {
+			err = sub.DropReplace(oldPath)
+		}
+		if err == nil {
+			err = sub.SetRequire(mig.NewPath, mig.Tag)
+		}
+		if err == nil {
+			g.saveMajorOriginal(filepath.Join(dir, "go.mod"))
+			err = sub.Save()
+		}
+		if err != nil {
+			return mig, fmt.Errorf("failed to move %s to %s: %w", dir, mig.NewPath, err)
+		}
+	}
+
+	g.migratedFrom = oldPath
+	g.consoleOutput(fmt.Sprintf("🔀 %s → %s (%d files)", oldPath, mig.NewPath, mig.Files))
+	return mig, nil
+}
+
+// saveMajorOriginal keeps the content of path before MigrateMajor edits it.
+func (g *Go) saveMajorOriginal(path string) {
+	if _, ok := g.majorOriginals[path]; ok {
+		return
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		g.majorOriginals[path] = data
+	}
+}
+
+// restoreMajorMigration writes back the files edited by MigrateMajor, unless
+// the migration was already committed.
+func (g *Go) restoreMajorMigration() {
+	originals := g.majorOriginals
+	g.majorOriginals = nil
+	if originals == nil {
+		return
+	}
+	if head, _ := command.RunInDir(g.rootDir, "git", "rev-parse", "HEAD"); head != g.majorHead {
+		return
+	}
+	for path, data := range originals {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			g.log("Warning: could not restore", path, err)
+		}
+	}
+	g.migratedFrom = ""
+	g.consoleOutput(fmt.Sprintf("↩ major migration undone (%d files restored)", len(originals)))
+}
+
+// RewriteImports rewrites the imports of oldPath and its packages to newPath
+// in every .go file under dir, using go/ast so only import specs change.
+// Imports of the modules listed in keep (nested modules under oldPath) are
+// left alone. Like the go tool, testdata, vendor and directories or files
+// starting with "_" or "." are ignored. Returns the number of files changed.
+func RewriteImports(dir, oldPath, newPath string, keep []string) (int, error) {
+	originals, err := rewriteImports(dir, oldPath, newPath, keep)
+	return len(originals), err
+}
+
+// rewriteImports is RewriteImports returning the previous content of each
+// file it changed.
+func rewriteImports(dir, oldPath, newPath string, keep []string) (map[string][]byte, error) {
+	originals := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if path != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir && (name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		original, err := rewriteFileImports(path, oldPath, newPath, keep)
+		if err != nil {
+			return err
+		}
+		if original != nil {
+			originals[path] = original
+		}
+		return nil
+	})
+	return originals, err
+}
+
+// rewriteFileImports rewrites the imports of one file and returns its
+// previous content, nil when nothing changed.
+func rewriteFileImports(path, oldPath, newPath string, keep []string) ([]byte, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	// Only the import path literals are replaced, the rest of the file is
+	// kept byte for byte. Imports are in source order: splice from the end.
+	out := src
+	changed := false
+	for i := len(file.Imports) - 1; i >= 0; i-- {
+		lit := file.Imports[i].Path
+		ipath, err := strconv.Unquote(lit.Value)
+		if err != nil || (ipath != oldPath && !strings.HasPrefix(ipath, oldPath+"/")) {
+			continue
+		}
+		if underAny(ipath, keep) {
+			continue
+		}
+		start, end := fset.Position(lit.Pos()).Offset, fset.Position(lit.End()).Offset
+		quoted := strconv.Quote(newPath + strings.TrimPrefix(ipath, oldPath))
+		out = append(append(append([]byte{}, out[:start]...), quoted...), out[end:]...)
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+	return src, os.WriteFile(path, out, 0644)
+}
+
+// underAny reports whether importPath belongs to one of the module paths.
+func underAny(importPath string, modules []string) bool {
+	for _, m := range modules {
+		if importPath == m || strings.HasPrefix(importPath, m+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedModules returns the directories below root holding their own go.mod.
+func nestedModules(root string) ([]string, error) {
+	absRoot, _ := filepath.Abs(root)
+	var dirs []string
+	err := filepath.Walk(absRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() && info.Name() == "go.mod" && filepath.Dir(path) != absRoot {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// MigrateDependents moves the local dependents of oldPath to newPath@version:
+// their imports are rewritten, the requirement is swapped, and each one is
+// tested and published like a regular cascade bump. Dependents with an active
+// codejob session or uncommitted work are skipped: the migration edits source
+// files, not only go.mod. The dependents of the published ones then get the
+// regular cascade (see RunCascade).
+func (g *Go) MigrateDependents(oldPath, newPath, version, rootCause, searchPath string) CascadeReport {
+	report := CascadeReport{}
+	dependents, err := g.FindDependentModules(oldPath, searchPath)
+	if err != nil {
+		report.Entries = append(report.Entries, CascadeEntry{ModulePath: oldPath, Status: CascadeStatusFailed, Detail: err.Error()})
+		return report
+	}
+	if len(dependents) == 0 {
+		return report
+	}
+	if err := g.WaitForVersionAvailable(newPath, version); err != nil {
+		report.Entries = append(report.Entries, CascadeEntry{ModulePath: newPath, Status: CascadeStatusFailed, Detail: err.Error()})
+		return report
+	}
+
+	g.consoleOutput(fmt.Sprintf("🔀 Migrating %d dependents to %s...", len(dependents), newPath))
+	published := map[string]string{newPath: version}
+	done := map[string]bool{}
+	for _, dir := range dependents {
+		name := dependentDisplayName(dir)
+		gomod := NewGoModHandler()
+		gomod.SetRootDir(dir)
+		modulePath, _ := gomod.GetModulePath()
+		done[modulePath] = true
+
+		outcome, err := g.migrateDependent(dir, oldPath, newPath, version, rootCause)
+		entry := CascadeEntry{ModulePath: name, Status: outcome.Status, Detail: outcome.Reason}
+		if err != nil {
+			entry.Status, entry.Detail = CascadeStatusFailed, err.Error()
+		} else if outcome.Status == CascadeStatusPublished {
+			entry.Detail = outcome.Version
+			published[modulePath] = outcome.Version
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	// Modules depending on a migrated dependent get the new version of it
+	if len(published) > 1 {
+		next := g.runCascade(newPath, published, done, rootCause, searchPath)
+		report.Entries = append(report.Entries, next.Entries...)
+	}
+	g.printCascadeReport(report)
+	return report
+}
+
+func (g *Go) migrateDependent(dir, oldPath, newPath, version, rootCause string) (CascadeOutcome, error) {
+	depName := dependentDisplayName(dir)
+
+	git, err := gitmod.NewGit()
+	if err != nil {
+		return g.reportFail(depName, fmt.Errorf("git init failed: %w", err))
+	}
+	git.SetRootDir(dir)
+
+	gomod := NewGoModHandler()
+	gomod.SetRootDir(dir)
+	objectors := append([]gitmod.PublishObjector{gomod, git, CodeJob{}}, g.extraPublishObjectors...)
+	ctx := gitmod.PublishContext{RepoDir: dir, ModulePaths: []string{oldPath}}
+	if action, reason := gitmod.ResolvePublishAction(objectors, ctx); action != gitmod.ActionNone {
+		g.consoleOutput(fmt.Sprintf("📦 %s → skip (%s) ⏭", depName, reason))
+		return CascadeOutcome{Status: CascadeStatusSkipped, Reason: reason}, nil
+	}
+
+	// The tree was clean: on failure every file the migration touched is restored.
+	success := false
+	defer func() {
+		if !success {
+			command.RunInDir(dir, "git", "checkout", "--", ".")
+		}
+	}()
+
+	if _, err := RewriteImports(dir, oldPath, newPath, nil); err != nil {
+		return g.reportFail(depName, err)
+	}
+	err = gomod.DropRequire(oldPath)
+	if err == nil {
+		err = gomod.DropReplace(oldPath)
+	}
+	if err == nil {
+		err = gomod.SetRequire(newPath, version)
+	}
+	if err == nil {
+		err = gomod.Save()
+	}
+	if err != nil {
+		return g.reportFail(depName, err)
+	}
+	if _, err := runWithRetryModuleMode(dir, g.goEnv(), "go", []string{"mod", "tidy"}, g.retryAttempts, g.retryDelay); err != nil {
+		return g.reportFail(depName, fmt.Errorf("go mod tidy failed after retries: %w", err))
+	}
+	if output, err := runInDirModuleMode(dir, g.goEnv(), "gotest", "-t", "60", "-no-cache"); err != nil {
+		g.consoleOutput(fmt.Sprintf("📦 %s → %s ❌", depName, extractFirstFailure(output)))
+		return CascadeOutcome{}, fmt.Errorf("tests failed: %w", err)
+	}
+
+	depHandler, err := NewGo(git)
+	if err != nil {
+		return g.reportFail(depName, fmt.Errorf("go handler init failed: %w", err))
+	}
+	depHandler.SetRootDir(dir)
+	depHandler.SetSkipGenerateCheck(true) // the gate is for the pushed module
+	g.inheritSigning(depHandler)
+	g.inheritProxy(depHandler)
+	commitMsg := gitmod.BuildDepsCommitMessage([]gitmod.DepBump{{ModulePath: newPath, NewVersion: version}}, rootCause)
+	pushRes, err := depHandler.Push(commitMsg, "", true, true, true, true, false, false, "")
+	if err != nil {
+		g.consoleOutput(fmt.Sprintf("📦 %s → ❌ push failed", depName))
+		return CascadeOutcome{}, fmt.Errorf("push failed: %w", err)
+	}
+
+	g.recordDependent(dir, pushRes.Tag)
+	g.consoleOutput(fmt.Sprintf("📦 %s → migrated to %s ✅", depName, newPath))
+	success = true
+	return CascadeOutcome{Status: CascadeStatusPublished, Version: pushRes.Tag}, nil
+}
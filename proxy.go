@@ -0,0 +1,251 @@
+package devflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/command"
+	keyring "github.com/tinywasm/keyring/auto"
+	"golang.org/x/mod/module"
+	modzip "golang.org/x/mod/zip"
+)
+
+// ProxyFile is the optional module proxy configuration in .devflow/.
+const ProxyFile = "proxy.json"
+
+// ProxyTokenName is the entry of the upload token in the devflow keyring;
+// the ProxyTokenEnv variable takes precedence (CI).
+const ProxyTokenName = "PROXY_TOKEN"
+
+// ProxyTokenEnv holds the upload token when set.
+const ProxyTokenEnv = "DEVFLOW_PROXY_TOKEN"
+
+// ProxyConfig points Push and the cascade at a private module proxy:
+//
+//	{"goproxy": "https://athens.example.com", "goprivate": "example.com/*",
+//	 "upload": "https://athens.example.com/upload"}
+//
+// The go variables are passed to every go command devflow runs (a variable
+// already set in the environment wins). Upload, when set, receives the module
+// files (.info, .mod, .zip) of each release: a file:// directory laid out
+// like a GOPROXY, or an http(s) endpoint accepting
+// PUT <upload>/<module>/@v/<version>.<ext>, authenticated with the token of
+// ProxyTokenEnv or of the devflow keyring when there is one.
+type ProxyConfig struct {
+	GOPROXY   string `json:"goproxy,omitempty"`
+	GOPRIVATE string `json:"goprivate,omitempty"`
+	GONOPROXY string `json:"gonoproxy,omitempty"`
+	GONOSUMDB string `json:"gonosumdb,omitempty"`
+	Upload    string `json:"upload,omitempty"`
+}
+
+// LoadProxyConfig reads rootDir/.devflow/proxy.json. It returns nil (and no
+// error) when the file does not exist: the go environment is used as is.
+func LoadProxyConfig(rootDir string) (*ProxyConfig, error) {
+	var cfg ProxyConfig
+	found, err := readDevflowConfig(rootDir, ProxyFile, &cfg)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// SetProxy sets the module proxy configuration (nil: Push reads
+// .devflow/proxy.json, the go environment is used as is without it).
+// Dependents published by the cascade inherit it. The upload token is
+// read from the store set by SetSecretStore.
+func (g *Go) SetProxy(cfg *ProxyConfig) {
+	g.proxy = cfg
+}
+
+// env returns the go variables of c not already set in the environment.
+func (c *ProxyConfig) env() [][2]string {
+	var vars [][2]string
+	for _, kv := range [][2]string{
+		{"GOPROXY", c.GOPROXY},
+		{"GOPRIVATE", c.GOPRIVATE},
+		{"GONOPROXY", c.GONOPROXY},
+		{"GONOSUMDB", c.GONOSUMDB},
+	} {
+		if _, set := os.LookupEnv(kv[0]); kv[1] != "" && !set {
+			vars = append(vars, kv)
+		}
+	}
+	return vars
+}
+
+// proxyVars returns the proxy configuration as NAME=value entries.
+func (g *Go) proxyVars() []string {
+	if g.proxy == nil {
+		return nil
+	}
+	var vars []string
+	for _, kv := range g.proxy.env() {
+		vars = append(vars, kv[0]+"="+kv[1])
+	}
+	return vars
+}
+
+// goEnv returns the environment of the go commands devflow runs: the process
+// environment, the proxy configuration and extra. The process environment
+// itself is never changed.
+func (g *Go) goEnv(extra ...string) []string {
+	return append(append(os.Environ(), g.proxyVars()...), extra...)
+}
+
+// proxyCommand prefixes a go command run through a gitmod.Runner, which only
+// takes a command line, with the proxy configuration (env NAME=value go ...).
+func (g *Go) proxyCommand(name string, args ...string) (string, []string) {
+	vars := g.proxyVars()
+	if len(vars) == 0 {
+		return name, args
+	}
+	return "env", append(append(vars, name), args...)
+}
+
+// inheritProxy passes the proxy configuration and the store of its upload
+// token to a dependent handler.
+func (g *Go) inheritProxy(dep *Go) {
+	if g.proxy != nil {
+		dep.proxy = g.proxy
+		dep.secrets = g.secrets
+	}
+}
+
+// proxyToken returns the upload token from ProxyTokenEnv or, when unset, from
+// the store set by SetSecretStore or the devflow keyring ("" when there is
+// none).
+func (g *Go) proxyToken() string {
+	if token := os.Getenv(ProxyTokenEnv); token != "" {
+		return token
+	}
+	store := g.secrets
+	if store == nil {
+		store = keyring.OpenKeyring("devflow")
+	}
+	token, _ := store.Get(ProxyTokenName)
+	return strings.TrimSpace(token)
+}
+
+// UploadToProxy sends modulePath@version, as tagged in the repository at
+// dir, to the configured upload endpoint. tag is the git tag of the
+// version (prefixed for submodules).
+func (g *Go) UploadToProxy(dir, modulePath, version, tag string) error {
+	if g.proxy == nil || g.proxy.Upload == "" {
+		return nil
+	}
+	files, err := moduleProxyFiles(dir, modulePath, version, tag)
+	if err != nil {
+		return err
+	}
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return err
+	}
+
+	if root, ok := strings.CutPrefix(g.proxy.Upload, "file://"); ok {
+		return writeProxyFiles(filepath.Join(filepath.FromSlash(root), escaped, "@v"), version, files)
+	}
+
+	token := g.proxyToken()
+	client := &http.Client{Timeout: 2 * time.Minute}
+	for _, ext := range []string{"mod", "zip", "info"} { // .info last: it makes the version visible
+		url := fmt.Sprintf("%s/%s/@v/%s.%s", strings.TrimSuffix(g.proxy.Upload, "/"), escaped, version, ext)
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(files[ext]))
+		if err != nil {
+			return err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("upload %s: %w", url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("upload %s: %s", url, resp.Status)
+		}
+	}
+	return nil
+}
+
+// moduleProxyFiles builds the .info, .mod and .zip files a GOPROXY serves
+// for the version tagged tag, from the repository and not the working tree.
+func moduleProxyFiles(dir, modulePath, version, tag string) (map[string][]byte, error) {
+	top, err := command.RunInDir(dir, "git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+	subdir, _ := command.RunInDir(dir, "git", "rev-parse", "--show-prefix")
+	subdir = strings.TrimSuffix(subdir, "/")
+
+	commitTime, err := command.RunInDir(dir, "git", "log", "-1", "--format=%cI", tag)
+	if err != nil {
+		return nil, fmt.Errorf("tag %s not found: %w", tag, err)
+	}
+	t, err := time.Parse(time.RFC3339, commitTime)
+	if err != nil {
+		return nil, err
+	}
+	info, _ := json.Marshal(struct {
+		Version string
+		Time    time.Time
+	}{version, t.UTC()})
+
+	gomod, err := command.RunInDir(dir, "git", "show", tag+":"+filepath.ToSlash(filepath.Join(subdir, "go.mod")))
+	if err != nil {
+		return nil, fmt.Errorf("go.mod at %s: %w", tag, err)
+	}
+
+	var zip bytes.Buffer
+	if err := modzip.CreateFromVCS(&zip, module.Version{Path: modulePath, Version: version}, top, tag, subdir); err != nil {
+		return nil, fmt.Errorf("module zip: %w", err)
+	}
+	return map[string][]byte{"info": info, "mod": []byte(gomod + "\n"), "zip": zip.Bytes()}, nil
+}
+
+// writeProxyFiles stores the files of version in dir (a file:// proxy's
+// <module>/@v directory) and lists the version.
+func writeProxyFiles(dir, version string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, ext := range []string{"mod", "zip", "info"} {
+		if err := os.WriteFile(filepath.Join(dir, version+"."+ext), files[ext], 0644); err != nil {
+			return err
+		}
+	}
+
+	listPath := filepath.Join(dir, "list")
+	list, _ := os.ReadFile(listPath)
+	for _, v := range strings.Fields(string(list)) {
+		if v == version {
+			return nil
+		}
+	}
+	return os.WriteFile(listPath, append(list, []byte(version+"\n")...), 0644)
+}
+
+// uploadRelease uploads the root release and the submodule releases when an
+// upload endpoint is configured and returns the summary entry.
+func (g *Go) uploadRelease(modulePath, tag string, submodules []SubmoduleRelease) string {
+	if g.proxy == nil || g.proxy.Upload == "" || tag == "" {
+		return ""
+	}
+	if err := g.UploadToProxy(g.rootDir, modulePath, tag, tag); err != nil {
+		return fmt.Sprintf("Warning: proxy upload failed: %v", err)
+	}
+	for _, r := range submodules {
+		if err := g.UploadToProxy(r.Dir, r.ModulePath, r.Next, r.Tag()); err != nil {
+			return fmt.Sprintf("Warning: proxy upload of %s failed: %v", r.Tag(), err)
+		}
+	}
+	return "📡 uploaded to " + g.proxy.Upload
+}
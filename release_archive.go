@@ -0,0 +1,246 @@
+package devflow
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tinywasm/command"
+)
+
+// DefaultArchiveName is the archive name template: the binary name of the
+// previous releases, so download links keep their shape.
+const DefaultArchiveName = "{{.Cmd}}-{{.OS}}-{{.Arch}}"
+
+// DefaultArchiveFiles are the repository files packed next to the binary.
+var DefaultArchiveFiles = []string{"LICENSE*", "LICENCE*", "README*"}
+
+// ArchiveConfig configures the release archives: .tar.gz on unix, .zip on
+// windows.
+type ArchiveConfig struct {
+	// Name is a text/template for the archive name without extension, with
+	// .Cmd, .Version, .OS and .Arch (default DefaultArchiveName).
+	Name string `json:"name,omitempty"`
+	// Files are glob patterns, relative to the repository root, of the files
+	// packed with the binary (default DefaultArchiveFiles).
+	Files []string `json:"files,omitempty"`
+	// Format "binary" publishes the bare executables instead of archives.
+	Format string `json:"format,omitempty"`
+}
+
+// ArchiveName holds the fields of an archive name template.
+type ArchiveName struct {
+	Cmd, Version, OS, Arch string
+}
+
+func (c ArchiveConfig) validate() error {
+	switch c.Format {
+	case "", "archive", "binary":
+	default:
+		return fmt.Errorf("%s/%s: unknown archive format %q (expected \"archive\" or \"binary\")", DevflowConfigDir, ReleaseFile, c.Format)
+	}
+	if _, err := c.template(); err != nil {
+		return fmt.Errorf("%s/%s: archive name: %w", DevflowConfigDir, ReleaseFile, err)
+	}
+	for _, pattern := range c.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s/%s: archive file pattern %q: %w", DevflowConfigDir, ReleaseFile, pattern, err)
+		}
+	}
+	return nil
+}
+
+func (c ArchiveConfig) template() (*template.Template, error) {
+	name := c.Name
+	if name == "" {
+		name = DefaultArchiveName
+	}
+	return template.New("archive").Option("missingkey=error").Parse(name)
+}
+
+// PackageArchives packs each cross-compiled binary (named
+// <cmd>-<os>-<arch>[.exe|.wasm], see BuildRelease) with the configured
+// files into outDir and returns the archive paths. The archives are
+// reproducible: entries are sorted, owned by root, with fixed modes and the
+// tag's commit time as modification time.
+func (g *Go) PackageArchives(outDir string, binaries []string, tag string, cfg ArchiveConfig) ([]string, error) {
+	if cfg.Format == "binary" {
+		return binaries, nil
+	}
+	tmpl, err := cfg.template()
+	if err != nil {
+		return nil, err
+	}
+	extras, err := archiveFiles(g.rootDir, cfg.Files)
+	if err != nil {
+		return nil, err
+	}
+	mtime := g.sourceDate(tag)
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+	var archives []string
+	for _, bin := range binaries {
+		fields, ext, ok := parseBinaryName(filepath.Base(bin))
+		if !ok {
+			return nil, fmt.Errorf("cannot tell the target of %s: expected <cmd>-<os>-<arch>", filepath.Base(bin))
+		}
+		fields.Version = tag
+
+		var name strings.Builder
+		if err := tmpl.Execute(&name, fields); err != nil {
+			return nil, fmt.Errorf("archive name: %w", err)
+		}
+
+		entries := append([]archiveEntry{{Name: fields.Cmd + ext, Path: bin, Mode: 0755}}, extras...)
+
+		var archive string
+		if fields.OS == "windows" {
+			archive = filepath.Join(outDir, name.String()+".zip")
+			err = writeZip(archive, entries, mtime)
+		} else {
+			archive = filepath.Join(outDir, name.String()+".tar.gz")
+			err = writeTarGz(archive, entries, mtime)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", filepath.Base(archive), err)
+		}
+		archives = append(archives, archive)
+	}
+	return archives, nil
+}
+
+// parseBinaryName splits "<cmd>-<os>-<arch>[.exe|.wasm]" and returns the
+// extension apart; cmd may contain dashes.
+func parseBinaryName(name string) (ArchiveName, string, bool) {
+	ext := filepath.Ext(name)
+	if ext != ".exe" && ext != ".wasm" {
+		ext = ""
+	}
+	parts := strings.Split(strings.TrimSuffix(name, ext), "-")
+	if len(parts) < 3 {
+		return ArchiveName{}, "", false
+	}
+	n := len(parts)
+	return ArchiveName{Cmd: strings.Join(parts[:n-2], "-"), OS: parts[n-2], Arch: parts[n-1]}, ext, true
+}
+
+type archiveEntry struct {
+	Name string // path inside the archive
+	Path string // file on disk
+	Mode int64
+}
+
+// archiveFiles resolves the file patterns against rootDir, sorted and
+// without duplicates.
+func archiveFiles(rootDir string, patterns []string) ([]archiveEntry, error) {
+	if patterns == nil {
+		patterns = DefaultArchiveFiles
+	}
+	seen := map[string]bool{}
+	var entries []archiveEntry
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(rootDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil || info.IsDir() || seen[m] {
+				continue
+			}
+			seen[m] = true
+			rel, _ := filepath.Rel(rootDir, m)
+			entries = append(entries, archiveEntry{Name: filepath.ToSlash(rel), Path: m, Mode: 0644})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// sourceDate returns the timestamp of the archive entries: SOURCE_DATE_EPOCH
+// when set, else the commit time of tag, else the zip epoch (1980-01-01).
+func (g *Go) sourceDate(tag string) time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+	if tag != "" {
+		if out, err := command.RunInDir(g.rootDir, "git", "log", "-1", "--format=%ct", tag); err == nil {
+			if sec, err := strconv.ParseInt(out, 10, 64); err == nil {
+				return time.Unix(sec, 0).UTC()
+			}
+		}
+	}
+	return time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func writeTarGz(path string, entries []archiveEntry, mtime time.Time) error {
+	var buf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression) // no name, zero header time
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		data, err := os.ReadFile(e.Path)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     e.Name,
+			Mode:     e.Mode,
+			Size:     int64(len(data)),
+			ModTime:  mtime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func writeZip(path string, entries []archiveEntry, mtime time.Time) error {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.Name, Method: zip.Deflate, Modified: mtime}
+		hdr.SetMode(os.FileMode(e.Mode))
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(e.Path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
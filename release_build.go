@@ -0,0 +1,383 @@
+package devflow
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tinywasm/command"
+	"github.com/tinywasm/devflow/selfupdate"
+)
+
+// BuildConfig configures how gorelease builds a command:
+//
+//	{"targets": ["linux/amd64", "linux/arm/7", "wasip1/wasm"],
+//	 "ldflags": ["-X main.Commit={{.Commit}}", "-X main.Date={{.Date}}"],
+//	 "tags": ["netgo"], "cgo": false, "env": ["GOEXPERIMENT=rangefunc"]}
+type BuildConfig struct {
+	// Targets are "os/arch" or "os/arch/variant" (variant: GOARM, GOAMD64...).
+	// Default DefaultTargets.
+	Targets []string `json:"targets,omitempty"`
+	// Ldflags are added to "-s -w -X main.Version=<tag>". They are templates
+	// with .Cmd, .Version, .Commit, .Date (RFC 3339, the tag's commit time),
+	// .OS and .Arch.
+	Ldflags []string `json:"ldflags,omitempty"`
+	// Tags are the build tags.
+	Tags []string `json:"tags,omitempty"`
+	// CGO enables cgo (CGO_ENABLED=1); builds are static by default.
+	CGO *bool `json:"cgo,omitempty"`
+	// Env are extra KEY=VALUE variables of the build, e.g. CC for cgo.
+	Env []string `json:"env,omitempty"`
+}
+
+// ldflagsData holds the fields of the ldflags templates.
+type ldflagsData struct {
+	Cmd, Version, Commit, Date, OS, Arch string
+}
+
+// variantEnv maps a GOARCH to the variable selecting its sub-architecture.
+var variantEnv = map[string]string{
+	"386":      "GO386",
+	"amd64":    "GOAMD64",
+	"arm":      "GOARM",
+	"arm64":    "GOARM64",
+	"mips":     "GOMIPS",
+	"mipsle":   "GOMIPS",
+	"mips64":   "GOMIPS64",
+	"mips64le": "GOMIPS64",
+	"ppc64":    "GOPPC64",
+	"ppc64le":  "GOPPC64",
+	"riscv64":  "GORISCV64",
+	"wasm":     "GOWASM",
+}
+
+// ParseCrossTarget parses "os/arch" or "os/arch/variant", e.g. "linux/arm/7".
+func ParseCrossTarget(s string) (CrossTarget, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return CrossTarget{}, fmt.Errorf("invalid target %q: expected os/arch or os/arch/variant", s)
+	}
+	t := CrossTarget{GOOS: parts[0], GOARCH: parts[1]}
+	if len(parts) == 3 {
+		if _, ok := variantEnv[t.GOARCH]; !ok {
+			return CrossTarget{}, fmt.Errorf("invalid target %q: %s has no variants", s, t.GOARCH)
+		}
+		t.Variant = parts[2]
+	}
+	return t, nil
+}
+
+func (t CrossTarget) String() string {
+	if t.Variant != "" {
+		return t.GOOS + "/" + t.GOARCH + "/" + t.Variant
+	}
+	return t.GOOS + "/" + t.GOARCH
+}
+
+// archName is the architecture in asset names: "armv7", "amd64v3". The
+// naming is shared with selfupdate, which picks assets by it.
+func (t CrossTarget) archName() string {
+	return selfupdate.Target(t).ArchName()
+}
+
+// assetSuffix is the suffix of the binary name: "-linux-armv7",
+// "-windows-amd64.exe", "-wasip1-wasm.wasm".
+func (t CrossTarget) assetSuffix() string {
+	return "-" + t.GOOS + "-" + t.archName() + selfupdate.Target(t).BinaryExt()
+}
+
+// CrossTargets returns the parsed targets of b, DefaultTargets when unset.
+func (b BuildConfig) CrossTargets() ([]CrossTarget, error) {
+	if len(b.Targets) == 0 {
+		return DefaultTargets(), nil
+	}
+	var targets []CrossTarget
+	for _, s := range b.Targets {
+		t, err := ParseCrossTarget(s)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func (b BuildConfig) validate(where string) error {
+	if _, err := b.CrossTargets(); err != nil {
+		return fmt.Errorf("%s/%s: %s: %w", DevflowConfigDir, ReleaseFile, where, err)
+	}
+	for _, flag := range b.Ldflags {
+		t, err := template.New("ldflags").Parse(flag)
+		if err == nil {
+			err = t.Execute(&strings.Builder{}, ldflagsData{})
+		}
+		if err != nil {
+			return fmt.Errorf("%s/%s: %s: ldflags %q: %w", DevflowConfigDir, ReleaseFile, where, flag, err)
+		}
+	}
+	for _, kv := range b.Env {
+		if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+			return fmt.Errorf("%s/%s: %s: env %q: expected KEY=VALUE", DevflowConfigDir, ReleaseFile, where, kv)
+		}
+	}
+	return nil
+}
+
+// linkFlags returns the linker flags of a build past the stripping "-s -w":
+// the version injected into main.Version, then the configured flags.
+func (b BuildConfig) linkFlags(data ldflagsData) ([]string, error) {
+	var flags []string
+	if data.Version != "" {
+		flags = append(flags, "-X", "main.Version="+data.Version)
+	}
+	for _, flag := range b.Ldflags {
+		t, err := template.New("ldflags").Parse(flag)
+		if err != nil {
+			return nil, err
+		}
+		var out strings.Builder
+		if err := t.Execute(&out, data); err != nil {
+			return nil, err
+		}
+		flags = append(flags, out.String())
+	}
+	return flags, nil
+}
+
+// env returns the variables of a build for target, after os.Environ.
+func (b BuildConfig) env(target CrossTarget) []string {
+	cgo := "0"
+	if b.CGO != nil && *b.CGO {
+		cgo = "1"
+	}
+	env := []string{"CGO_ENABLED=" + cgo, "GOOS=" + target.GOOS, "GOARCH=" + target.GOARCH}
+	if target.Variant != "" {
+		env = append(env, variantEnv[target.GOARCH]+"="+target.Variant)
+	}
+	return append(env, b.Env...)
+}
+
+// BuildFor returns the build configuration of cmd: the per-command settings
+// replace the defaults they set; env variables are added to the defaults.
+func (c ReleaseConfig) BuildFor(cmd string) BuildConfig {
+	b := c.Build
+	o, ok := c.Commands[cmd]
+	if !ok {
+		return b
+	}
+	if o.Targets != nil {
+		b.Targets = o.Targets
+	}
+	if o.Ldflags != nil {
+		b.Ldflags = o.Ldflags
+	}
+	if o.Tags != nil {
+		b.Tags = o.Tags
+	}
+	if o.CGO != nil {
+		b.CGO = o.CGO
+	}
+	b.Env = append(append([]string(nil), b.Env...), o.Env...)
+	return b
+}
+
+// checkCommands rejects per-command settings of commands that are not in cmd/.
+func (c ReleaseConfig) checkCommands(cmds []string) error {
+	var unknown []string
+	for name := range c.Commands {
+		if !slices.Contains(cmds, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if c.Image != nil {
+		for _, name := range c.Image.Commands {
+			if !slices.Contains(cmds, name) && !slices.Contains(unknown, name) {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s/%s: unknown commands %s (cmd/ has %s)", DevflowConfigDir, ReleaseFile, strings.Join(unknown, ", "), strings.Join(cmds, ", "))
+	}
+	return nil
+}
+
+// BuildRelease cross-compiles cmds into tmpDir with the targets and flags cfg
+// sets for each of them and returns the binaries.
+func (g *Go) BuildRelease(tmpDir string, cmds []string, tag string, cfg ReleaseConfig) ([]string, error) {
+	if g.crossCompileFn != nil {
+		// Commands sharing their targets are compiled together.
+		var order []string
+		groups := map[string][]string{}
+		targetsOf := map[string][]CrossTarget{}
+		for _, cmd := range cmds {
+			targets, err := cfg.BuildFor(cmd).CrossTargets()
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprint(targets)
+			if _, ok := groups[key]; !ok {
+				order = append(order, key)
+				targetsOf[key] = targets
+			}
+			groups[key] = append(groups[key], cmd)
+		}
+		var assets []string
+		for _, key := range order {
+			built, err := g.crossCompileFn(tmpDir, groups[key], targetsOf[key], g.rootDir)
+			if err != nil {
+				return nil, err
+			}
+			assets = append(assets, built...)
+		}
+		return assets, nil
+	}
+
+	builds, err := g.releaseBuilds(cmds, tag, cfg)
+	if err != nil {
+		return nil, err
+	}
+	mtime := g.sourceDate(tag)
+	var assets []string
+	for _, b := range builds {
+		path, err := crossBuild(tmpDir, g.rootDir, b, mtime)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, path)
+	}
+	return assets, nil
+}
+
+// ReleaseBuild is one go build of a release: a command for a target with the
+// flags and variables of its BuildConfig.
+type ReleaseBuild struct {
+	Cmd     string
+	Target  CrossTarget
+	Ldflags string
+	// LinkFlags are the Ldflags past "-s -w", for TinyGo, which strips
+	// by itself.
+	LinkFlags string
+	Tags      []string
+	VCS       bool     // stamp the VCS state into the binary (-buildvcs)
+	Env       []string // set over the environment of the builder
+}
+
+// Binary is the file name of the build: <cmd>-<os>-<arch>[.exe|.wasm].
+func (b ReleaseBuild) Binary() string {
+	return b.Cmd + b.Target.assetSuffix()
+}
+
+// Args are the go command arguments writing the binary to outputPath.
+func (b ReleaseBuild) Args(outputPath string) []string {
+	return crossBuildArgs(b.Cmd, outputPath, b.Ldflags, b.Tags, b.VCS)
+}
+
+// newReleaseBuild resolves the flags of cmd for target. SOURCE_DATE_EPOCH is
+// set for the toolchains that honor it (cgo); build.Env may override it.
+func newReleaseBuild(cmd string, target CrossTarget, build BuildConfig, data ldflagsData, vcs bool, date time.Time) (ReleaseBuild, error) {
+	data.Cmd, data.OS, data.Arch = cmd, target.GOOS, target.archName()
+	flags, err := build.linkFlags(data)
+	if err != nil {
+		return ReleaseBuild{}, err
+	}
+	ldflags := strings.Join(append([]string{"-s", "-w"}, flags...), " ")
+	env := append([]string{"SOURCE_DATE_EPOCH=" + strconv.FormatInt(date.Unix(), 10)}, build.env(target)...)
+	return ReleaseBuild{Cmd: cmd, Target: target, Ldflags: ldflags, LinkFlags: strings.Join(flags, " "), Tags: build.Tags, VCS: vcs, Env: env}, nil
+}
+
+// releaseBuilds returns the builds of cmds for tag, in the order BuildRelease
+// runs them.
+func (g *Go) releaseBuilds(cmds []string, tag string, cfg ReleaseConfig) ([]ReleaseBuild, error) {
+	date := g.sourceDate(tag)
+	data := ldflagsData{Version: tag, Commit: g.releaseCommit(tag), Date: date.Format(time.RFC3339)}
+	vcs := isGitCheckout(g.rootDir)
+	var builds []ReleaseBuild
+	for _, cmd := range cmds {
+		build := cfg.BuildFor(cmd)
+		targets, err := build.CrossTargets()
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range targets {
+			b, err := newReleaseBuild(cmd, target, build, data, vcs, date)
+			if err != nil {
+				return nil, err
+			}
+			// selfupdate downloads the asset of this target, variant included
+			b.Ldflags += " -X " + selfupdate.TargetSymbol + "=" + target.String()
+			builds = append(builds, b)
+		}
+	}
+	return builds, nil
+}
+
+// isGitCheckout reports whether dir is inside a git work tree: -buildvcs=true
+// fails outside one.
+func isGitCheckout(dir string) bool {
+	out, err := command.RunInDir(dir, "git", "rev-parse", "--is-inside-work-tree")
+	return err == nil && out == "true"
+}
+
+// releaseCommit returns the commit of tag, HEAD when it does not exist.
+func (g *Go) releaseCommit(tag string) string {
+	if tag != "" {
+		if commit, err := command.RunInDir(g.rootDir, "git", "rev-list", "-n", "1", tag); err == nil {
+			return commit
+		}
+	}
+	commit, _ := command.RunInDir(g.rootDir, "git", "rev-parse", "HEAD")
+	return commit
+}
+
+// CheckRelease validates .devflow/release.json against the commands in cmd/
+// and the platforms of the installed go, and returns the build matrix.
+func (g *Go) CheckRelease() (string, error) {
+	cmds, err := g.listCmdDirs(g.rootDir)
+	if err != nil {
+		return "", err
+	}
+	if len(cmds) == 0 {
+		return "", fmt.Errorf("no cmd/ found in %s", g.rootDir)
+	}
+	cfg, err := LoadReleaseConfig(g.rootDir)
+	if err != nil {
+		return "", err
+	}
+	if err := cfg.checkCommands(cmds); err != nil {
+		return "", err
+	}
+
+	var ports []string
+	if out, err := command.RunInDir(os.TempDir(), "go", "tool", "dist", "list"); err == nil {
+		ports = strings.Fields(out)
+	}
+
+	var lines []string
+	for _, cmd := range cmds {
+		build := cfg.BuildFor(cmd)
+		targets, _ := build.CrossTargets()
+		var names []string
+		for _, t := range targets {
+			if ports != nil && !slices.Contains(ports, t.GOOS+"/"+t.GOARCH) {
+				return "", fmt.Errorf("%s: target %s is not supported by go (see go tool dist list)", cmd, t)
+			}
+			names = append(names, t.String())
+		}
+		line := fmt.Sprintf("%s: %s", cmd, strings.Join(names, ", "))
+		if len(build.Tags) > 0 {
+			line += " [tags " + strings.Join(build.Tags, ",") + "]"
+		}
+		if build.CGO != nil && *build.CGO {
+			line += " [cgo]"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,72 @@
+package devflow
+
+import "fmt"
+
+// ReleaseFile is the optional gorelease configuration in .devflow/.
+const ReleaseFile = "release.json"
+
+// ReleaseConfig configures the artifacts gorelease publishes:
+//
+//	{"build": {"ldflags": ["-X main.Commit={{.Commit}}"]},
+//	 "commands": {"agent": {"targets": ["linux/amd64", "linux/arm/7"]}},
+//	 "archive": {"name": "{{.Cmd}}_{{.Version}}_{{.OS}}_{{.Arch}}", "files": ["LICENSE", "docs/*.md"]}}
+type ReleaseConfig struct {
+	Build    BuildConfig            `json:"build,omitempty"`
+	Commands map[string]BuildConfig `json:"commands,omitempty"` // per cmd/ directory, see BuildFor
+	Archive  ArchiveConfig          `json:"archive,omitempty"`
+	// SBOM is "spdx" (default) for an SPDX SBOM per binary, "none" for none.
+	SBOM string `json:"sbom,omitempty"`
+	// Sign signs checksums.txt with the release key of the devflow keyring.
+	Sign bool `json:"sign,omitempty"`
+	// Install publishes install.sh (default true).
+	Install *bool `json:"install,omitempty"`
+	// Homebrew and Scoop update a Homebrew tap and a Scoop bucket with the
+	// released commands.
+	Homebrew *TapConfig `json:"homebrew,omitempty"`
+	Scoop    *TapConfig `json:"scoop,omitempty"`
+	// Image builds OCI images of commands, see ImageConfig.
+	Image *ImageConfig `json:"image,omitempty"`
+	// Wasm builds js/wasm entry points, see WasmConfig.
+	Wasm *WasmConfig `json:"wasm,omitempty"`
+}
+
+// LoadReleaseConfig reads rootDir/.devflow/release.json. A missing file is
+// the zero config: the defaults of every section apply.
+func LoadReleaseConfig(rootDir string) (ReleaseConfig, error) {
+	var cfg ReleaseConfig
+	if _, err := readDevflowConfig(rootDir, ReleaseFile, &cfg); err != nil {
+		return ReleaseConfig{}, err
+	}
+	if err := cfg.Build.validate("build"); err != nil {
+		return ReleaseConfig{}, err
+	}
+	for name, build := range cfg.Commands {
+		if err := build.validate("commands." + name); err != nil {
+			return ReleaseConfig{}, err
+		}
+	}
+	if err := cfg.Archive.validate(); err != nil {
+		return ReleaseConfig{}, err
+	}
+	switch cfg.SBOM {
+	case "", "spdx", "none":
+	default:
+		return ReleaseConfig{}, fmt.Errorf("%s/%s: unknown sbom format %q (expected \"spdx\" or \"none\")", DevflowConfigDir, ReleaseFile, cfg.SBOM)
+	}
+	if cfg.Image != nil {
+		if err := cfg.Image.validate(); err != nil {
+			return ReleaseConfig{}, err
+		}
+	}
+	if cfg.Wasm != nil {
+		if err := cfg.Wasm.validate(); err != nil {
+			return ReleaseConfig{}, err
+		}
+	}
+	for name, tap := range map[string]*TapConfig{"homebrew": cfg.Homebrew, "scoop": cfg.Scoop} {
+		if tap != nil && tap.Repo == "" {
+			return ReleaseConfig{}, fmt.Errorf("%s/%s: %s: repo is required", DevflowConfigDir, ReleaseFile, name)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,534 @@
+package devflow
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// ImageExt is the suffix of the OCI layout tarball of a command's image.
+const ImageExt = ".oci.tar"
+
+// ImageConfig builds a minimal OCI image of commands from their static linux
+// binaries, for linux/amd64, linux/arm64... as their targets:
+//
+//	"image": {"commands": ["server"], "base": "gcr.io/distroless/static:nonroot",
+//	          "repository": "ghcr.io/acme/{{.Cmd}}"}
+type ImageConfig struct {
+	// Commands are the cmd/ directories to containerize.
+	Commands []string `json:"commands"`
+	// Base is "scratch" (default) or an image the binary is layered on, e.g.
+	// "gcr.io/distroless/static:nonroot"; pin it by digest for reproducible images.
+	Base string `json:"base,omitempty"`
+	// Repository is where gorelease pushes the images, a template with .Cmd:
+	// "ghcr.io/acme/{{.Cmd}}", or "oci:<dir>" for an OCI layout directory.
+	// Empty: the images are only published as <cmd>.oci.tar assets.
+	Repository string   `json:"repository,omitempty"`
+	User       string   `json:"user,omitempty"`  // default: the base image's user
+	Env        []string `json:"env,omitempty"`   // KEY=VALUE, added to the base image's
+	Ports      []string `json:"ports,omitempty"` // exposed, e.g. "8080" or "53/udp"
+}
+
+// ReleaseImage is the OCI image of a command.
+type ReleaseImage struct {
+	Cmd     string
+	Layout  string // OCI layout directory, tagged with the release tag and latest
+	Tarball string // Layout as a tar file
+	Digest  string // of the image index, one manifest per platform
+
+	index ociDescriptor
+}
+
+const (
+	ociMediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	ociMediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	ociMediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	ociMediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+	ociRefName           = "org.opencontainers.image.ref.name"
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *ociPlatform      `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+type ociImageConfig struct {
+	Created      string             `json:"created,omitempty"`
+	Architecture string             `json:"architecture"`
+	OS           string             `json:"os"`
+	Variant      string             `json:"variant,omitempty"`
+	Config       ociContainerConfig `json:"config"`
+	RootFS       ociRootFS          `json:"rootfs"`
+}
+
+type ociContainerConfig struct {
+	User         string              `json:"User,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+}
+
+type ociRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+func (c ImageConfig) validate() error {
+	if len(c.Commands) == 0 {
+		return fmt.Errorf("%s/%s: image: commands is required", DevflowConfigDir, ReleaseFile)
+	}
+	if _, err := template.New("repository").Parse(c.Repository); err != nil {
+		return fmt.Errorf("%s/%s: image repository: %w", DevflowConfigDir, ReleaseFile, err)
+	}
+	if len(c.Commands) > 1 && c.Repository != "" && !strings.Contains(c.Repository, "{{") {
+		return fmt.Errorf("%s/%s: image repository %q must contain {{.Cmd}} with several commands", DevflowConfigDir, ReleaseFile, c.Repository)
+	}
+	for _, env := range c.Env {
+		if !strings.Contains(env, "=") {
+			return fmt.Errorf("%s/%s: image env %q is not KEY=VALUE", DevflowConfigDir, ReleaseFile, env)
+		}
+	}
+	return nil
+}
+
+// repository returns the repository the image of cmd is pushed to.
+func (c ImageConfig) repository(cmd string) (string, error) {
+	tmpl, err := template.New("repository").Option("missingkey=error").Parse(c.Repository)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, struct{ Cmd string }{cmd}); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// imageTags returns the tags of the images of a release: the tag, and latest
+// unless it is a prerelease.
+func imageTags(tag string) []string {
+	tags := []string{strings.ReplaceAll(tag, "+", "_")}
+	if semver.Prerelease(tag) == "" {
+		tags = append(tags, "latest")
+	}
+	return tags
+}
+
+// BuildImages assembles the image of each command of release.Image from its
+// linux binaries: an OCI layout in layoutDir/<cmd>, packed into
+// distDir/<cmd>.oci.tar. Layers and configs are timestamped like the
+// archives, so the same binaries give the same image digest.
+func (g *Go) BuildImages(layoutDir, distDir string, binaries []string, tag string, release ReleaseConfig) ([]ReleaseImage, error) {
+	cfg := release.Image
+	if cfg == nil {
+		return nil, nil
+	}
+	date := g.sourceDate(tag)
+	labels := map[string]string{
+		"org.opencontainers.image.version":  tag,
+		"org.opencontainers.image.revision": g.releaseCommit(tag),
+		"org.opencontainers.image.created":  date.UTC().Format(time.RFC3339),
+	}
+	if repo := g.originRepo(); repo != "" {
+		labels["org.opencontainers.image.source"] = "https://github.com/" + repo
+	}
+
+	var base *registryClient
+	var baseRepo, baseRef string
+	if cfg.Base != "" && cfg.Base != "scratch" {
+		host, repo, ref, err := parseImageRef(cfg.Base)
+		if err != nil {
+			return nil, fmt.Errorf("image base: %w", err)
+		}
+		base, baseRepo, baseRef = newRegistryClient(host), repo, ref
+	}
+
+	var images []ReleaseImage
+	for _, cmd := range cfg.Commands {
+		build := release.BuildFor(cmd)
+		if build.CGO != nil && *build.CGO {
+			return nil, fmt.Errorf("image of %s: needs a static binary, but cgo is enabled", cmd)
+		}
+		targets, err := build.CrossTargets()
+		if err != nil {
+			return nil, err
+		}
+
+		layout := ociLayout{dir: filepath.Join(layoutDir, cmd)}
+		var manifests []ociDescriptor
+		for _, t := range targets {
+			if t.GOOS != "linux" {
+				continue
+			}
+			name := cmd + "-linux-" + t.archName()
+			i := slices.IndexFunc(binaries, func(b string) bool { return filepath.Base(b) == name })
+			if i < 0 {
+				return nil, fmt.Errorf("image of %s: %s was not built", cmd, name)
+			}
+			platform := ociPlatform{OS: t.GOOS, Architecture: t.GOARCH, Variant: ociVariant(t)}
+
+			var from ociImageConfig
+			var layers []ociDescriptor
+			if base != nil {
+				from, layers, err = base.pullImage(layout, baseRepo, baseRef, platform)
+				if err != nil {
+					return nil, fmt.Errorf("image base %s for %s: %w", cfg.Base, name, err)
+				}
+			}
+			desc, err := layout.writeImage(cmd, binaries[i], platform, from, layers, *cfg, labels, date)
+			if err != nil {
+				return nil, fmt.Errorf("image of %s: %w", name, err)
+			}
+			manifests = append(manifests, desc)
+		}
+		if len(manifests) == 0 {
+			return nil, fmt.Errorf("image of %s: no linux target", cmd)
+		}
+
+		index, err := layout.writeJSON(ociMediaTypeIndex, ociIndex{SchemaVersion: 2, MediaType: ociMediaTypeIndex, Manifests: manifests})
+		if err != nil {
+			return nil, err
+		}
+		if err := layout.tag(index, imageTags(tag)...); err != nil {
+			return nil, err
+		}
+		tarball := filepath.Join(distDir, cmd+ImageExt)
+		if err := writeLayoutTar(tarball, layout.dir, date); err != nil {
+			return nil, err
+		}
+		images = append(images, ReleaseImage{Cmd: cmd, Layout: layout.dir, Tarball: tarball, Digest: index.Digest, index: index})
+	}
+	return images, nil
+}
+
+// ociVariant returns the OCI platform variant of t: "v7" for linux/arm/7.
+func ociVariant(t CrossTarget) string {
+	switch {
+	case t.Variant == "":
+		return ""
+	case t.Variant[0] >= '0' && t.Variant[0] <= '9':
+		return "v" + t.Variant
+	case t.Variant[0] == 'v':
+		return t.Variant
+	}
+	return ""
+}
+
+// ociLayout is an OCI image layout directory.
+type ociLayout struct {
+	dir string
+}
+
+func (l ociLayout) blobPath(digest string) string {
+	algo, hash, _ := strings.Cut(digest, ":")
+	return filepath.Join(l.dir, "blobs", algo, hash)
+}
+
+// writeBlob stores data and returns its descriptor.
+func (l ociLayout) writeBlob(mediaType string, data []byte) (ociDescriptor, error) {
+	sum := sha256.Sum256(data)
+	desc := ociDescriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	path := l.blobPath(desc.Digest)
+	if _, err := os.Stat(path); err == nil {
+		return desc, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ociDescriptor{}, err
+	}
+	return desc, os.WriteFile(path, data, 0644)
+}
+
+func (l ociLayout) writeJSON(mediaType string, v any) (ociDescriptor, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	return l.writeBlob(mediaType, data)
+}
+
+func (l ociLayout) readBlob(digest string) ([]byte, error) {
+	return os.ReadFile(l.blobPath(digest))
+}
+
+// tag points the refs of index.json at desc, replacing their previous
+// targets.
+func (l ociLayout) tag(desc ociDescriptor, refs ...string) error {
+	var index ociIndex
+	data, err := os.ReadFile(filepath.Join(l.dir, "index.json"))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &index); err != nil {
+			return fmt.Errorf("invalid %s: %w", filepath.Join(l.dir, "index.json"), err)
+		}
+	case os.IsNotExist(err):
+		index = ociIndex{SchemaVersion: 2, MediaType: ociMediaTypeIndex}
+	default:
+		return err
+	}
+	index.Manifests = slices.DeleteFunc(index.Manifests, func(d ociDescriptor) bool {
+		return slices.Contains(refs, d.Annotations[ociRefName])
+	})
+	for _, ref := range refs {
+		d := desc
+		d.Annotations = map[string]string{ociRefName: ref}
+		index.Manifests = append(index.Manifests, d)
+	}
+	if data, err = json.MarshalIndent(index, "", "  "); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(l.dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(l.dir, "index.json"), append(data, '\n'), 0644)
+}
+
+// writeImage writes the image of bin for platform on top of the base image
+// from and its layers, and returns its manifest.
+func (l ociLayout) writeImage(cmd, bin string, platform ociPlatform, from ociImageConfig, layers []ociDescriptor, cfg ImageConfig, labels map[string]string, date time.Time) (ociDescriptor, error) {
+	entrypoint := "/usr/local/bin/" + cmd
+	layer, diffID, err := binaryLayer(bin, entrypoint, date)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	layerDesc, err := l.writeBlob(ociMediaTypeLayer, layer)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+
+	config := ociImageConfig{
+		Created:      date.UTC().Format(time.RFC3339),
+		Architecture: platform.Architecture,
+		OS:           platform.OS,
+		Variant:      platform.Variant,
+		Config:       from.Config,
+		RootFS:       ociRootFS{Type: "layers", DiffIDs: append(slices.Clip(from.RootFS.DiffIDs), diffID)},
+	}
+	config.Config.Entrypoint = []string{entrypoint}
+	config.Config.Cmd = nil
+	if cfg.User != "" {
+		config.Config.User = cfg.User
+	}
+	config.Config.Env = append(slices.Clip(config.Config.Env), cfg.Env...)
+	for _, port := range cfg.Ports {
+		if !strings.Contains(port, "/") {
+			port += "/tcp"
+		}
+		if config.Config.ExposedPorts == nil {
+			config.Config.ExposedPorts = map[string]struct{}{}
+		}
+		config.Config.ExposedPorts[port] = struct{}{}
+	}
+	merged := map[string]string{}
+	for k, v := range config.Config.Labels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	config.Config.Labels = merged
+
+	configDesc, err := l.writeJSON(ociMediaTypeConfig, config)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	desc, err := l.writeJSON(ociMediaTypeManifest, ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociMediaTypeManifest,
+		Config:        configDesc,
+		Layers:        append(slices.Clip(layers), layerDesc),
+	})
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	desc.Platform = &platform
+	return desc, nil
+}
+
+// binaryLayer returns the gzipped layer holding bin at path, and the digest
+// of its uncompressed tar (the image config's diff_id).
+func binaryLayer(bin, path string, date time.Time) ([]byte, string, error) {
+	data, err := os.ReadFile(bin)
+	if err != nil {
+		return nil, "", err
+	}
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	dirs := strings.Split(strings.Trim(filepath.ToSlash(filepath.Dir(path)), "/"), "/")
+	for i := range dirs {
+		hdr := &tar.Header{Typeflag: tar.TypeDir, Name: strings.Join(dirs[:i+1], "/") + "/", Mode: 0755, ModTime: date}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, "", err
+		}
+	}
+	hdr := &tar.Header{Typeflag: tar.TypeReg, Name: strings.TrimPrefix(path, "/"), Mode: 0755, Size: int64(len(data)), ModTime: date}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, "", err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(tarBuf.Bytes())
+
+	var gzBuf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&gzBuf, gzip.BestCompression) // no name, zero header time
+	if _, err := gz.Write(tarBuf.Bytes()); err != nil {
+		return nil, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", err
+	}
+	return gzBuf.Bytes(), "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// writeLayoutTar packs the OCI layout dir into a tar file, as `podman load`
+// and `skopeo copy oci-archive:` read it.
+func writeLayoutTar(path, dir string, date time.Time) error {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, p)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, f)
+		hdr := &tar.Header{Typeflag: tar.TypeReg, Name: filepath.ToSlash(rel), Mode: 0644, Size: int64(len(data)), ModTime: date}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// PushImages publishes the images to cfg.Repository with the release tag and
+// latest: blobs and manifests first, then the tags.
+func (g *Go) PushImages(images []ReleaseImage, tag string, cfg ImageConfig) error {
+	if cfg.Repository == "" {
+		return nil
+	}
+	for _, img := range images {
+		ref, err := cfg.repository(img.Cmd)
+		if err != nil {
+			return err
+		}
+		src := ociLayout{dir: img.Layout}
+		if dir, ok := strings.CutPrefix(ref, "oci:"); ok {
+			err = copyLayout(src, ociLayout{dir: dir}, img.index, imageTags(tag))
+		} else {
+			err = pushLayout(src, ref, img.index, imageTags(tag))
+		}
+		if err != nil {
+			return fmt.Errorf("push of %s to %s failed: %w", img.Cmd, ref, err)
+		}
+		g.consoleOutput(fmt.Sprintf("✅ Image %s:%s (%s)", ref, strings.Join(imageTags(tag), ", "), img.Digest))
+	}
+	return nil
+}
+
+// imageBlobs returns the blobs of the image index, children first: the
+// configs and layers, then the manifests of the platforms.
+func imageBlobs(l ociLayout, index ociDescriptor) ([]ociDescriptor, error) {
+	data, err := l.readBlob(index.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var idx ociIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	var blobs []ociDescriptor
+	for _, m := range idx.Manifests {
+		data, err := l.readBlob(m.Digest)
+		if err != nil {
+			return nil, err
+		}
+		var manifest ociManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, manifest.Config)
+		blobs = append(blobs, manifest.Layers...)
+		blobs = append(blobs, m)
+	}
+	return blobs, nil
+}
+
+// copyLayout copies the image index of src into the layout dst, tagged with tags.
+func copyLayout(src, dst ociLayout, index ociDescriptor, tags []string) error {
+	blobs, err := imageBlobs(src, index)
+	if err != nil {
+		return err
+	}
+	for _, b := range append(blobs, index) {
+		data, err := src.readBlob(b.Digest)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.writeBlob(b.MediaType, data); err != nil {
+			return err
+		}
+	}
+	return dst.tag(index, tags...)
+}
@@ -0,0 +1,161 @@
+package devflow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// InstallScriptFile is the release asset installing the commands with
+// `curl -fsSL .../install.sh | sh`.
+const InstallScriptFile = "install.sh"
+
+// installScript is the template of install.sh. Assets holds one
+// "<cmd> <os> <arch> <asset> <binary>" line per release asset.
+var installScript = template.Must(template.New("install.sh").Parse(`#!/bin/sh
+# Install {{.Cmds}} {{.Tag}} from https://github.com/{{.Repo}}/releases
+#
+#   curl -fsSL https://github.com/{{.Repo}}/releases/download/{{.Tag}}/install.sh | sh
+#   curl -fsSL https://github.com/{{.Repo}}/releases/download/{{.Tag}}/install.sh | sh -s -- <cmd>
+#
+# BIN_DIR sets the install directory (default: $HOME/.local/bin).
+# Every download is checked against the release's checksums.txt.
+set -eu
+
+REPO="{{.Repo}}"
+TAG="{{.Tag}}"
+BIN_DIR="${BIN_DIR:-$HOME/.local/bin}"
+
+# <cmd> <os> <arch> <asset> <binary>
+ASSETS='
+{{- range .Assets}}
+{{.}}
+{{- end}}
+'
+
+fail() {
+	echo "install.sh: $*" >&2
+	exit 1
+}
+
+os=$(uname -s | tr '[:upper:]' '[:lower:]')
+case "$os" in
+mingw* | msys* | cygwin*) os=windows ;;
+esac
+
+arch=$(uname -m)
+case "$arch" in
+x86_64 | amd64) arch=amd64 ;;
+aarch64 | arm64) arch=arm64 ;;
+armv7*) arch=armv7 ;;
+armv6*) arch=armv6 ;;
+i386 | i686) arch=386 ;;
+esac
+
+if command -v curl >/dev/null 2>&1; then
+	download() { curl -fsSL -o "$2" "$1"; }
+elif command -v wget >/dev/null 2>&1; then
+	download() { wget -q -O "$2" "$1"; }
+else
+	fail "curl or wget is required"
+fi
+
+if command -v sha256sum >/dev/null 2>&1; then
+	sha256() { sha256sum "$1" | cut -d' ' -f1; }
+elif command -v shasum >/dev/null 2>&1; then
+	sha256() { shasum -a 256 "$1" | cut -d' ' -f1; }
+else
+	fail "sha256sum or shasum is required"
+fi
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+base="https://github.com/$REPO/releases/download/$TAG"
+download "$base/checksums.txt" "$tmp/checksums.txt"
+
+installed=""
+while read -r cmd a_os a_arch asset bin; do
+	[ -n "$cmd" ] || continue
+	[ "$a_os" = "$os" ] && [ "$a_arch" = "$arch" ] || continue
+	[ $# -eq 0 ] || [ "$cmd" = "$1" ] || continue
+
+	download "$base/$asset" "$tmp/$asset"
+	want=$(awk -v f="$asset" '$2 == f { print $1 }' "$tmp/checksums.txt")
+	[ -n "$want" ] || fail "$asset is not listed in checksums.txt"
+	[ "$(sha256 "$tmp/$asset")" = "$want" ] || fail "$asset does not match checksums.txt"
+
+	mkdir -p "$tmp/$cmd.d"
+	case "$asset" in
+	*.tar.gz) tar -xzf "$tmp/$asset" -C "$tmp/$cmd.d" ;;
+	*.zip) unzip -q -o "$tmp/$asset" -d "$tmp/$cmd.d" ;;
+	*) cp "$tmp/$asset" "$tmp/$cmd.d/$bin" ;;
+	esac
+
+	mkdir -p "$BIN_DIR"
+	cp "$tmp/$cmd.d/$bin" "$BIN_DIR/.$bin.new"
+	chmod 755 "$BIN_DIR/.$bin.new"
+	mv -f "$BIN_DIR/.$bin.new" "$BIN_DIR/$bin"
+	installed="$installed $bin"
+done <<EOF
+$ASSETS
+EOF
+
+[ -n "$installed" ] || fail "no asset of $TAG for $os/$arch${1:+ and command $1}"
+echo "Installed$installed $TAG in $BIN_DIR"
+case ":$PATH:" in
+*":$BIN_DIR:"*) ;;
+*) echo "Add $BIN_DIR to your PATH" ;;
+esac
+`))
+
+// InstallEnabled reports whether the release includes install.sh: unless
+// "install" is false.
+func (c ReleaseConfig) InstallEnabled() bool {
+	return c.Install == nil || *c.Install
+}
+
+// writeInstallScript writes dir/install.sh, installing from repo ("owner/name")
+// the assets of tag; assets[i] carries binaries[i] (see PackageArchives).
+func writeInstallScript(dir, repo, tag string, binaries, assets []string) (string, error) {
+	data := struct {
+		Repo, Tag, Cmds string
+		Assets          []string
+	}{Repo: repo, Tag: tag}
+
+	var cmds []string
+	for i, bin := range binaries {
+		fields, ext, ok := parseBinaryName(filepath.Base(bin))
+		if !ok {
+			return "", fmt.Errorf("cannot tell the target of %s", filepath.Base(bin))
+		}
+		asset := filepath.Base(assets[i])
+		if strings.ContainsAny(asset, " \t\n'") {
+			return "", fmt.Errorf("install.sh: unsupported asset name %q", asset)
+		}
+		data.Assets = append(data.Assets, strings.Join([]string{fields.Cmd, fields.OS, fields.Arch, asset, fields.Cmd + ext}, " "))
+		if !slices.Contains(cmds, fields.Cmd) {
+			cmds = append(cmds, fields.Cmd)
+		}
+	}
+	data.Cmds = strings.Join(cmds, ", ")
+
+	var out strings.Builder
+	if err := installScript.Execute(&out, data); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, InstallScriptFile)
+	if err := os.WriteFile(path, []byte(out.String()), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// originRepo returns the GitHub "owner/name" of origin, "" when origin is
+// not on GitHub.
+func (g *Go) originRepo() string {
+	return originRepoOf(g.rootDir)
+}
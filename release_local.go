@@ -0,0 +1,122 @@
+package devflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestFile is the release manifest written by ReleaseLocal.
+const ManifestFile = "manifest.json"
+
+// ReleaseManifest describes a local release: the assets ReleaseOnly would
+// publish and the binaries they were packaged from.
+type ReleaseManifest struct {
+	Tag      string         `json:"tag"`
+	Commit   string         `json:"commit,omitempty"`
+	Date     string         `json:"date"` // RFC 3339 timestamp of the artifacts
+	Notes    string         `json:"notes,omitempty"`
+	Assets   []ManifestItem `json:"assets"`
+	Binaries []ManifestItem `json:"binaries"`
+}
+
+// ManifestItem is a file of a local release, relative to its directory.
+type ManifestItem struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Cmd    string `json:"cmd,omitempty"`
+	OS     string `json:"os,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+}
+
+// ReleaseLocal runs the release pipeline of ReleaseOnly (cross-compile,
+// packaging, SBOMs, checksums, signature, provenance) without GitHub: the
+// assets are written to dir, the binaries to dir/bin, and both are listed in
+// dir/manifest.json. dir must be empty or missing. If tag is empty, the
+// latest tag is used; an explicit tag does not need to exist. checksums.txt
+// is signed when configured and the key is available.
+func (g *Go) ReleaseLocal(tag, dir string) (*ReleaseManifest, error) {
+	tag, err := g.releaseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	cmds, release, err := g.releaseCommands()
+	if err != nil {
+		return nil, err
+	}
+
+	var key *ReleaseKey
+	if release.Sign {
+		if k, err := LoadReleaseKey(g.secrets); err == nil {
+			key = &k
+		} else {
+			g.consoleOutput(fmt.Sprintf("⚠ checksums.txt left unsigned: %v", err))
+		}
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s is not empty", dir)
+	}
+	binDir := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return nil, err
+	}
+
+	artifacts, err := g.releaseArtifacts(binDir, dir, cmds, tag, release, key, g.originRepo())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &ReleaseManifest{
+		Tag:    tag,
+		Commit: g.releaseCommit(tag),
+		Date:   g.sourceDate(tag).Format(time.RFC3339),
+		Notes:  g.releaseNotes(tag, artifacts),
+	}
+	for _, asset := range artifacts.Assets {
+		item, err := manifestItem(dir, asset)
+		if err != nil {
+			return nil, err
+		}
+		m.Assets = append(m.Assets, item)
+	}
+	for _, bin := range artifacts.Binaries {
+		item, err := manifestItem(dir, bin)
+		if err != nil {
+			return nil, err
+		}
+		if fields, _, ok := parseBinaryName(filepath.Base(bin)); ok {
+			item.Cmd, item.OS, item.Arch = fields.Cmd, fields.OS, fields.Arch
+		}
+		m.Binaries = append(m.Binaries, item)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	g.consoleOutput(fmt.Sprintf("✅ Local release %s → %s (%d assets)", tag, dir, len(m.Assets)))
+	return m, nil
+}
+
+func manifestItem(dir, path string) (ManifestItem, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ManifestItem{}, err
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return ManifestItem{}, err
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return ManifestItem{}, err
+	}
+	return ManifestItem{Name: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum}, nil
+}
@@ -0,0 +1,282 @@
+package devflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/command"
+)
+
+// ProvenanceFile is the release asset describing how the assets were built.
+const ProvenanceFile = "provenance.intoto.json"
+
+// ProvenanceBuilderID identifies gorelease as the builder of a release.
+const ProvenanceBuilderID = "https://github.com/tinywasm/devflow/cmd/gorelease"
+
+// Provenance is the build provenance of a release: an in-toto statement with
+// a SLSA v1 predicate (https://slsa.dev/spec/v1.0/provenance). Its subjects
+// are the entries of checksums.txt.
+type Provenance struct {
+	Type          string              `json:"_type"`
+	Subject       []ProvenanceSubject `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     ProvenancePredicate `json:"predicate"`
+}
+
+// ProvenanceSubject is a release asset and its digests.
+type ProvenanceSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// ProvenancePredicate holds what was built and who built it.
+type ProvenancePredicate struct {
+	BuildDefinition ProvenanceDefinition `json:"buildDefinition"`
+	RunDetails      ProvenanceRun        `json:"runDetails"`
+}
+
+// ProvenanceDefinition holds the inputs needed to rebuild the release.
+type ProvenanceDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ProvenanceParameters `json:"externalParameters"`
+	InternalParameters   map[string]string    `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ProvenanceResource `json:"resolvedDependencies,omitempty"`
+}
+
+// ProvenanceParameters are the source ref and the go builds of the release.
+type ProvenanceParameters struct {
+	Repository string            `json:"repository,omitempty"`
+	Ref        string            `json:"ref"`
+	Builds     []ProvenanceBuild `json:"builds"`
+}
+
+// ProvenanceBuild is one go build, run from the repository root with Env set
+// over the environment.
+type ProvenanceBuild struct {
+	Binary string   `json:"binary"`
+	Target string   `json:"target"`
+	Args   []string `json:"args"`
+	Env    []string `json:"env"`
+}
+
+// ProvenanceResource is an artifact the build read, e.g. the source commit.
+type ProvenanceResource struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// ProvenanceRun identifies the builder and when it ran.
+type ProvenanceRun struct {
+	Builder  ProvenanceBuilder  `json:"builder"`
+	Metadata ProvenanceMetadata `json:"metadata"`
+}
+
+// ProvenanceBuilder is gorelease and its version.
+type ProvenanceBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+// ProvenanceMetadata holds the RFC 3339 times of the release build.
+type ProvenanceMetadata struct {
+	StartedOn  string `json:"startedOn"`
+	FinishedOn string `json:"finishedOn"`
+}
+
+// assetDigest is a line of checksums.txt.
+type assetDigest struct {
+	Name, SHA256 string
+}
+
+// writeChecksums writes the SHA256 of each asset to path, in the
+// "<hex>  <name>" format of sha256sum, and returns the digests.
+func writeChecksums(path string, assets []string) ([]assetDigest, error) {
+	var digests []assetDigest
+	var out strings.Builder
+	for _, asset := range assets {
+		sum, err := fileSHA256(asset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate checksum: %w", err)
+		}
+		d := assetDigest{Name: filepath.Base(asset), SHA256: sum}
+		digests = append(digests, d)
+		fmt.Fprintf(&out, "%s  %s\n", d.SHA256, d.Name)
+	}
+	if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to create checksums.txt: %w", err)
+	}
+	return digests, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeProvenance writes the provenance of the assets in digests, built for
+// tag since started, to path.
+func (g *Go) writeProvenance(path, tag string, cmds []string, release ReleaseConfig, digests []assetDigest, started time.Time) error {
+	builds, err := g.releaseBuilds(cmds, tag, release)
+	if err != nil {
+		return err
+	}
+
+	p := Provenance{
+		Type:          "https://in-toto.io/Statement/v1",
+		PredicateType: "https://slsa.dev/provenance/v1",
+	}
+	for _, d := range digests {
+		p.Subject = append(p.Subject, ProvenanceSubject{Name: d.Name, Digest: map[string]string{"sha256": d.SHA256}})
+	}
+
+	def := &p.Predicate.BuildDefinition
+	def.BuildType = ProvenanceBuilderID + "/build@v1"
+	def.ExternalParameters.Ref = "refs/tags/" + tag
+	for _, b := range builds {
+		def.ExternalParameters.Builds = append(def.ExternalParameters.Builds, ProvenanceBuild{
+			Binary: b.Binary(),
+			Target: b.Target.String(),
+			Args:   b.Args(b.Binary()),
+			Env:    b.Env,
+		})
+	}
+	if goVersion, err := command.RunInDir(g.rootDir, "go", "env", "GOVERSION"); err == nil {
+		def.InternalParameters = map[string]string{"goVersion": goVersion}
+	}
+
+	repo, _ := command.RunInDir(g.rootDir, "git", "remote", "get-url", "origin")
+	if repo == "" {
+		if abs, err := filepath.Abs(g.rootDir); err == nil {
+			repo = "file://" + filepath.ToSlash(abs)
+		}
+	}
+	def.ExternalParameters.Repository = repo
+	if commit := g.releaseCommit(tag); commit != "" {
+		def.ResolvedDependencies = []ProvenanceResource{{
+			URI:    "git+" + repo + "@" + def.ExternalParameters.Ref,
+			Digest: map[string]string{"gitCommit": commit},
+		}}
+	}
+
+	run := &p.Predicate.RunDetails
+	run.Builder.ID = ProvenanceBuilderID
+	run.Builder.Version = map[string]string{"gorelease": builderVersion()}
+	run.Metadata.StartedOn = started.UTC().Format(time.RFC3339)
+	run.Metadata.FinishedOn = time.Now().UTC().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// checkReleaseSource refuses to build tag from a working tree that is not its
+// commit, as the provenance records the tag and that commit as the source. A
+// tag that does not exist yet is built from HEAD. Outside git there is no
+// source to check.
+func (g *Go) checkReleaseSource(tag string) error {
+	head, err := command.RunInDir(g.rootDir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return nil
+	}
+	if commit, err := command.RunInDir(g.rootDir, "git", "rev-list", "-n", "1", tag); err == nil && commit != head {
+		return fmt.Errorf("HEAD is not at %s, check it out to build its assets", tag)
+	}
+	status, err := command.RunInDir(g.rootDir, "git", "status", "--porcelain")
+	if err != nil {
+		return fmt.Errorf("could not check the working tree: %w", err)
+	}
+	if status != "" {
+		return fmt.Errorf("the working tree has uncommitted changes, commit or stash them to build %s", tag)
+	}
+	return nil
+}
+
+// VerifyReproducible builds the release assets of tag twice, in different
+// directories and with an empty build cache the second time, and compares
+// their digests. It returns one line per asset and an error naming the
+// assets that differ. If tag is empty, the latest tag is used.
+func (g *Go) VerifyReproducible(tag string) (string, error) {
+	tag, err := g.releaseTag(tag)
+	if err != nil {
+		return "", err
+	}
+	if err := g.checkReleaseSource(tag); err != nil {
+		return "", fmt.Errorf("refusing to verify %s: %w", tag, err)
+	}
+	cmds, release, err := g.releaseCommands()
+	if err != nil {
+		return "", err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gorelease-verify-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var runs [2]map[string]string
+	var names []string
+	for i := range runs {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("build%d", i+1))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		cfg := release
+		if i == 1 {
+			// A cached build would not compile anything again.
+			cfg.Build.Env = append(append([]string(nil), cfg.Build.Env...), "GOCACHE="+filepath.Join(tmpDir, "gocache"))
+		}
+		files, err := g.buildArtifacts(dir, filepath.Join(dir, "dist"), cmds, tag, cfg)
+		if err != nil {
+			return "", err
+		}
+		runs[i] = map[string]string{}
+		for _, asset := range files.Assets {
+			sum, err := fileSHA256(asset)
+			if err != nil {
+				return "", err
+			}
+			name := filepath.Base(asset)
+			runs[i][name] = sum
+			if i == 0 {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var lines, differ []string
+	for _, name := range names {
+		first, second := runs[0][name], runs[1][name]
+		if first == second {
+			lines = append(lines, fmt.Sprintf("✓ %s  %s", name, first))
+			continue
+		}
+		differ = append(differ, name)
+		if second == "" {
+			second = "missing"
+		}
+		lines = append(lines, fmt.Sprintf("✗ %s  %s != %s", name, first, second))
+	}
+	report := strings.Join(lines, "\n")
+	if len(differ) > 0 {
+		return report, fmt.Errorf("%d of %d assets of %s are not reproducible: %s", len(differ), len(names), tag, strings.Join(differ, ", "))
+	}
+	return report, nil
+}
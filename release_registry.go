@@ -0,0 +1,418 @@
+package devflow
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RegistryAuthEnv holds the "user:password" (or token) of the registry
+// gorelease pushes images to; without it, ~/.docker/config.json is used.
+const RegistryAuthEnv = "DEVFLOW_REGISTRY_AUTH"
+
+// Manifest media types a base image can be served with.
+var registryManifestTypes = []string{
+	ociMediaTypeIndex,
+	ociMediaTypeManifest,
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// parseImageRef splits "ghcr.io/acme/tool:v1" into its registry host,
+// repository and tag or digest ("" when absent). Docker Hub names such as
+// "alpine" are expanded.
+func parseImageRef(ref string) (host, repo, reference string, err error) {
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, reference = name[:i], name[i+1:]
+	}
+	host, repo, ok := strings.Cut(name, "/")
+	if !ok || !strings.ContainsAny(host, ".:") && host != "localhost" {
+		host, repo = "registry-1.docker.io", name
+		if !strings.Contains(repo, "/") {
+			repo = "library/" + repo
+		}
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+	}
+	if repo == "" || strings.ToLower(repo) != repo {
+		return "", "", "", fmt.Errorf("invalid image reference %q", ref)
+	}
+	return host, repo, reference, nil
+}
+
+// registryClient speaks the OCI distribution API of a registry, with its
+// token authentication.
+type registryClient struct {
+	base   string // https://host, http:// for localhost
+	host   string
+	client *http.Client
+	token  string // bearer token of the last challenge
+}
+
+func newRegistryClient(host string) *registryClient {
+	scheme := "https"
+	if h := strings.Split(host, ":")[0]; h == "localhost" || h == "127.0.0.1" || strings.HasPrefix(host, "[::1]") {
+		scheme = "http"
+	}
+	return &registryClient{base: scheme + "://" + host, host: host, client: http.DefaultClient}
+}
+
+// registryCredentials returns the "user:password" of host from
+// RegistryAuthEnv or ~/.docker/config.json, "" when unknown.
+func registryCredentials(host string) string {
+	if auth := os.Getenv(RegistryAuthEnv); auth != "" {
+		return auth
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".docker", "config.json"))
+	if err != nil {
+		return ""
+	}
+	var cfg struct {
+		Auths map[string]struct{ Auth string } `json:"auths"`
+	}
+	if json.Unmarshal(data, &cfg) != nil {
+		return ""
+	}
+	keys := []string{host, "https://" + host}
+	if host == "registry-1.docker.io" {
+		keys = append(keys, "https://index.docker.io/v1/", "docker.io")
+	}
+	for _, k := range keys {
+		if a, ok := cfg.Auths[k]; ok {
+			if raw, err := base64.StdEncoding.DecodeString(a.Auth); err == nil {
+				return string(raw)
+			}
+		}
+	}
+	return ""
+}
+
+// do sends the request built by newReq, authenticating on a 401 challenge.
+func (c *registryClient) do(newReq func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", c.token)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, err
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authenticate answers a Basic or Bearer challenge.
+func (c *registryClient) authenticate(challenge string) error {
+	creds := registryCredentials(c.host)
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == "" {
+			return fmt.Errorf("%s requires credentials: set %s or docker login", c.host, RegistryAuthEnv)
+		}
+		c.token = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("%s: unsupported authentication %q", c.host, challenge)
+	}
+
+	values := map[string]string{}
+	for _, p := range splitChallenge(params) {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			values[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"`)
+		}
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return fmt.Errorf("%s: invalid authentication challenge %q", c.host, challenge)
+	}
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if values[k] != "" {
+			q.Set(k, values[k])
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if user, pass, ok := strings.Cut(creds, ":"); ok {
+		req.SetBasicAuth(user, pass)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: token request failed: %s", c.host, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	c.token = "Bearer " + token.Token
+	return nil
+}
+
+// splitChallenge splits the comma separated parameters of a challenge,
+// keeping commas inside quotes (scope="repository:a:pull,push").
+func splitChallenge(s string) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// get fetches path and checks the digest of the content when path ends with one.
+func (c *registryClient) get(path string, accept ...string) ([]byte, string, error) {
+	resp, err := c.do(func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+		if err == nil && len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if i := strings.LastIndex(path, "/sha256:"); i >= 0 {
+		sum := sha256.Sum256(data)
+		if path[i+1:] != "sha256:"+hex.EncodeToString(sum[:]) {
+			return nil, "", fmt.Errorf("GET %s: digest mismatch", path)
+		}
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// pullImage copies the layers of the platform's image repo:ref into l and
+// returns its config and layers.
+func (c *registryClient) pullImage(l ociLayout, repo, ref string, platform ociPlatform) (ociImageConfig, []ociDescriptor, error) {
+	if ref == "" {
+		ref = "latest"
+	}
+	data, mediaType, err := c.get("/v2/"+repo+"/manifests/"+ref, registryManifestTypes...)
+	if err != nil {
+		return ociImageConfig{}, nil, err
+	}
+	if strings.Contains(mediaType, "index") || strings.Contains(mediaType, "list") {
+		var index ociIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return ociImageConfig{}, nil, err
+		}
+		digest := ""
+		for _, m := range index.Manifests {
+			p := m.Platform
+			if p != nil && p.OS == platform.OS && p.Architecture == platform.Architecture && (platform.Variant == "" || p.Variant == platform.Variant) {
+				digest = m.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return ociImageConfig{}, nil, fmt.Errorf("no %s/%s image", platform.OS, platform.Architecture)
+		}
+		if data, _, err = c.get("/v2/"+repo+"/manifests/"+digest, registryManifestTypes...); err != nil {
+			return ociImageConfig{}, nil, err
+		}
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return ociImageConfig{}, nil, err
+	}
+	raw, _, err := c.get("/v2/" + repo + "/blobs/" + manifest.Config.Digest)
+	if err != nil {
+		return ociImageConfig{}, nil, err
+	}
+	var config ociImageConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return ociImageConfig{}, nil, err
+	}
+	if config.OS != platform.OS || config.Architecture != platform.Architecture {
+		return ociImageConfig{}, nil, fmt.Errorf("the image is %s/%s", config.OS, config.Architecture)
+	}
+
+	var layers []ociDescriptor
+	for _, layer := range manifest.Layers {
+		blob, _, err := c.get("/v2/" + repo + "/blobs/" + layer.Digest)
+		if err != nil {
+			return ociImageConfig{}, nil, err
+		}
+		// Docker layers are the same gzipped tars under another name.
+		mediaType := layer.MediaType
+		if mediaType == "application/vnd.docker.image.rootfs.diff.tar.gzip" {
+			mediaType = ociMediaTypeLayer
+		}
+		desc, err := l.writeBlob(mediaType, blob)
+		if err != nil {
+			return ociImageConfig{}, nil, err
+		}
+		layers = append(layers, desc)
+	}
+	return config, layers, nil
+}
+
+// hasBlob reports whether repo already holds digest.
+func (c *registryClient) hasBlob(repo, digest string) bool {
+	resp, err := c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, c.base+"/v2/"+repo+"/blobs/"+digest, nil)
+	})
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// pushBlob uploads data in a single request.
+func (c *registryClient) pushBlob(repo, digest string, data []byte) error {
+	resp, err := c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, c.base+"/v2/"+repo+"/blobs/uploads/", nil)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("upload of %s: %s", digest, resp.Status)
+	}
+	location, err := url.Parse(c.base + "/")
+	if err == nil {
+		location, err = location.Parse(resp.Header.Get("Location"))
+	}
+	if err != nil {
+		return fmt.Errorf("upload of %s: invalid location: %w", digest, err)
+	}
+	q := location.Query()
+	q.Set("digest", digest)
+	location.RawQuery = q.Encode()
+
+	resp, err = c.do(func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPut, location.String(), bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("upload of %s: %s", digest, resp.Status)
+	}
+	return nil
+}
+
+// pushManifest stores a manifest or index under ref, a tag or its digest.
+func (c *registryClient) pushManifest(repo, ref, mediaType string, data []byte) error {
+	resp, err := c.do(func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPut, c.base+"/v2/"+repo+"/manifests/"+ref, bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", mediaType)
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("manifest %s: %s %s", ref, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// pushLayout pushes the image index of l to the repository ref (without
+// tag), tagged with tags.
+func pushLayout(l ociLayout, ref string, index ociDescriptor, tags []string) error {
+	host, repo, reference, err := parseImageRef(ref)
+	if err != nil {
+		return err
+	}
+	if reference != "" {
+		return fmt.Errorf("image repository %q must not have a tag", ref)
+	}
+	c := newRegistryClient(host)
+
+	blobs, err := imageBlobs(l, index)
+	if err != nil {
+		return err
+	}
+	for _, b := range blobs {
+		data, err := l.readBlob(b.Digest)
+		if err != nil {
+			return err
+		}
+		if b.MediaType == ociMediaTypeManifest {
+			if err := c.pushManifest(repo, b.Digest, b.MediaType, data); err != nil {
+				return err
+			}
+		} else if !c.hasBlob(repo, b.Digest) {
+			if err := c.pushBlob(repo, b.Digest, data); err != nil {
+				return err
+			}
+		}
+	}
+	data, err := l.readBlob(index.Digest)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := c.pushManifest(repo, tag, index.MediaType, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package devflow
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/command"
+	"golang.org/x/mod/modfile"
+)
+
+// SBOMExt is the suffix of the SBOM asset of a release asset:
+// tool-linux-amd64.tar.gz → tool-linux-amd64.tar.gz.spdx.json.
+const SBOMExt = ".spdx.json"
+
+// spdxDocument is the subset of SPDX 2.3 (https://spdx.github.io/spdx-spec/v2.3/)
+// gorelease writes.
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name                  string         `json:"name"`
+	SPDXID                string         `json:"SPDXID"`
+	VersionInfo           string         `json:"versionInfo,omitempty"`
+	DownloadLocation      string         `json:"downloadLocation"`
+	FilesAnalyzed         bool           `json:"filesAnalyzed"`
+	Checksums             []spdxChecksum `json:"checksums,omitempty"`
+	ExternalRefs          []spdxRef      `json:"externalRefs,omitempty"`
+	PrimaryPackagePurpose string         `json:"primaryPackagePurpose,omitempty"`
+	Comment               string         `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// sbomModule is a module linked into a binary.
+type sbomModule struct {
+	Path, Version string
+}
+
+// WriteSBOMs writes an SPDX SBOM of each binary into outDir, named after the
+// release asset carrying it (assets[i] carries binaries[i], see
+// PackageArchives), and returns their paths. The modules come from the build
+// info embedded in the binary, their dependencies from go mod graph. The
+// documents are reproducible: created at the tag's commit time.
+func (g *Go) WriteSBOMs(outDir string, binaries, assets []string, tag string) ([]string, error) {
+	if len(binaries) != len(assets) {
+		return nil, fmt.Errorf("sbom: %d binaries for %d assets", len(binaries), len(assets))
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+	created := g.sourceDate(tag).Format(time.RFC3339)
+	graph := g.moduleGraph()
+
+	var sboms []string
+	for i, bin := range binaries {
+		doc, err := g.sbom(bin, filepath.Base(assets[i]), tag, created, graph)
+		if err != nil {
+			return nil, fmt.Errorf("sbom of %s: %w", filepath.Base(bin), err)
+		}
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(outDir, filepath.Base(assets[i])+SBOMExt)
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			return nil, err
+		}
+		sboms = append(sboms, path)
+	}
+	return sboms, nil
+}
+
+func (g *Go) sbom(bin, asset, tag, created string, graph map[string][]string) (spdxDocument, error) {
+	sum, err := fileSHA256(bin)
+	if err != nil {
+		return spdxDocument{}, err
+	}
+
+	var main sbomModule
+	var deps []sbomModule
+	var goVersion, settings string
+	if info, err := buildinfo.ReadFile(bin); err == nil {
+		main = sbomModule{Path: info.Main.Path}
+		for _, d := range info.Deps {
+			if d.Replace != nil && !modfile.IsDirectoryPath(d.Replace.Path) {
+				d = d.Replace // a fork; local directories keep the required version
+			}
+			deps = append(deps, sbomModule{Path: d.Path, Version: d.Version})
+		}
+		goVersion = info.GoVersion
+		var kv []string
+		for _, s := range info.Settings {
+			kv = append(kv, s.Key+"="+s.Value)
+		}
+		settings = strings.Join(kv, " ")
+	} else {
+		// No build info (e.g. a binary built by another toolchain): the
+		// modules of the build list stand in for the linked ones.
+		g.log("Warning: no build info in", filepath.Base(bin)+", listing the module graph:", err)
+		main, deps = g.buildList()
+	}
+	main.Version = tag
+
+	binName := strings.TrimSuffix(filepath.Base(bin), filepath.Ext(bin))
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              asset,
+		DocumentNamespace: ProvenanceBuilderID + "/spdx/" + binName + "-" + sum,
+		CreationInfo:      spdxCreationInfo{Created: created, Creators: []string{"Tool: gorelease-" + builderVersion()}},
+	}
+
+	const binID = "SPDXRef-Binary"
+	doc.Packages = append(doc.Packages, spdxPackage{
+		Name:                  filepath.Base(bin),
+		SPDXID:                binID,
+		VersionInfo:           tag,
+		DownloadLocation:      "NOASSERTION",
+		Checksums:             []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: sum}},
+		PrimaryPackagePurpose: "APPLICATION",
+		Comment:               strings.TrimSpace(goVersion + " " + settings),
+	})
+	doc.Relationships = append(doc.Relationships, spdxRelationship{"SPDXRef-DOCUMENT", "DESCRIBES", binID})
+
+	ids := map[string]string{} // path@version → SPDXID
+	add := func(m sbomModule, purpose string) string {
+		id := spdxID(m.Path)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:                  m.Path,
+			SPDXID:                id,
+			VersionInfo:           m.Version,
+			DownloadLocation:      "NOASSERTION",
+			ExternalRefs:          []spdxRef{{"PACKAGE-MANAGER", "purl", purl(m)}},
+			PrimaryPackagePurpose: purpose,
+		})
+		ids[m.Path+"@"+m.Version] = id
+		return id
+	}
+
+	if main.Path != "" {
+		mainID := add(main, "SOURCE")
+		ids[main.Path] = mainID // go mod graph names the main module without version
+		doc.Relationships = append(doc.Relationships, spdxRelationship{binID, "GENERATED_FROM", mainID})
+	}
+	if goVersion != "" {
+		id := add(sbomModule{Path: "stdlib", Version: goVersion}, "LIBRARY")
+		doc.Relationships = append(doc.Relationships, spdxRelationship{binID, "CONTAINS", id})
+	}
+	for _, d := range deps {
+		id := add(d, "LIBRARY")
+		doc.Relationships = append(doc.Relationships, spdxRelationship{binID, "CONTAINS", id})
+	}
+
+	// Dependencies between the linked modules, at their selected versions.
+	for _, node := range slices.Sorted(maps.Keys(graph)) {
+		from, ok := ids[node]
+		if !ok {
+			continue
+		}
+		for _, dep := range graph[node] {
+			if to, ok := ids[dep]; ok {
+				doc.Relationships = append(doc.Relationships, spdxRelationship{from, "DEPENDS_ON", to})
+			}
+		}
+	}
+	return doc, nil
+}
+
+// moduleGraph returns the edges of go mod graph by "path@version" ("path"
+// for the main module); nil when it cannot be computed, e.g. offline with an
+// empty module cache.
+func (g *Go) moduleGraph() map[string][]string {
+	out, err := command.RunInDir(g.rootDir, "go", "mod", "graph")
+	if err != nil {
+		g.log("Warning: go mod graph failed, the SBOM has no module dependencies:", err)
+		return nil
+	}
+	graph := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if from, to, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			graph[from] = append(graph[from], to)
+		}
+	}
+	return graph
+}
+
+// buildList returns the main module and the modules of go list -m all.
+func (g *Go) buildList() (sbomModule, []sbomModule) {
+	out, err := command.RunInDir(g.rootDir, "go", "list", "-m", "all")
+	if err != nil {
+		return sbomModule{}, nil
+	}
+	var main sbomModule
+	var deps []sbomModule
+	for i, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case i == 0:
+			main.Path = fields[0]
+		case len(fields) >= 2:
+			deps = append(deps, sbomModule{Path: fields[0], Version: fields[len(fields)-1]})
+		}
+	}
+	return main, deps
+}
+
+// spdxID turns a module path into an SPDX identifier: letters, digits, "."
+// and "-" only.
+func spdxID(path string) string {
+	return "SPDXRef-Module-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, path)
+}
+
+// purl is the package URL of a module: pkg:golang/<path>@<version>.
+func purl(m sbomModule) string {
+	p := "pkg:golang/" + m.Path
+	if m.Version != "" {
+		p += "@" + m.Version
+	}
+	return p
+}
+
+// builderVersion is the version gorelease was installed at, "devel" when
+// built from a checkout.
+func builderVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
+}
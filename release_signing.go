@@ -0,0 +1,234 @@
+package devflow
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/devflow/selfupdate"
+)
+
+// ReleaseKeyName is the entry of the release signing key in the devflow
+// keyring; the ReleaseKeyEnv variable takes precedence (CI).
+const ReleaseKeyName = "RELEASE_SIGNING_KEY"
+
+// ReleaseKeyEnv holds the release signing key when set, as stored in the keyring.
+const ReleaseKeyEnv = "DEVFLOW_RELEASE_KEY"
+
+// ReleasePubKeyFile is the public release key in .devflow/, in the minisign
+// format: gorelease verify uses it when no key is given.
+const ReleasePubKeyFile = "release.pub"
+
+// SignatureExt is the suffix of the signature of checksums.txt.
+const SignatureExt = ".sig"
+
+// SecretStore is the part of the devflow keyring release signing uses.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+}
+
+// SetSecretStore sets the keyring holding the release signing key and the
+// proxy upload token.
+func (g *Go) SetSecretStore(store SecretStore) {
+	g.secrets = store
+}
+
+// ReleaseKey is an Ed25519 key signing checksums.txt. Signatures use the
+// minisign format (legacy, non-prehashed "Ed" algorithm), so
+// `minisign -Vm checksums.txt -p release.pub` checks them as well.
+type ReleaseKey struct {
+	ID      [8]byte
+	Private ed25519.PrivateKey
+}
+
+// GenerateReleaseKey creates a random release key.
+func GenerateReleaseKey() (ReleaseKey, error) {
+	var k ReleaseKey
+	if _, err := rand.Read(k.ID[:]); err != nil {
+		return ReleaseKey{}, err
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return ReleaseKey{}, err
+	}
+	k.Private = priv
+	return k, nil
+}
+
+// LoadReleaseKey reads the release key from ReleaseKeyEnv or, when unset,
+// from store.
+func LoadReleaseKey(store SecretStore) (ReleaseKey, error) {
+	encoded := os.Getenv(ReleaseKeyEnv)
+	if encoded == "" && store != nil {
+		encoded, _ = store.Get(ReleaseKeyName)
+	}
+	if encoded == "" {
+		return ReleaseKey{}, fmt.Errorf("no release signing key in the devflow keyring or %s: run gorelease keygen", ReleaseKeyEnv)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 8+ed25519.SeedSize {
+		return ReleaseKey{}, fmt.Errorf("invalid release signing key")
+	}
+	var k ReleaseKey
+	copy(k.ID[:], raw[:8])
+	k.Private = ed25519.NewKeyFromSeed(raw[8:])
+	return k, nil
+}
+
+// Save stores the key in store.
+func (k ReleaseKey) Save(store SecretStore) error {
+	return store.Set(ReleaseKeyName, base64.StdEncoding.EncodeToString(append(k.ID[:], k.Private.Seed()...)))
+}
+
+// KeyID is the key id minisign displays: the id as a little-endian integer.
+func (k ReleaseKey) KeyID() string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(k.ID[:]))
+}
+
+// PublicKey returns the public key file, in the minisign format.
+func (k ReleaseKey) PublicKey() string {
+	raw := append([]byte("Ed"), k.ID[:]...)
+	raw = append(raw, k.Private.Public().(ed25519.PublicKey)...)
+	return fmt.Sprintf("untrusted comment: minisign public key %s\n%s\n", k.KeyID(), base64.StdEncoding.EncodeToString(raw))
+}
+
+// Sign returns the minisign signature file of data. Ed25519 signatures are
+// deterministic: the same data and trusted comment give the same file.
+func (k ReleaseKey) Sign(data []byte, trustedComment string) []byte {
+	sig := append([]byte("Ed"), k.ID[:]...)
+	sig = append(sig, ed25519.Sign(k.Private, data)...)
+	global := ed25519.Sign(k.Private, append(append([]byte(nil), sig[10:]...), trustedComment...))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "untrusted comment: signature from devflow release key %s\n", k.KeyID())
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(sig))
+	fmt.Fprintf(&out, "trusted comment: %s\n", trustedComment)
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(global))
+	return out.Bytes()
+}
+
+// signChecksums writes the signature of checksumsPath next to it.
+func (k ReleaseKey) signChecksums(checksumsPath string, date time.Time) (string, error) {
+	data, err := os.ReadFile(checksumsPath)
+	if err != nil {
+		return "", err
+	}
+	comment := fmt.Sprintf("timestamp:%d\tfile:%s", date.Unix(), filepath.Base(checksumsPath))
+	sigPath := checksumsPath + SignatureExt
+	if err := os.WriteFile(sigPath, k.Sign(data, comment), 0644); err != nil {
+		return "", err
+	}
+	return sigPath, nil
+}
+
+// VerifySignature checks the minisign signature sig of data against the
+// public key (a minisign public key file, or its base64 line) and returns
+// the trusted comment.
+func VerifySignature(publicKey string, data, sig []byte) (string, error) {
+	return selfupdate.VerifySignature(publicKey, data, sig)
+}
+
+// VerifyRelease checks the downloaded release assets in dir against
+// dir/checksums.txt, and checksums.txt against its signature when publicKey
+// is set (a minisign public key file or its base64 line). Assets listed but
+// not downloaded are skipped; at least one must be present. It returns one
+// line per asset.
+func VerifyRelease(dir, publicKey string) (string, error) {
+	checksumsPath := filepath.Join(dir, "checksums.txt")
+	checksums, err := os.ReadFile(checksumsPath)
+	if err != nil {
+		return "", fmt.Errorf("checksums.txt not found in %s: %w", dir, err)
+	}
+
+	var lines []string
+	sig, sigErr := os.ReadFile(checksumsPath + SignatureExt)
+	switch {
+	case publicKey != "" && sigErr != nil:
+		return "", fmt.Errorf("checksums.txt is not signed: %s not found", filepath.Base(checksumsPath)+SignatureExt)
+	case publicKey != "":
+		comment, err := VerifySignature(publicKey, checksums, sig)
+		if err != nil {
+			return "", fmt.Errorf("checksums.txt: %w", err)
+		}
+		lines = append(lines, fmt.Sprintf("🔏 checksums.txt: good signature (%s)", comment))
+	case sigErr == nil:
+		return "", fmt.Errorf("checksums.txt is signed but no public key was given (--key or %s/%s)", DevflowConfigDir, ReleasePubKeyFile)
+	default:
+		lines = append(lines, "⚠ checksums.txt is not signed")
+	}
+
+	var failed []string
+	verified := 0
+	for _, line := range strings.Split(string(checksums), "\n") {
+		want, name, ok := strings.Cut(strings.TrimSpace(line), "  ")
+		if !ok {
+			continue
+		}
+		if name != filepath.Base(name) {
+			return "", fmt.Errorf("checksums.txt: invalid asset name %q", name)
+		}
+		got, err := fileSHA256(filepath.Join(dir, name))
+		switch {
+		case os.IsNotExist(err):
+			lines = append(lines, fmt.Sprintf("- %s: not downloaded", name))
+		case err != nil:
+			return "", err
+		case got != want:
+			failed = append(failed, name)
+			lines = append(lines, fmt.Sprintf("✗ %s: checksum mismatch", name))
+		default:
+			verified++
+			lines = append(lines, fmt.Sprintf("✓ %s", name))
+		}
+	}
+
+	report := strings.Join(lines, "\n")
+	if len(failed) > 0 {
+		return report, fmt.Errorf("%d assets do not match checksums.txt: %s", len(failed), strings.Join(failed, ", "))
+	}
+	if verified == 0 {
+		return report, fmt.Errorf("no asset of checksums.txt found in %s", dir)
+	}
+	return report, nil
+}
+
+// ReleasePublicKey reads rootDir/.devflow/release.pub, "" when missing.
+func ReleasePublicKey(rootDir string) string {
+	data, err := os.ReadFile(filepath.Join(rootDir, DevflowConfigDir, ReleasePubKeyFile))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// GenerateReleaseKeyFor creates the release key of the repository at rootDir:
+// the private key goes to store, the public key to .devflow/release.pub. It
+// refuses to replace an existing key.
+func GenerateReleaseKeyFor(rootDir string, store SecretStore) (ReleaseKey, error) {
+	if existing, _ := store.Get(ReleaseKeyName); existing != "" {
+		return ReleaseKey{}, fmt.Errorf("a release signing key already exists in the devflow keyring")
+	}
+	k, err := GenerateReleaseKey()
+	if err != nil {
+		return ReleaseKey{}, err
+	}
+	if err := k.Save(store); err != nil {
+		return ReleaseKey{}, fmt.Errorf("could not save the key to the keyring: %w", err)
+	}
+	dir := filepath.Join(rootDir, DevflowConfigDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ReleaseKey{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ReleasePubKeyFile), []byte(k.PublicKey()), 0644); err != nil {
+		return ReleaseKey{}, err
+	}
+	return k, nil
+}
@@ -0,0 +1,425 @@
+package devflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// TapConfig configures a repository gorelease commits a package manifest per
+// command to after a release: a Homebrew tap (Formula/<cmd>.rb) or a Scoop
+// bucket (bucket/<cmd>.json).
+//
+//	"homebrew": {"repo": "acme/homebrew-tap", "description": "Fast tool"}
+type TapConfig struct {
+	// Repo is the GitHub "owner/name" of the repository, or a git URL or path.
+	Repo string `json:"repo"`
+	// Branch to commit to (default: the branch the clone checks out).
+	Branch string `json:"branch,omitempty"`
+	// Dir holds the manifests in the repository (default "Formula" for
+	// Homebrew, "bucket" for Scoop).
+	Dir string `json:"dir,omitempty"`
+	// Template replaces the built-in template: a file of the released
+	// repository, executed with a TapPackage.
+	Template    string `json:"template,omitempty"`
+	Description string `json:"description,omitempty"` // default: the first paragraph of README.md
+	Homepage    string `json:"homepage,omitempty"`    // default: the GitHub page of the release repository
+	License     string `json:"license,omitempty"`     // SPDX identifier, omitted when empty
+}
+
+// TapPackage is the data of the Homebrew formula and Scoop manifest templates
+// of a command.
+type TapPackage struct {
+	Cmd         string
+	Class       string // Homebrew class name of Cmd, e.g. "MyTool" for my-tool
+	Repo        string // "owner/name" the release is published in
+	Tag         string
+	Version     string // Tag without its "v" prefix
+	Description string
+	Homepage    string
+	License     string
+	Archived    bool // the assets are archives holding Cmd, not bare binaries
+	Assets      []TapAsset
+}
+
+// TapAsset is a release asset of a TapPackage.
+type TapAsset struct {
+	OS, Arch string // as in the asset name, e.g. "linux", "armv7"
+	Name     string
+	URL      string
+	SHA256   string
+}
+
+// For returns the assets of goos.
+func (p TapPackage) For(goos string) []TapAsset {
+	var assets []TapAsset
+	for _, a := range p.Assets {
+		if a.OS == goos {
+			assets = append(assets, a)
+		}
+	}
+	return assets
+}
+
+// HomebrewCPU returns the Homebrew condition matching the asset's
+// architecture, "" when Homebrew does not run there.
+func (a TapAsset) HomebrewCPU() string {
+	switch a.Arch {
+	case "amd64":
+		return "Hardware::CPU.intel? && Hardware::CPU.is_64_bit?"
+	case "arm64":
+		return "Hardware::CPU.arm? && Hardware::CPU.is_64_bit?"
+	case "armv6", "armv7":
+		return "Hardware::CPU.arm? && !Hardware::CPU.is_64_bit?"
+	}
+	return ""
+}
+
+// ScoopArch returns the Scoop architecture of the asset, "" when unsupported.
+func (a TapAsset) ScoopArch() string {
+	switch a.Arch {
+	case "amd64":
+		return "64bit"
+	case "386":
+		return "32bit"
+	case "arm64":
+		return "arm64"
+	}
+	return ""
+}
+
+var tapFuncs = template.FuncMap{
+	// json quotes a string for JSON and Ruby alike.
+	"json": func(s string) (string, error) {
+		data, err := json.Marshal(s)
+		return string(data), err
+	},
+}
+
+var homebrewFormula = template.Must(template.New("formula").Funcs(tapFuncs).Parse(`# Generated by gorelease from {{.Repo}} {{.Tag}}. DO NOT EDIT.
+class {{.Class}} < Formula
+  desc {{json .Description}}
+  homepage {{json .Homepage}}
+  version {{json .Version}}
+{{- with .License}}
+  license {{json .}}
+{{- end}}
+{{- with .For "darwin"}}
+
+  on_macos do
+{{- range .}}
+    if {{.HomebrewCPU}}
+      url {{json .URL}}
+      sha256 {{json .SHA256}}
+    end
+{{- end}}
+  end
+{{- end}}
+{{- with .For "linux"}}
+
+  on_linux do
+{{- range .}}
+    if {{.HomebrewCPU}}
+      url {{json .URL}}
+      sha256 {{json .SHA256}}
+    end
+{{- end}}
+  end
+{{- end}}
+
+  def install
+{{- if .Archived}}
+    bin.install {{json .Cmd}}
+{{- else}}
+    bin.install Dir[{{json (print .Cmd "-*")}}].first => {{json .Cmd}}
+{{- end}}
+  end
+
+  test do
+    assert_predicate bin/{{json .Cmd}}, :executable?
+  end
+end
+`))
+
+var scoopManifest = template.Must(template.New("manifest").Funcs(tapFuncs).Parse(`{
+  "version": {{json .Version}},
+  "description": {{json .Description}},
+  "homepage": {{json .Homepage}},
+{{- with .License}}
+  "license": {{json .}},
+{{- end}}
+  "architecture": {
+{{- range $i, $a := .For "windows"}}{{if $i}},{{end}}
+    {{json .ScoopArch}}: {
+      "url": {{if $.Archived}}{{json .URL}}{{else}}{{json (print .URL "#/" $.Cmd ".exe")}}{{end}},
+      "hash": {{json .SHA256}}
+    }
+{{- end}}
+  },
+  "bin": {{json (print .Cmd ".exe")}}
+}
+`))
+
+// tapKind is a package manager gorelease updates.
+type tapKind struct {
+	Name     string // "Homebrew", "Scoop"
+	Dir      string // default TapConfig.Dir
+	Ext      string // manifest extension
+	Template *template.Template
+	OS       []string // the systems the manager runs on
+	Arch     func(TapAsset) string
+}
+
+var (
+	homebrewTap = tapKind{Name: "Homebrew", Dir: "Formula", Ext: ".rb", Template: homebrewFormula, OS: []string{"darwin", "linux"}, Arch: TapAsset.HomebrewCPU}
+	scoopTap    = tapKind{Name: "Scoop", Dir: "bucket", Ext: ".json", Template: scoopManifest, OS: []string{"windows"}, Arch: TapAsset.ScoopArch}
+)
+
+// tapPackages returns the package of each command from the release files:
+// files.Assets[i] is the asset of files.Binaries[i] (see PackageArchives).
+func (g *Go) tapPackages(repo, tag string, release ReleaseConfig, files releaseFiles) ([]TapPackage, error) {
+	var pkgs []TapPackage
+	index := map[string]int{}
+	for i, bin := range files.Binaries {
+		fields, _, ok := parseBinaryName(filepath.Base(bin))
+		if !ok {
+			return nil, fmt.Errorf("cannot tell the target of %s", filepath.Base(bin))
+		}
+		asset := files.Assets[i]
+		sum, err := fileSHA256(asset)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := index[fields.Cmd]
+		if !ok {
+			n = len(pkgs)
+			index[fields.Cmd] = n
+			pkgs = append(pkgs, TapPackage{
+				Cmd:      fields.Cmd,
+				Class:    homebrewClass(fields.Cmd),
+				Repo:     repo,
+				Tag:      tag,
+				Version:  strings.TrimPrefix(tag, "v"),
+				Archived: release.Archive.Format != "binary",
+			})
+		}
+		pkgs[n].Assets = append(pkgs[n].Assets, TapAsset{
+			OS:     fields.OS,
+			Arch:   fields.Arch,
+			Name:   filepath.Base(asset),
+			URL:    fmt.Sprintf("https://github.com/%s/releases/download/%s/%s", repo, tag, filepath.Base(asset)),
+			SHA256: sum,
+		})
+	}
+	return pkgs, nil
+}
+
+// homebrewClass returns the Homebrew class name of a formula: "my-tool" is
+// "MyTool".
+func homebrewClass(name string) string {
+	var class strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case r == '@':
+			class.WriteString("AT")
+			upper = true
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			upper = true
+		case upper:
+			class.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			class.WriteRune(r)
+		}
+	}
+	return class.String()
+}
+
+// readmeSummary returns the first paragraph of rootDir/README.md that is not
+// a heading, a badge or HTML, "" when there is none.
+func readmeSummary(rootDir string) string {
+	data, err := os.ReadFile(filepath.Join(rootDir, "README.md"))
+	if err != nil {
+		return ""
+	}
+	for _, para := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" || strings.ContainsAny(para[:1], "#![<|`>-*") {
+			continue
+		}
+		return strings.Join(strings.Fields(para), " ")
+	}
+	return ""
+}
+
+// writeTap writes the manifest of each package kind supports into dir,
+// relative to a checkout of the tap, and returns the files written.
+func (g *Go) writeTap(dir string, kind tapKind, cfg TapConfig, pkgs []TapPackage) ([]string, error) {
+	tmpl := kind.Template
+	if cfg.Template != "" {
+		t, err := template.New(filepath.Base(cfg.Template)).Funcs(tapFuncs).ParseFiles(filepath.Join(g.rootDir, cfg.Template))
+		if err != nil {
+			return nil, fmt.Errorf("%s template: %w", kind.Name, err)
+		}
+		tmpl = t
+	}
+	sub := cfg.Dir
+	if sub == "" {
+		sub = kind.Dir
+	}
+	if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+		return nil, err
+	}
+
+	description := cfg.Description
+	if description == "" {
+		description = readmeSummary(g.rootDir)
+	}
+
+	var written []string
+	for _, pkg := range pkgs {
+		pkg.Description = description
+		if pkg.Description == "" {
+			pkg.Description = pkg.Cmd + " from " + pkg.Repo
+		}
+		pkg.Homepage = cfg.Homepage
+		if pkg.Homepage == "" {
+			pkg.Homepage = "https://github.com/" + pkg.Repo
+		}
+		pkg.License = cfg.License
+
+		// Only the assets the manager can install, one per architecture
+		var assets []TapAsset
+		seen := map[string]bool{}
+		for _, a := range pkg.Assets {
+			key := a.OS + " " + kind.Arch(a)
+			if kind.Arch(a) == "" || seen[key] || !slices.Contains(kind.OS, a.OS) {
+				continue
+			}
+			seen[key] = true
+			assets = append(assets, a)
+		}
+		if len(assets) == 0 {
+			continue
+		}
+		pkg.Assets = assets
+
+		var out strings.Builder
+		if err := tmpl.Execute(&out, pkg); err != nil {
+			return nil, fmt.Errorf("%s manifest of %s: %w", kind.Name, pkg.Cmd, err)
+		}
+		rel := filepath.ToSlash(filepath.Join(sub, pkg.Cmd+kind.Ext))
+		if err := os.WriteFile(filepath.Join(dir, rel), []byte(out.String()), 0644); err != nil {
+			return nil, err
+		}
+		written = append(written, rel)
+	}
+	return written, nil
+}
+
+// updateTaps commits the Homebrew formulas and Scoop manifests of the
+// released commands to the configured repositories.
+func (g *Go) updateTaps(gh *gitmod.GitHub, repo, tag string, release ReleaseConfig, files releaseFiles) error {
+	if release.Homebrew == nil && release.Scoop == nil {
+		return nil
+	}
+	if repo == "" {
+		return fmt.Errorf("cannot tell the GitHub repository the assets are downloaded from")
+	}
+	pkgs, err := g.tapPackages(repo, tag, release, files)
+	if err != nil {
+		return err
+	}
+	for _, tap := range []struct {
+		kind tapKind
+		cfg  *TapConfig
+	}{{homebrewTap, release.Homebrew}, {scoopTap, release.Scoop}} {
+		if tap.cfg == nil {
+			continue
+		}
+		if err := g.updateTap(gh, tap.kind, *tap.cfg, tag, pkgs); err != nil {
+			return fmt.Errorf("%s tap %s: %w", tap.kind.Name, tap.cfg.Repo, err)
+		}
+	}
+	return nil
+}
+
+// updateTap clones the tap of cfg, writes the manifests of pkgs and pushes
+// them in a commit.
+func (g *Go) updateTap(gh *gitmod.GitHub, kind tapKind, cfg TapConfig, tag string, pkgs []TapPackage) error {
+	tmpDir, err := os.MkdirTemp("", "gorelease-tap-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	dir := filepath.Join(tmpDir, "tap")
+
+	cloneArgs := []string{"--depth=1"}
+	if cfg.Branch != "" {
+		cloneArgs = append(cloneArgs, "--branch", cfg.Branch)
+	}
+	if isGitHubRepoRef(cfg.Repo) {
+		args := append([]string{"repo", "clone", cfg.Repo, dir, "--"}, cloneArgs...)
+		if _, err := githubRunner(gh).Run("gh", args...); err != nil {
+			return fmt.Errorf("clone failed: %w", err)
+		}
+	} else {
+		args := append(append([]string{"clone"}, cloneArgs...), cfg.Repo, dir)
+		if out, err := command.RunInDir(tmpDir, "git", args...); err != nil {
+			return fmt.Errorf("clone failed: %w\n%s", err, out)
+		}
+	}
+
+	written, err := g.writeTap(dir, kind, cfg, pkgs)
+	if err != nil {
+		return err
+	}
+	if len(written) == 0 {
+		g.consoleOutput(fmt.Sprintf("⚠ %s tap %s: no asset for %s", kind.Name, cfg.Repo, strings.Join(kind.OS, "/")))
+		return nil
+	}
+
+	git, err := gitmod.NewGit()
+	if err != nil {
+		return err
+	}
+	git.SetRootDir(dir)
+	if err := git.Add(); err != nil {
+		return fmt.Errorf("git add failed: %w", err)
+	}
+	var cmds []string
+	for _, file := range written {
+		cmds = append(cmds, strings.TrimSuffix(path.Base(file), kind.Ext))
+	}
+	committed, err := git.Commit(fmt.Sprintf("Update %s to %s", strings.Join(cmds, ", "), tag))
+	if err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	if !committed {
+		g.consoleOutput(fmt.Sprintf("%s tap %s already up to date", kind.Name, cfg.Repo))
+		return nil
+	}
+	if _, err := git.PushWithoutTags(); err != nil {
+		return fmt.Errorf("push failed: %w", err)
+	}
+	g.consoleOutput(fmt.Sprintf("✅ %s tap %s → %s", kind.Name, cfg.Repo, strings.Join(written, ", ")))
+	return nil
+}
+
+// isGitHubRepoRef reports whether ref is a GitHub "owner/name" rather than a
+// git URL or path.
+func isGitHubRepoRef(ref string) bool {
+	owner, name, ok := strings.Cut(ref, "/")
+	return ok && owner != "" && name != "" && !strings.Contains(name, "/") &&
+		!strings.ContainsAny(ref, ":@\\") && !strings.HasPrefix(owner, ".")
+}
@@ -0,0 +1,320 @@
+package devflow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/tinywasm/command"
+)
+
+// WasmExecFile is the JavaScript support file of js/wasm binaries, published
+// next to them.
+const WasmExecFile = "wasm_exec.js"
+
+// WasmConfig builds the js/wasm entry points of the repository, with Go or,
+// after UseTinygo, TinyGo:
+//
+//	"wasm": {"packages": ["web/client"], "wasm_opt": ["-Oz", "--enable-bulk-memory"]}
+type WasmConfig struct {
+	// Packages are the main packages built for js/wasm, relative to the
+	// repository root: "web/client" gives client.wasm.
+	Packages []string `json:"packages"`
+	// WasmOpt are the flags of binaryen's wasm-opt, run on each .wasm when set.
+	WasmOpt []string `json:"wasm_opt,omitempty"`
+	// Compress lists the precompressed copies: "gzip" (.wasm.gz) and "br"
+	// (.wasm.br). Default both, [] for none.
+	Compress []string `json:"compress,omitempty"`
+}
+
+// WasmArtifact is the js/wasm build of a package.
+type WasmArtifact struct {
+	Package  string
+	Compiler string // "go" or "tinygo"
+	Path     string // the .wasm
+	Size     int64
+	Built    int64 // size before wasm-opt, 0 when it did not run
+	Gzip     int64 // size of .wasm.gz, 0 when not published
+	Brotli   int64 // size of .wasm.br, 0 when not published
+}
+
+func (c WasmConfig) validate() error {
+	if len(c.Packages) == 0 {
+		return fmt.Errorf("%s/%s: wasm: packages is required", DevflowConfigDir, ReleaseFile)
+	}
+	seen := map[string]string{}
+	for _, pkg := range c.Packages {
+		clean := path.Clean(filepath.ToSlash(pkg))
+		if clean == "." || path.IsAbs(clean) || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("%s/%s: wasm package %q must be a directory of the repository", DevflowConfigDir, ReleaseFile, pkg)
+		}
+		if other, ok := seen[path.Base(clean)]; ok {
+			return fmt.Errorf("%s/%s: wasm packages %s and %s would both give %s.wasm", DevflowConfigDir, ReleaseFile, other, pkg, path.Base(clean))
+		}
+		seen[path.Base(clean)] = pkg
+	}
+	for _, format := range c.Compress {
+		if format != "gzip" && format != "br" {
+			return fmt.Errorf("%s/%s: unknown wasm compression %q (expected \"gzip\" or \"br\")", DevflowConfigDir, ReleaseFile, format)
+		}
+	}
+	return nil
+}
+
+func (c WasmConfig) compressions() []string {
+	if c.Compress == nil {
+		return []string{"gzip", "br"}
+	}
+	return c.Compress
+}
+
+// BuildWasm builds the packages of release.Wasm into distDir: <name>.wasm,
+// optimized by wasm-opt when configured, its precompressed copies and
+// wasm_exec.js of the compiler. It returns the builds and the assets.
+func (g *Go) BuildWasm(distDir, tag string, release ReleaseConfig) ([]WasmArtifact, []string, error) {
+	cfg := release.Wasm
+	if cfg == nil {
+		return nil, nil, nil
+	}
+	compiler := "go"
+	if g.useTinygo {
+		compiler = "tinygo"
+	}
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return nil, nil, err
+	}
+	date := g.sourceDate(tag)
+	data := ldflagsData{Version: tag, Commit: g.releaseCommit(tag), Date: date.Format(time.RFC3339)}
+	vcs := isGitCheckout(g.rootDir)
+
+	var artifacts []WasmArtifact
+	var assets []string
+	for _, pkg := range cfg.Packages {
+		pkg = path.Clean(filepath.ToSlash(pkg))
+		name := path.Base(pkg)
+		b, err := newReleaseBuild(name, CrossTarget{GOOS: "js", GOARCH: "wasm"}, release.Build, data, vcs, date)
+		if err != nil {
+			return nil, nil, err
+		}
+		out := filepath.Join(distDir, name+".wasm")
+		if err := g.buildWasm(compiler, pkg, out, b); err != nil {
+			return nil, nil, err
+		}
+		a := WasmArtifact{Package: pkg, Compiler: compiler, Path: out}
+
+		if len(cfg.WasmOpt) > 0 {
+			if a.Built, err = fileSize(out); err != nil {
+				return nil, nil, err
+			}
+			if err := g.wasmOpt(out, cfg.WasmOpt); err != nil {
+				return nil, nil, err
+			}
+		}
+		if a.Size, err = fileSize(out); err != nil {
+			return nil, nil, err
+		}
+		if err := os.Chtimes(out, date, date); err != nil {
+			return nil, nil, err
+		}
+		assets = append(assets, out)
+
+		for _, format := range cfg.compressions() {
+			compressed, size, err := compressFile(out, format, date)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s of %s: %w", format, filepath.Base(out), err)
+			}
+			if format == "gzip" {
+				a.Gzip = size
+			} else {
+				a.Brotli = size
+			}
+			assets = append(assets, compressed)
+		}
+		artifacts = append(artifacts, a)
+		g.consoleOutput(fmt.Sprintf("📦 %s: %s", filepath.Base(out), a.sizes()))
+	}
+
+	execJS, err := g.copyWasmExec(compiler, filepath.Join(distDir, WasmExecFile), date)
+	if err != nil {
+		return nil, nil, err
+	}
+	return artifacts, append(assets, execJS), nil
+}
+
+// buildWasm compiles pkg into out with the flags of b.
+func (g *Go) buildWasm(compiler, pkg, out string, b ReleaseBuild) error {
+	var args []string
+	if compiler == "tinygo" {
+		// TinyGo strips debug info itself and only takes -X in ldflags.
+		args = []string{"build", "-o", out, "-target", "wasm", "-no-debug", "-opt", "z"}
+		if len(b.Tags) > 0 {
+			args = append(args, "-tags="+strings.Join(b.Tags, ","))
+		}
+		if b.LinkFlags != "" {
+			args = append(args, "-ldflags="+b.LinkFlags)
+		}
+		args = append(args, "./"+pkg)
+	} else {
+		args = crossBuildArgs(b.Cmd, out, b.Ldflags, b.Tags, b.VCS)
+		args[len(args)-1] = "./" + pkg // the package, not ./cmd/<name>
+	}
+
+	buildCmd := command.Exec(compiler, args...)
+	buildCmd.Dir = g.rootDir
+	buildCmd.Env = append(os.Environ(), b.Env...)
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to build %s for js/wasm with %s: %w\nOutput: %s", pkg, compiler, err, string(output))
+	}
+	return nil
+}
+
+// wasmOpt optimizes the module file in place.
+func (g *Go) wasmOpt(file string, flags []string) error {
+	if _, err := exec.LookPath("wasm-opt"); err != nil {
+		return fmt.Errorf("wasm-opt not found: install binaryen or remove \"wasm_opt\" from %s/%s", DevflowConfigDir, ReleaseFile)
+	}
+	tmp := file + ".opt"
+	optCmd := command.Exec("wasm-opt", append(slices.Clip(flags), "-o", tmp, file)...)
+	optCmd.Dir = g.rootDir
+	if output, err := optCmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("wasm-opt failed on %s: %w\nOutput: %s", filepath.Base(file), err, string(output))
+	}
+	return os.Rename(tmp, file)
+}
+
+// copyWasmExec copies the wasm_exec.js of compiler to dst.
+func (g *Go) copyWasmExec(compiler, dst string, date time.Time) (string, error) {
+	var candidates []string
+	if compiler == "tinygo" {
+		root, err := command.RunInDir(g.rootDir, "tinygo", "env", "TINYGOROOT")
+		if err != nil {
+			return "", fmt.Errorf("tinygo env TINYGOROOT: %w", err)
+		}
+		candidates = []string{filepath.Join(root, "targets", WasmExecFile)}
+	} else {
+		root, err := command.RunInDir(g.rootDir, "go", "env", "GOROOT")
+		if err != nil {
+			return "", fmt.Errorf("go env GOROOT: %w", err)
+		}
+		// lib/wasm since Go 1.24, misc/wasm before
+		candidates = []string{filepath.Join(root, "lib", "wasm", WasmExecFile), filepath.Join(root, "misc", "wasm", WasmExecFile)}
+	}
+	for _, src := range candidates {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return "", err
+		}
+		return dst, os.Chtimes(dst, date, date)
+	}
+	return "", fmt.Errorf("%s of %s not found in %s", WasmExecFile, compiler, strings.Join(candidates, ", "))
+}
+
+// compressFile writes file.gz or file.br and returns its path and size.
+func compressFile(file, format string, date time.Time) (string, int64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", 0, err
+	}
+	var buf bytes.Buffer
+	ext := ".gz"
+	if format == "br" {
+		ext = ".br"
+		w := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+		if _, err := w.Write(data); err != nil {
+			return "", 0, err
+		}
+		if err := w.Close(); err != nil {
+			return "", 0, err
+		}
+	} else {
+		w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression) // no name, zero header time
+		if _, err := w.Write(data); err != nil {
+			return "", 0, err
+		}
+		if err := w.Close(); err != nil {
+			return "", 0, err
+		}
+	}
+	out := file + ext
+	if err := os.WriteFile(out, buf.Bytes(), 0644); err != nil {
+		return "", 0, err
+	}
+	return out, int64(buf.Len()), os.Chtimes(out, date, date)
+}
+
+func fileSize(file string) (int64, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// formatSize returns n bytes in B, KiB or MiB.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// sizes describes the sizes of a for the console.
+func (a WasmArtifact) sizes() string {
+	s := formatSize(a.Size)
+	if a.Built > 0 {
+		s += fmt.Sprintf(" (%s before wasm-opt)", formatSize(a.Built))
+	}
+	if a.Gzip > 0 {
+		s += ", gzip " + formatSize(a.Gzip)
+	}
+	if a.Brotli > 0 {
+		s += ", br " + formatSize(a.Brotli)
+	}
+	return s
+}
+
+// wasmSizeReport is the section of the release notes listing the wasm
+// sizes, "" without wasm builds.
+func wasmSizeReport(artifacts []WasmArtifact) string {
+	if len(artifacts) == 0 {
+		return ""
+	}
+	cell := func(n int64) string {
+		if n == 0 {
+			return "-"
+		}
+		return formatSize(n)
+	}
+	compiler := "Go"
+	if artifacts[0].Compiler == "tinygo" {
+		compiler = "TinyGo"
+	}
+
+	var b strings.Builder
+	b.WriteString("### WebAssembly\n\n")
+	b.WriteString("| File | Size | gzip | brotli |\n|---|---|---|---|\n")
+	for _, a := range artifacts {
+		size := formatSize(a.Size)
+		if a.Built > 0 {
+			size += " (" + formatSize(a.Built) + " before wasm-opt)"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", filepath.Base(a.Path), size, cell(a.Gzip), cell(a.Brotli))
+	}
+	fmt.Fprintf(&b, "\nBuilt with %s for js/wasm; load with the `%s` of this release.\n", compiler, WasmExecFile)
+	return b.String()
+}
@@ -0,0 +1,368 @@
+package devflow
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+	"golang.org/x/mod/module"
+)
+
+// RemoteDependent is a repository outside the local workspace whose go.mod
+// requires a module.
+type RemoteDependent struct {
+	Repo string // "owner/name"
+	Dir  string // directory of the go.mod inside the repo ("" = root)
+}
+
+// DependentsIndex lists the remote repositories that depend on a module.
+// Implementations: GitHubSearchIndex, FileIndex.
+type DependentsIndex interface {
+	Dependents(modulePath string) ([]RemoteDependent, error)
+}
+
+// GitHubSearchIndex finds dependents with a GitHub code search for the module
+// path in go.mod files.
+type GitHubSearchIndex struct {
+	runner gitmod.Runner
+	owner  string // optional: restrict the search to one user/org
+}
+
+// NewGitHubSearchIndex creates a GitHubSearchIndex. owner may be empty.
+func NewGitHubSearchIndex(runner gitmod.Runner, owner string) *GitHubSearchIndex {
+	if runner == nil {
+		runner = gitmod.RealRunner{}
+	}
+	return &GitHubSearchIndex{runner: runner, owner: owner}
+}
+
+// Dependents implements DependentsIndex.
+func (s *GitHubSearchIndex) Dependents(modulePath string) ([]RemoteDependent, error) {
+	args := []string{"search", "code", modulePath, "--filename", "go.mod", "--json", "path,repository", "--limit", "100"}
+	if s.owner != "" {
+		args = append(args, "--owner", s.owner)
+	}
+	out, err := s.runner.Run("gh", args...)
+	if err != nil {
+		return nil, fmt.Errorf("gh search code failed: %w", err)
+	}
+
+	var results []struct {
+		Path       string `json:"path"`
+		Repository struct {
+			NameWithOwner string `json:"nameWithOwner"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		return nil, fmt.Errorf("could not decode gh search output: %w", err)
+	}
+
+	var deps []RemoteDependent
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if path.Base(r.Path) != "go.mod" || r.Repository.NameWithOwner == "" {
+			continue
+		}
+		dir := path.Dir(r.Path)
+		if dir == "." {
+			dir = ""
+		}
+		key := r.Repository.NameWithOwner + "/" + dir
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deps = append(deps, RemoteDependent{Repo: r.Repository.NameWithOwner, Dir: dir})
+	}
+	return deps, nil
+}
+
+// FileIndex reads dependents from a local importers file, one dependent per line:
+//
+//	# module                    repository    [go.mod dir]
+//	github.com/tinywasm/fmt     acme/webapp
+//	github.com/tinywasm/fmt     acme/tools    cmd/tool
+type FileIndex struct {
+	path string
+}
+
+// NewFileIndex creates a FileIndex over the given file.
+func NewFileIndex(path string) *FileIndex {
+	return &FileIndex{path: path}
+}
+
+// Dependents implements DependentsIndex.
+func (f *FileIndex) Dependents(modulePath string) ([]RemoteDependent, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var deps []RemoteDependent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != modulePath {
+			continue
+		}
+		dep := RemoteDependent{Repo: fields[1]}
+		if len(fields) > 2 {
+			dep.Dir = fields[2]
+		}
+		deps = append(deps, dep)
+	}
+	return deps, scanner.Err()
+}
+
+// DependentsConfig is the content of .devflow/dependents.json.
+//
+//	{"source": "github", "owner": "acme"}
+//	{"source": "file", "path": "docs/importers.txt"}
+type DependentsConfig struct {
+	Source string `json:"source"` // "github" (default) | "file"
+	Owner  string `json:"owner"`  // github: optional user/org filter
+	Path   string `json:"path"`   // file: importers file, relative to the repo root
+}
+
+// LoadDependentsIndex builds the DependentsIndex configured in
+// rootDir/.devflow/dependents.json, defaulting to a GitHub code search.
+func LoadDependentsIndex(rootDir string, runner gitmod.Runner) (DependentsIndex, error) {
+	var cfg DependentsConfig
+	if _, err := readDevflowConfig(rootDir, "dependents.json", &cfg); err != nil {
+		return nil, err
+	}
+	switch cfg.Source {
+	case "", "github":
+		return NewGitHubSearchIndex(runner, cfg.Owner), nil
+	case "file":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("dependents.json: source \"file\" requires a path")
+		}
+		p := cfg.Path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(rootDir, p)
+		}
+		return NewFileIndex(p), nil
+	default:
+		return nil, fmt.Errorf("dependents.json: unknown source %q", cfg.Source)
+	}
+}
+
+// SetRemoteDependents enables remote-dependents mode: after publishing, Push
+// asks idx for dependents that are not cloned locally and opens a
+// dependency-bump pull request on each through gh.
+func (g *Go) SetRemoteDependents(idx DependentsIndex, gh *gitmod.GitHub) {
+	g.remoteIndex = idx
+	g.github = gh
+}
+
+// BumpRemoteDependents opens one dependency-bump pull request per remote
+// dependent of modulePath. Local checkouts are never touched: each dependent
+// is cloned into a temporary directory. The module's own repository (if
+// indexed) is skipped, and so is a dependent whose pull request is already
+// open.
+func (g *Go) BumpRemoteDependents(modulePath, version, rootCause string) ([]CascadeEntry, error) {
+	return g.bumpRemoteDependents(modulePath, version, rootCause, nil)
+}
+
+// bumpRemoteDependents is BumpRemoteDependents skipping the dependents
+// checked out in the local directories, which UpdateDependents bumps.
+func (g *Go) bumpRemoteDependents(modulePath, version, rootCause string, local []string) ([]CascadeEntry, error) {
+	if g.remoteIndex == nil {
+		return nil, nil
+	}
+	if g.github == nil {
+		return nil, fmt.Errorf("remote dependents need a GitHub client")
+	}
+
+	deps, err := g.remoteIndex.Dependents(modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	checkedOut := make(map[string]bool)
+	for _, dir := range local {
+		if name := checkoutName(dir); name != "" {
+			checkedOut[name] = true
+		}
+	}
+
+	var remote []RemoteDependent
+	for _, d := range deps {
+		if isModuleRepo(modulePath, d) || checkedOut[strings.ToLower(d.name())] {
+			continue
+		}
+		remote = append(remote, d)
+	}
+	if len(remote) == 0 {
+		return nil, nil
+	}
+
+	if err := g.WaitForVersionAvailable(modulePath, version); err != nil {
+		return nil, err
+	}
+
+	g.consoleOutput(fmt.Sprintf("🔗 Opening dependency PRs on %d remote dependents...", len(remote)))
+
+	bumps := []gitmod.DepBump{{ModulePath: modulePath, NewVersion: version}}
+	var entries []CascadeEntry
+	for _, d := range remote {
+		name := d.name()
+		url, status, err := g.openRemoteBumpPR(d, bumps, rootCause)
+		switch {
+		case err != nil:
+			g.consoleOutput(fmt.Sprintf("🔗 %s → ❌ %v", name, err))
+			entries = append(entries, CascadeEntry{ModulePath: name, Status: CascadeStatusFailed, Detail: err.Error()})
+		case status == CascadeStatusSkipped && url != "":
+			g.consoleOutput(fmt.Sprintf("🔗 %s → skip (PR already open: %s) ⏭", name, url))
+			entries = append(entries, CascadeEntry{ModulePath: name, Status: status, Detail: "PR already open: " + url})
+		case status == CascadeStatusSkipped:
+			g.consoleOutput(fmt.Sprintf("🔗 %s → skip (already up-to-date) ⏭", name))
+			entries = append(entries, CascadeEntry{ModulePath: name, Status: status, Detail: "already up-to-date"})
+		default:
+			g.consoleOutput(fmt.Sprintf("🔗 %s → %s ✅", name, url))
+			entries = append(entries, CascadeEntry{ModulePath: name, Status: status, Detail: url})
+		}
+	}
+	return entries, nil
+}
+
+// name is "owner/name[/dir]".
+func (d RemoteDependent) name() string {
+	if d.Dir == "" {
+		return d.Repo
+	}
+	return d.Repo + "/" + d.Dir
+}
+
+// isModuleRepo reports whether d is the repository of modulePath itself,
+// with or without its /vN suffix.
+func isModuleRepo(modulePath string, d RemoteDependent) bool {
+	prefix, _, _ := module.SplitPathVersion(modulePath)
+	self := "/" + strings.ToLower(d.name())
+	return strings.HasSuffix(strings.ToLower(modulePath), self) || strings.HasSuffix(strings.ToLower(prefix), self)
+}
+
+// checkoutName returns the "owner/name[/dir]" of the GitHub repository
+// checked out in dir, "" when origin is not on GitHub.
+func checkoutName(dir string) string {
+	top, err := command.RunInDir(dir, "git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return ""
+	}
+	repo := originRepoOf(top)
+	if repo == "" {
+		return ""
+	}
+	d := RemoteDependent{Repo: repo}
+	if rel, err := filepath.Rel(top, dir); err == nil && rel != "." {
+		d.Dir = filepath.ToSlash(rel)
+	}
+	return strings.ToLower(d.name())
+}
+
+// originRepoOf returns the GitHub "owner/name" of origin in the repository
+// of dir, "" when origin is not on GitHub.
+func originRepoOf(dir string) string {
+	url, err := command.RunInDir(dir, "git", "remote", "get-url", "origin")
+	if err != nil {
+		return ""
+	}
+	url = strings.TrimSuffix(strings.TrimSpace(url), ".git")
+	for _, prefix := range []string{"https://github.com/", "git@github.com:", "ssh://git@github.com/"} {
+		if path, ok := strings.CutPrefix(url, prefix); ok && strings.Count(path, "/") == 1 {
+			return path
+		}
+	}
+	return ""
+}
+
+// openRemoteBumpPR clones one remote dependent, bumps the requirement on a
+// dedicated branch and opens a pull request. Every command goes through the
+// runner of the GitHub client, so it shares its authentication and the whole
+// flow can be exercised offline. A pull request already open for the branch
+// (an earlier run) is returned with CascadeStatusSkipped; a branch left
+// without one is replaced.
+func (g *Go) openRemoteBumpPR(d RemoteDependent, bumps []gitmod.DepBump, rootCause string) (url, status string, err error) {
+	runner := githubRunner(g.github)
+
+	last := bumps[len(bumps)-1]
+	branch := fmt.Sprintf("deps/%s-%s", path.Base(last.ModulePath), last.NewVersion)
+	if d.Dir != "" {
+		branch += "-" + strings.ReplaceAll(d.Dir, "/", "-")
+	}
+
+	open, err := runner.Run("gh", "pr", "list", "--repo", d.Repo, "--head", branch, "--state", "open", "--json", "url", "--jq", ".[0].url")
+	if err != nil {
+		return "", "", fmt.Errorf("gh pr list failed: %w", err)
+	}
+	if open = strings.TrimSpace(open); open != "" {
+		return open, CascadeStatusSkipped, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gopush-remote-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cloneDir := filepath.Join(tmpDir, "repo")
+	if _, err := runner.Run("gh", "repo", "clone", d.Repo, cloneDir, "--", "--depth=1"); err != nil {
+		return "", "", fmt.Errorf("clone failed: %w", err)
+	}
+	modDir := filepath.Join(cloneDir, filepath.FromSlash(d.Dir))
+
+	if _, err := runner.Run("git", "-C", cloneDir, "checkout", "-b", branch); err != nil {
+		return "", "", fmt.Errorf("branch failed: %w", err)
+	}
+
+	for _, b := range bumps {
+		target := fmt.Sprintf("%s@%s", b.ModulePath, b.NewVersion)
+		name, args := g.proxyCommand("go", "-C", modDir, "get", target)
+		if _, err := runner.Run(name, args...); err != nil {
+			return "", "", fmt.Errorf("go get failed: %w", err)
+		}
+	}
+	name, args := g.proxyCommand("go", "-C", modDir, "mod", "tidy")
+	if _, err := runner.Run(name, args...); err != nil {
+		return "", "", fmt.Errorf("go mod tidy failed: %w", err)
+	}
+
+	porcelain, _ := runner.Run("git", "-C", cloneDir, "status", "--porcelain")
+	if strings.TrimSpace(porcelain) == "" {
+		return "", CascadeStatusSkipped, nil
+	}
+
+	commitMsg := gitmod.BuildDepsCommitMessage(bumps, rootCause)
+	modRel := path.Join(d.Dir, "go.mod")
+	sumRel := path.Join(d.Dir, "go.sum")
+	if _, err := runner.Run("git", "-C", cloneDir, "add", "--", modRel, sumRel); err != nil {
+		return "", "", fmt.Errorf("git add failed: %w", err)
+	}
+	name, args = g.gitCommand("-C", cloneDir, "commit", "-m", commitMsg)
+	if _, err := runner.Run(name, args...); err != nil {
+		return "", "", fmt.Errorf("git commit failed: %w", err)
+	}
+	// --force: a branch left by a run that failed before opening the PR
+	if _, err := runner.Run("git", "-C", cloneDir, "push", "--force", "-u", "origin", branch); err != nil {
+		return "", "", fmt.Errorf("git push failed: %w", err)
+	}
+
+	title := strings.SplitN(commitMsg, "\n", 2)[0]
+	out, err := runner.Run("gh", "pr", "create", "--repo", d.Repo, "--head", branch, "--title", title, "--body", commitMsg)
+	if err != nil {
+		return "", "", fmt.Errorf("gh pr create failed: %w", err)
+	}
+	return strings.TrimSpace(out), CascadeStatusPROpened, nil
+}
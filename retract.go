@@ -0,0 +1,107 @@
+package devflow
+
+import (
+	"fmt"
+	"strings"
+
+	gitmod "github.com/tinywasm/git"
+)
+
+// Retract publishes a new patch release whose go.mod retracts low (or the
+// range [low, high]) with rationale. When cascade is true, the local
+// dependents still requiring a retracted version are bumped past it; others
+// are left alone.
+func (g *Go) Retract(low, high, rationale string, cascade bool, searchPath string) (gitmod.PushResult, error) {
+	return g.retract(low, high, rationale, "", cascade, searchPath)
+}
+
+// retract is Retract publishing the retraction as tag ("" = planned from the
+// commits).
+func (g *Go) retract(low, high, rationale, tag string, cascade bool, searchPath string) (gitmod.PushResult, error) {
+	if high == "" {
+		high = low
+	}
+	for _, v := range []string{low, high} {
+		if !semverTagRe.MatchString(v) || !strings.HasPrefix(v, "v") {
+			return gitmod.PushResult{}, fmt.Errorf("invalid version %q: expected vX.Y.Z", v)
+		}
+	}
+	if gitmod.CompareVersions(low, high) > 0 {
+		return gitmod.PushResult{}, fmt.Errorf("invalid range [%s, %s]: low is greater than high", low, high)
+	}
+	if rationale == "" {
+		return gitmod.PushResult{}, fmt.Errorf("a rationale is required: it is shown to users by 'go list -m -retracted' and 'go get'")
+	}
+
+	gomod := NewGoModHandler()
+	gomod.SetRootDir(g.rootDir)
+	if !gomod.AddRetract(low, high, rationale) {
+		return gitmod.PushResult{}, fmt.Errorf("%s is already retracted (or go.mod is unreadable)", RetractEntry{Low: low, High: high})
+	}
+	if err := gomod.Save(); err != nil {
+		return gitmod.PushResult{}, fmt.Errorf("failed to save go.mod: %w", err)
+	}
+
+	message := fmt.Sprintf("fix: retract %s\n\n%s", RetractEntry{Low: low, High: high}, rationale)
+	res, err := g.Push(message, tag, false, false, true, false, false, false, searchPath)
+	if err != nil {
+		return res, err
+	}
+
+	if cascade && res.Tag != "" {
+		modulePath, err := g.GetModulePath()
+		if err != nil {
+			return res, nil
+		}
+		rootCause := releaseCause(modulePath, res.Tag, message)
+		g.bumpPastRetracted(modulePath, low, high, res.Tag, rootCause, searchPath)
+	}
+	return res, nil
+}
+
+// bumpPastRetracted updates the local dependents whose requirement on
+// modulePath falls in [low, high] to version.
+func (g *Go) bumpPastRetracted(modulePath, low, high, version, rootCause, searchPath string) CascadeReport {
+	report := CascadeReport{}
+	if searchPath == "" {
+		searchPath = ".."
+	}
+	dependents, err := g.FindDependentModules(modulePath, searchPath)
+	if err != nil || len(dependents) == 0 {
+		return report
+	}
+
+	var affected []string
+	for _, dir := range dependents {
+		cur, err := g.GetCurrentVersion(dir, modulePath)
+		if err != nil || gitmod.CompareVersions(cur, low) < 0 || gitmod.CompareVersions(cur, high) > 0 {
+			report.Entries = append(report.Entries, CascadeEntry{ModulePath: dependentDisplayName(dir), Status: CascadeStatusSkipped, Detail: "not on a retracted version"})
+			continue
+		}
+		affected = append(affected, dir)
+	}
+
+	if len(affected) > 0 {
+		if err := g.WaitForVersionAvailable(modulePath, version); err != nil {
+			g.consoleOutput(fmt.Sprintf("⏳ %s", err))
+			return report
+		}
+		g.consoleOutput(fmt.Sprintf("🚀 Bumping %d dependents past the retracted version...", len(affected)))
+	}
+	for _, dir := range affected {
+		entry := CascadeEntry{ModulePath: dependentDisplayName(dir)}
+		outcome, err := g.UpdateDependentModule(dir, []gitmod.DepBump{{ModulePath: modulePath, NewVersion: version}}, rootCause)
+		switch {
+		case err != nil:
+			entry.Status, entry.Detail = CascadeStatusFailed, err.Error()
+		case outcome.Status == CascadeStatusPublished:
+			entry.Status, entry.Detail = outcome.Status, outcome.Version
+		default:
+			entry.Status, entry.Detail = outcome.Status, outcome.Reason
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	g.printCascadeReport(report)
+	return report
+}
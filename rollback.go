@@ -0,0 +1,408 @@
+package devflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+	"golang.org/x/mod/module"
+)
+
+// PushRecordFile is the record of the last push, kept in the repository's
+// git directory (.git/devflow/last_push.json) for `gopush --rollback`.
+const PushRecordFile = "last_push.json"
+
+// PushedModule is a commit (and tag) published by a push.
+type PushedModule struct {
+	Dir        string `json:"dir"`
+	ModulePath string `json:"module_path,omitempty"`
+	Commit     string `json:"commit"`
+	Tag        string `json:"tag,omitempty"`
+}
+
+// PushRecord describes the last push of a repository: its release commit and
+// tag, the submodule tags created with it and the dependents the cascade
+// committed.
+type PushRecord struct {
+	PushedModule
+	SubmoduleTags []PushedModule `json:"submodule_tags,omitempty"`
+	Dependents    []PushedModule `json:"dependents,omitempty"`
+	Time          time.Time      `json:"time"`
+}
+
+// pushRecordPath returns the path of the push record of the repository in dir.
+func pushRecordPath(dir string) (string, error) {
+	path, err := command.RunInDir(dir, "git", "rev-parse", "--git-path", "devflow/"+PushRecordFile)
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
+}
+
+// LoadPushRecord reads the record of the last push of the repository in
+// rootDir. It returns nil (and no error) when there is none.
+func LoadPushRecord(rootDir string) (*PushRecord, error) {
+	path, err := pushRecordPath(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec PushRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &rec, nil
+}
+
+// savePushRecord writes rec as the last push of the repository. A push
+// that cannot be recorded is still a push: failures are only logged.
+func (g *Go) savePushRecord(rec *PushRecord) {
+	path, err := pushRecordPath(g.rootDir)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		data, _ := json.MarshalIndent(rec, "", "  ")
+		err = os.WriteFile(path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		g.log("Warning: could not record push for rollback:", err)
+	}
+}
+
+// newPushRecord records the commit just pushed from rootDir and resets the
+// dependents collected by the cascade.
+func (g *Go) newPushRecord(modulePath, tag string, submoduleTags []PushedModule) *PushRecord {
+	commit, _ := command.RunInDir(g.rootDir, "git", "rev-parse", "HEAD")
+	dir, _ := filepath.Abs(g.rootDir)
+
+	g.pushedMu.Lock()
+	g.pushedDependents = nil
+	g.pushedMu.Unlock()
+
+	rec := &PushRecord{
+		PushedModule:  PushedModule{Dir: dir, ModulePath: modulePath, Commit: commit, Tag: tag},
+		SubmoduleTags: submoduleTags,
+		Time:          time.Now().UTC(),
+	}
+	g.savePushRecord(rec)
+	return rec
+}
+
+// recordDependent adds the commit just pushed in a dependent to the record
+// of the running push. Dependents are updated in parallel.
+func (g *Go) recordDependent(dir, tag string) {
+	commit, err := command.RunInDir(dir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return
+	}
+	gomod := NewGoModHandler()
+	gomod.SetRootDir(dir)
+	modulePath, _ := gomod.GetModulePath()
+
+	g.pushedMu.Lock()
+	defer g.pushedMu.Unlock()
+	g.pushedDependents = append(g.pushedDependents, PushedModule{Dir: dir, ModulePath: modulePath, Commit: commit, Tag: tag})
+}
+
+// finishPushRecord saves rec with the dependents committed by the cascade.
+func (g *Go) finishPushRecord(rec *PushRecord) {
+	g.pushedMu.Lock()
+	rec.Dependents = append([]PushedModule(nil), g.pushedDependents...)
+	g.pushedMu.Unlock()
+	g.savePushRecord(rec)
+}
+
+// RollbackAction is what a rollback step does.
+type RollbackAction string
+
+const (
+	RollbackDeleteTag RollbackAction = "delete-tag" // tag not on the proxy yet: removed locally and on origin
+	RollbackRevert    RollbackAction = "revert"     // revert commit, pushed without a tag
+	RollbackRetract   RollbackAction = "retract"    // revert commit released with a retraction of the tag
+)
+
+// RollbackStep is one action of a RollbackPlan.
+type RollbackStep struct {
+	Action RollbackAction
+	PushedModule
+}
+
+func (s RollbackStep) String() string {
+	name := dependentDisplayName(s.Dir)
+	switch s.Action {
+	case RollbackDeleteTag:
+		return fmt.Sprintf("🗑  %s: delete tag %s (not published)", name, s.Tag)
+	case RollbackRetract:
+		return fmt.Sprintf("↩  %s: revert %s and retract %s (published)", name, shortCommit(s.Commit), s.Tag)
+	default:
+		return fmt.Sprintf("↩  %s: revert %s", name, shortCommit(s.Commit))
+	}
+}
+
+// RollbackPlan lists what Rollback will do, dependents first.
+type RollbackPlan struct {
+	Record PushRecord
+	Steps  []RollbackStep
+	Notes  []string // what is left as is, e.g. published dependent tags
+}
+
+func (p RollbackPlan) String() string {
+	lines := []string{fmt.Sprintf("Rollback of %s (%s, %s):", p.Record.displayName(), p.Record.Tag, p.Record.Time.Local().Format("2006-01-02 15:04"))}
+	for _, s := range p.Steps {
+		lines = append(lines, "  "+s.String())
+	}
+	for _, n := range p.Notes {
+		lines = append(lines, "  ⚠ "+n)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r PushRecord) displayName() string {
+	if r.ModulePath != "" {
+		return r.ModulePath
+	}
+	return filepath.Base(r.Dir)
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// PlanRollback builds the plan undoing the last recorded push. A tag the Go
+// proxy does not serve yet is deleted and its commit reverted, so the next
+// push does not release it again; a published one cannot be taken back, so
+// the release commit is reverted and the tag retracted in a new patch
+// release. Dependent bumps are reverted; their published tags stay.
+func (g *Go) PlanRollback() (RollbackPlan, error) {
+	rec, err := LoadPushRecord(g.rootDir)
+	if err != nil {
+		return RollbackPlan{}, err
+	}
+	if rec == nil {
+		return RollbackPlan{}, fmt.Errorf("no push recorded in %s", g.rootDir)
+	}
+	if err := g.loadPublishConfig(); err != nil {
+		return RollbackPlan{}, err
+	}
+	plan := RollbackPlan{Record: *rec}
+
+	for i := len(rec.Dependents) - 1; i >= 0; i-- {
+		dep := rec.Dependents[i]
+		if dep.Tag != "" {
+			if g.versionPublished(dep.ModulePath, dep.Tag) {
+				plan.Notes = append(plan.Notes, fmt.Sprintf("%s@%s stays published: the revert lands in its next release", dep.ModulePath, dep.Tag))
+			} else {
+				plan.Steps = append(plan.Steps, RollbackStep{Action: RollbackDeleteTag, PushedModule: dep})
+			}
+		}
+		plan.Steps = append(plan.Steps, RollbackStep{Action: RollbackRevert, PushedModule: PushedModule{Dir: dep.Dir, ModulePath: dep.ModulePath, Commit: dep.Commit}})
+	}
+
+	// Submodule tags usually point at the root's release commit, which the
+	// root step reverts: other commits are reverted here.
+	root := rec.PushedModule
+	for _, sub := range rec.SubmoduleTags {
+		version := sub.Tag[strings.LastIndex(sub.Tag, "/")+1:]
+		if g.versionPublished(sub.ModulePath, version) {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%s stays published: retract it with goretract in %s", sub.Tag, sub.Dir))
+			continue
+		}
+		plan.Steps = append(plan.Steps, RollbackStep{Action: RollbackDeleteTag, PushedModule: sub})
+		if sub.Commit != "" && sub.Commit != root.Commit {
+			plan.Steps = append(plan.Steps, RollbackStep{Action: RollbackRevert, PushedModule: PushedModule{Dir: sub.Dir, ModulePath: sub.ModulePath, Commit: sub.Commit}})
+		}
+	}
+
+	switch {
+	case root.Tag == "":
+		plan.Steps = append(plan.Steps, RollbackStep{Action: RollbackRevert, PushedModule: root})
+	case g.versionPublished(root.ModulePath, root.Tag):
+		plan.Steps = append(plan.Steps, RollbackStep{Action: RollbackRetract, PushedModule: root})
+	default:
+		plan.Steps = append(plan.Steps,
+			RollbackStep{Action: RollbackDeleteTag, PushedModule: root},
+			RollbackStep{Action: RollbackRevert, PushedModule: PushedModule{Dir: root.Dir, ModulePath: root.ModulePath, Commit: root.Commit}})
+	}
+	return plan, nil
+}
+
+// versionPublished reports whether a module proxy of GOPROXY lists
+// modulePath@version. It reads <proxy>/<module>/@v/list, which only shows
+// what the proxy already has: go list -m would make it fetch the version,
+// publishing the tag being checked. Modules matched by GONOPROXY come
+// straight from their repository, so no proxy keeps them; repositories that
+// are not Go modules are never published.
+func (g *Go) versionPublished(modulePath, version string) bool {
+	if modulePath == "" {
+		return false
+	}
+	out, err := runInDirEnv(g.rootDir, g.goEnv(), "go", "env", "GOPROXY", "GONOPROXY")
+	if err != nil {
+		return false
+	}
+	goproxy, noproxy, _ := strings.Cut(out, "\n")
+	if module.MatchPrefixPatterns(strings.TrimSpace(noproxy), modulePath) {
+		return false
+	}
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return false
+	}
+	for _, proxy := range strings.FieldsFunc(goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		if proxy == "direct" || proxy == "off" {
+			continue
+		}
+		list, err := readProxyList(proxy, escaped)
+		if err != nil {
+			g.log("Warning: could not read", proxy+":", err)
+			continue
+		}
+		if slices.Contains(strings.Fields(list), version) {
+			return true
+		}
+	}
+	return false
+}
+
+// readProxyList returns the @v/list of the escaped module path on proxy.
+func readProxyList(proxy, escaped string) (string, error) {
+	if root, ok := strings.CutPrefix(proxy, "file://"); ok {
+		data, err := os.ReadFile(filepath.Join(filepath.FromSlash(root), escaped, "@v", "list"))
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return string(data), err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(proxy, "/") + "/" + escaped + "/@v/list")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return "", nil
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// Rollback executes plan. rationale is recorded in the retraction, when the
+// plan has one. The push record is removed once every step succeeded, unless
+// the retraction release replaced it.
+func (g *Go) Rollback(plan RollbackPlan, rationale string) (string, error) {
+	if rationale == "" {
+		rationale = "Rolled back: do not use."
+	}
+	var summary []string
+	for _, s := range plan.Steps {
+		if err := g.rollbackStep(s, rationale); err != nil {
+			return strings.Join(summary, ", "), fmt.Errorf("%s: %w", s, err)
+		}
+		summary = append(summary, s.String())
+		g.consoleOutput(s.String() + " ✅")
+	}
+
+	if rec, err := LoadPushRecord(g.rootDir); err == nil && rec != nil && rec.Commit == plan.Record.Commit && rec.Time.Equal(plan.Record.Time) {
+		if path, err := pushRecordPath(g.rootDir); err == nil {
+			os.Remove(path)
+		}
+	}
+	return strings.Join(summary, ", "), nil
+}
+
+func (g *Go) rollbackStep(s RollbackStep, rationale string) error {
+	switch s.Action {
+	case RollbackDeleteTag:
+		if _, err := command.RunInDir(s.Dir, "git", "push", "origin", ":refs/tags/"+s.Tag); err != nil {
+			return fmt.Errorf("could not delete remote tag: %w", err)
+		}
+		command.RunInDir(s.Dir, "git", "tag", "-d", s.Tag)
+		return nil
+
+	case RollbackRevert:
+		if err := revertCommit(s.Dir, s.Commit); err != nil {
+			return err
+		}
+		git := g.git
+		if abs, _ := filepath.Abs(g.rootDir); s.Dir != abs {
+			client, err := gitmod.NewGit()
+			if err != nil {
+				return err
+			}
+			client.SetRootDir(s.Dir)
+			git = client
+		}
+		if _, err := git.PushWithoutTags(); err != nil {
+			return fmt.Errorf("push failed: %w", err)
+		}
+		return nil
+
+	case RollbackRetract:
+		head, err := command.RunInDir(s.Dir, "git", "rev-parse", "HEAD")
+		if err != nil {
+			return err
+		}
+		if err := revertCommit(s.Dir, s.Commit); err != nil {
+			return err
+		}
+		// The revert takes back whatever API the bad release added, and its
+		// commits do not decide the version: the retraction is the next patch
+		// release, without API gate.
+		tag, err := NextVersion(s.Tag, BumpPatch, "", nil)
+		if err == nil {
+			skip := g.skipAPICheck
+			g.skipAPICheck = true
+			_, err = g.retract(s.Tag, "", rationale, tag, false, "")
+			g.skipAPICheck = skip
+		}
+		if err != nil {
+			undoRevert(s.Dir, head)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown rollback action %q", s.Action)
+}
+
+// undoRevert drops the revert committed on top of head in dir, together with
+// the uncommitted retraction, unless the revert already reached upstream.
+func undoRevert(dir, head string) {
+	command.RunInDir(dir, "git", "checkout", "--", "go.mod")
+	if _, err := command.RunInDir(dir, "git", "merge-base", "--is-ancestor", "HEAD", "@{u}"); err == nil {
+		return
+	}
+	command.RunInDir(dir, "git", "reset", "-q", "--keep", head)
+}
+
+// revertCommit commits the revert of commit in dir; a conflicting revert is
+// aborted and leaves the tree as it was.
+func revertCommit(dir, commit string) error {
+	if _, err := command.RunInDir(dir, "git", "revert", "--no-edit", commit); err != nil {
+		command.RunInDir(dir, "git", "revert", "--abort")
+		return fmt.Errorf("git revert %s failed: %w", shortCommit(commit), err)
+	}
+	return nil
+}
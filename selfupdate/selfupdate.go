@@ -0,0 +1,400 @@
+// Package selfupdate implements --self-update for commands released with
+// gorelease: it finds the asset of the running platform in the latest GitHub
+// release, checks it against the release's checksums.txt (and checksums.txt
+// against its minisign signature when a public key is set) and atomically
+// replaces the running executable.
+//
+//	var Version string // injected by gorelease: -X main.Version=<tag>
+//
+//	if os.Args[1] == "--self-update" {
+//		u := &selfupdate.Updater{Repo: "acme/tool", Version: Version}
+//		tag, err := u.Update()
+//		...
+//	}
+//
+// It depends on the standard library only (and golang.org/x/mod/semver), so
+// commands can embed it without pulling devflow.
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/template"
+
+	"golang.org/x/mod/semver"
+)
+
+// DefaultName is the asset name template of gorelease, without extension.
+const DefaultName = "{{.Cmd}}-{{.OS}}-{{.Arch}}"
+
+// Target is a release platform; it converts from devflow.CrossTarget.
+type Target struct{ GOOS, GOARCH, Variant string }
+
+// BuildTarget is the release target of the running binary, "os/arch" or
+// "os/arch/variant"; gorelease sets it through TargetSymbol.
+var BuildTarget string
+
+// TargetSymbol is the linker symbol of BuildTarget: -X TargetSymbol=linux/arm/7.
+const TargetSymbol = "github.com/tinywasm/devflow/selfupdate.BuildTarget"
+
+// variantSettings are the build settings holding the GOARCH sub-architecture.
+var variantSettings = map[string]string{
+	"386":      "GO386",
+	"amd64":    "GOAMD64",
+	"arm":      "GOARM",
+	"arm64":    "GOARM64",
+	"mips":     "GOMIPS",
+	"mipsle":   "GOMIPS",
+	"mips64":   "GOMIPS64",
+	"mips64le": "GOMIPS64",
+	"ppc64":    "GOPPC64",
+	"ppc64le":  "GOPPC64",
+	"riscv64":  "GORISCV64",
+	"wasm":     "GOWASM",
+}
+
+// defaultVariants are the sub-architectures the toolchain records when none
+// is set: a release target without variant.
+var defaultVariants = map[string]string{
+	"386":      "sse2",
+	"amd64":    "v1",
+	"arm":      "7",
+	"arm64":    "v8.0",
+	"mips":     "hardfloat",
+	"mipsle":   "hardfloat",
+	"mips64":   "hardfloat",
+	"mips64le": "hardfloat",
+	"ppc64":    "power8",
+	"ppc64le":  "power8",
+	"riscv64":  "rva20u64",
+}
+
+// Current returns the platform of the running binary: BuildTarget when
+// gorelease set it, else the variant of the build info unless it is the
+// toolchain default (GOAMD64=v1 is "amd64").
+func Current() Target {
+	t := Target{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH}
+	if parts := strings.Split(BuildTarget, "/"); len(parts) >= 2 && parts[0] == t.GOOS && parts[1] == t.GOARCH {
+		if len(parts) == 3 {
+			t.Variant = parts[2]
+		}
+		return t
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == variantSettings[t.GOARCH] && s.Value != defaultVariants[t.GOARCH] {
+				t.Variant = s.Value
+			}
+		}
+	}
+	return t
+}
+
+// ArchName is the architecture in asset names: "amd64", "armv7", "amd64v3".
+func (t Target) ArchName() string {
+	switch {
+	case t.Variant == "":
+		return t.GOARCH
+	case t.Variant[0] >= '0' && t.Variant[0] <= '9':
+		return t.GOARCH + "v" + t.Variant
+	case t.Variant[0] == 'v':
+		return t.GOARCH + t.Variant
+	}
+	return t.GOARCH + "_" + t.Variant // "mips_softfloat"
+}
+
+// BinaryExt is the extension of executables: ".exe" on windows, ".wasm"
+// for wasm.
+func (t Target) BinaryExt() string {
+	switch {
+	case t.GOOS == "windows":
+		return ".exe"
+	case t.GOARCH == "wasm":
+		return ".wasm"
+	}
+	return ""
+}
+
+// Updater replaces the running command with the latest release of Repo.
+type Updater struct {
+	Repo    string // GitHub "owner/name" publishing the releases
+	Version string // version of the running binary: main.Version
+	Cmd     string // command name; default the executable name
+	Name    string // archive name template of .devflow/release.json; default DefaultName
+	Binary  bool   // assets are bare executables ("format": "binary")
+	Force   bool   // update even when up to date or the version is unknown
+	Target  Target // default Current()
+	// PublicKey is the minisign public key of the releases (.devflow/release.pub
+	// or its base64 line): when set, checksums.txt.sig must verify.
+	PublicKey string
+
+	Executable string       // file to replace; default os.Executable()
+	APIURL     string       // default https://api.github.com
+	Client     *http.Client // default http.DefaultClient
+}
+
+// Release is the latest release of the repository.
+type Release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// Latest returns the latest release and whether it is newer than Version.
+func (u *Updater) Latest() (*Release, bool, error) {
+	api := u.APIURL
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	data, err := u.get(api + "/repos/" + u.Repo + "/releases/latest")
+	if err != nil {
+		return nil, false, err
+	}
+	var r Release
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, false, fmt.Errorf("invalid release of %s: %w", u.Repo, err)
+	}
+	if !semver.IsValid(r.Tag) {
+		return nil, false, fmt.Errorf("latest release of %s has no semver tag: %q", u.Repo, r.Tag)
+	}
+	return &r, semver.IsValid(u.Version) && semver.Compare(r.Tag, u.Version) > 0, nil
+}
+
+// Update installs the latest release in place of the executable and returns
+// its tag, "" when already up to date. The asset is checked against the
+// release's checksums.txt, signed by PublicKey when set, before anything is
+// replaced.
+func (u *Updater) Update() (string, error) {
+	if !semver.IsValid(u.Version) && !u.Force {
+		return "", fmt.Errorf("unknown current version %q (built without -X main.Version)", u.Version)
+	}
+	r, newer, err := u.Latest()
+	if err != nil {
+		return "", err
+	}
+	if !newer && !u.Force {
+		return "", nil
+	}
+
+	exe, err := u.executable()
+	if err != nil {
+		return "", err
+	}
+	cmd := u.Cmd
+	if cmd == "" {
+		cmd = strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe))
+	}
+	target := u.Target
+	if target == (Target{}) {
+		target = Current()
+	}
+	asset, err := u.assetName(cmd, r.Tag, target)
+	if err != nil {
+		return "", err
+	}
+
+	url := func(name string) string {
+		for _, a := range r.Assets {
+			if a.Name == name {
+				return a.URL
+			}
+		}
+		return ""
+	}
+	if url(asset) == "" {
+		return "", fmt.Errorf("release %s has no asset %s for %s/%s", r.Tag, asset, target.GOOS, target.ArchName())
+	}
+	if url("checksums.txt") == "" {
+		return "", fmt.Errorf("release %s has no checksums.txt", r.Tag)
+	}
+
+	sums, err := u.get(url("checksums.txt"))
+	if err != nil {
+		return "", err
+	}
+	if u.PublicKey != "" {
+		if url("checksums.txt.sig") == "" {
+			return "", fmt.Errorf("release %s has no checksums.txt.sig", r.Tag)
+		}
+		sig, err := u.get(url("checksums.txt.sig"))
+		if err != nil {
+			return "", err
+		}
+		if _, err := VerifySignature(u.PublicKey, sums, sig); err != nil {
+			return "", fmt.Errorf("checksums.txt of %s: %w", r.Tag, err)
+		}
+	}
+	data, err := u.get(url(asset))
+	if err != nil {
+		return "", err
+	}
+	if err := verify(sums, asset, data); err != nil {
+		return "", err
+	}
+
+	bin := data
+	if !u.Binary {
+		if bin, err = extract(asset, data, cmd+target.BinaryExt()); err != nil {
+			return "", err
+		}
+	}
+	if err := replace(exe, bin); err != nil {
+		return "", err
+	}
+	return r.Tag, nil
+}
+
+func (u *Updater) executable() (string, error) {
+	exe := u.Executable
+	if exe == "" {
+		var err error
+		if exe, err = os.Executable(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// assetName renders the asset of cmd for target, as gorelease names it.
+func (u *Updater) assetName(cmd, tag string, target Target) (string, error) {
+	if u.Binary {
+		return cmd + "-" + target.GOOS + "-" + target.ArchName() + target.BinaryExt(), nil
+	}
+	name := u.Name
+	if name == "" {
+		name = DefaultName
+	}
+	tmpl, err := template.New("asset").Option("missingkey=error").Parse(name)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fields := struct{ Cmd, Version, OS, Arch string }{cmd, tag, target.GOOS, target.ArchName()}
+	if err := tmpl.Execute(&b, fields); err != nil {
+		return "", err
+	}
+	if target.GOOS == "windows" {
+		return b.String() + ".zip", nil
+	}
+	return b.String() + ".tar.gz", nil
+}
+
+func (u *Updater) get(url string) ([]byte, error) {
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verify checks data against the line of name in checksums.txt.
+func verify(checksums []byte, name string, data []byte) error {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		want, file, ok := strings.Cut(strings.TrimSpace(line), "  ")
+		if !ok || file != name {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != want {
+			return fmt.Errorf("%s does not match checksums.txt", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not listed in checksums.txt", name)
+}
+
+// extract returns the file named bin at the root of the archive.
+func extract(asset string, data []byte, bin string) ([]byte, error) {
+	if strings.HasSuffix(asset, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.Name == bin {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("%s not found in %s", bin, asset)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in %s", bin, asset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == bin && hdr.Typeflag == tar.TypeReg {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// replace writes bin next to exe and renames it over exe, so exe is either
+// the old or the new binary. On windows the running executable cannot be
+// overwritten but can be renamed: it is moved to exe.old first.
+func replace(exe string, bin []byte) error {
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(exe); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".new-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), exe)
+}
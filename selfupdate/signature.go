@@ -0,0 +1,77 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// VerifySignature checks the minisign signature sig of data against the
+// public key (a minisign public key file, or its base64 line) and returns
+// the trusted comment.
+func VerifySignature(publicKey string, data, sig []byte) (string, error) {
+	pub, err := decodeMinisign(minisignKeyLine(publicKey), 42)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(sig))
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("invalid signature file")
+	}
+	s, err := decodeMinisign(lines[1], 74)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature: %w", err)
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return "", errors.New("invalid signature: bad trusted comment signature")
+	}
+	if string(s[:2]) != "Ed" {
+		return "", fmt.Errorf("unsupported signature algorithm %q (prehashed signatures are not supported)", s[:2])
+	}
+	if !bytes.Equal(s[2:10], pub[2:10]) {
+		return "", fmt.Errorf("signature made with key %016X, not %016X", binary.LittleEndian.Uint64(s[2:10]), binary.LittleEndian.Uint64(pub[2:10]))
+	}
+	key := ed25519.PublicKey(pub[10:])
+	if !ed25519.Verify(key, data, s[10:]) {
+		return "", errors.New("signature verification failed")
+	}
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(key, append(append([]byte(nil), s[10:]...), comment...), global) {
+		return "", errors.New("trusted comment verification failed")
+	}
+	return comment, nil
+}
+
+// decodeMinisign decodes a base64 key or signature of n bytes.
+func decodeMinisign(line string, n int) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != n {
+		return nil, fmt.Errorf("expected %d bytes, got %d", n, len(raw))
+	}
+	return raw, nil
+}
+
+// minisignKeyLine returns the last line of s that is not a comment.
+func minisignKeyLine(s string) string {
+	var last string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+			last = line
+		}
+	}
+	return last
+}
@@ -0,0 +1,364 @@
+package devflow
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// SigningFile is the optional signing configuration in .devflow/.
+const SigningFile = "signing.json"
+
+// SigningConfig configures commit and tag signing for Push and the cascade.
+//
+//	{"format": "ssh", "key": "~/.ssh/id_ed25519.pub", "required": true}
+//
+// Format is git's gpg.format ("openpgp" when empty, "ssh" or "x509"). Key is
+// user.signingKey (a GPG key id, or the path of an SSH public key); when empty
+// the git config of the repository is used. AllowedSigners is the
+// gpg.ssh.allowedSignersFile SSH signatures are verified against; when empty
+// the git config's is used, or else only Key is trusted.
+type SigningConfig struct {
+	Format         string `json:"format,omitempty"`
+	Key            string `json:"key,omitempty"`
+	AllowedSigners string `json:"allowed_signers,omitempty"`
+	Required       bool   `json:"required,omitempty"` // refuse to publish when signing is unavailable
+}
+
+// LoadSigningConfig reads rootDir/.devflow/signing.json. It returns nil (and
+// no error) when the file does not exist: signing is off.
+func LoadSigningConfig(rootDir string) (*SigningConfig, error) {
+	var cfg SigningConfig
+	found, err := readDevflowConfig(rootDir, SigningFile, &cfg)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// SetSigning enables commit and tag signing (nil: Push reads
+// .devflow/signing.json, signing is off without it). Dependents published by
+// the cascade inherit the configuration.
+func (g *Go) SetSigning(cfg *SigningConfig) {
+	g.signing = cfg
+}
+
+// gitConfig returns the git settings that make every commit signed.
+// Tags are signed explicitly (git tag -s) by pushSigned.
+func (c *SigningConfig) gitConfig() [][2]string {
+	cfg := [][2]string{{"commit.gpgSign", "true"}}
+	if c.Format != "" {
+		cfg = append(cfg, [2]string{"gpg.format", c.Format})
+	}
+	if c.Key != "" {
+		cfg = append(cfg, [2]string{"user.signingKey", expandHome(c.Key)})
+	}
+	return cfg
+}
+
+// enableSigning checks that signing is possible and marks it active: from
+// then on the git commands of the push run with gitEnv, so the commits made
+// by devflow, by hooks and by cascade dependents are signed too. The process
+// environment is left alone. The returned func turns signing off again.
+func (g *Go) enableSigning() (func(), error) {
+	if err := g.checkSigningAvailable(); err != nil {
+		if g.signing.Required {
+			return nil, fmt.Errorf("signing is required but unavailable: %w", err)
+		}
+		g.consoleOutput(fmt.Sprintf("⚠ Signing unavailable, publishing unsigned: %v", err))
+		return func() {}, nil
+	}
+
+	g.signingActive = true
+	return func() { g.signingActive = false }, nil
+}
+
+// signingVars returns the signing git config as GIT_CONFIG_COUNT/KEY/VALUE
+// variables, after any the process environment already has (nil when signing
+// is not active).
+func (g *Go) signingVars() []string {
+	if !g.signingActive {
+		return nil
+	}
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	var vars []string
+	for _, kv := range g.signing.gitConfig() {
+		vars = append(vars,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, kv[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, kv[1]))
+		n++
+	}
+	return append(vars, "GIT_CONFIG_COUNT="+strconv.Itoa(n))
+}
+
+// gitEnv returns the environment of the git commands of a push: the process
+// environment plus the signing config when signing is active, and extra.
+func (g *Go) gitEnv(extra ...string) []string {
+	return append(append(os.Environ(), g.signingVars()...), extra...)
+}
+
+// gitCommand prefixes a git command run through a gitmod.Runner, which only
+// takes a command line, with the signing config (env NAME=value git ...).
+func (g *Go) gitCommand(args ...string) (string, []string) {
+	vars := g.signingVars()
+	if len(vars) == 0 {
+		return "git", args
+	}
+	return "env", append(append(vars, "git"), args...)
+}
+
+// commit stages everything and commits it, reporting false when there was
+// nothing to commit. With signing active the commit is made by devflow with
+// gitEnv (the git client only uses the process environment); otherwise by the
+// git client.
+func (g *Go) commit(message string) (bool, error) {
+	if !g.signingActive {
+		if err := g.git.Add(); err != nil {
+			return false, fmt.Errorf("git add failed: %w", err)
+		}
+		return g.git.Commit(message)
+	}
+	return g.commitSigned(g.rootDir, message)
+}
+
+// commitSigned commits paths (everything when empty) in dir with gitEnv,
+// reporting false when there was nothing to commit.
+func (g *Go) commitSigned(dir, message string, paths ...string) (bool, error) {
+	add := []string{"add", "-A"}
+	if len(paths) > 0 {
+		add = append([]string{"add", "--"}, paths...)
+	}
+	if _, err := runInDirEnv(dir, nil, "git", add...); err != nil {
+		return false, fmt.Errorf("git add failed: %w", err)
+	}
+	if _, err := runInDirEnv(dir, nil, "git", append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		return false, nil
+	}
+	if _, err := runInDirEnv(dir, g.gitEnv(), "git", append([]string{"commit", "-q", "-m", message, "--"}, paths...)...); err != nil {
+		return false, fmt.Errorf("git commit failed: %w", err)
+	}
+	return true, nil
+}
+
+// checkSigningAvailable reports why commits could not be signed: missing
+// signing program or key.
+func (g *Go) checkSigningAvailable() error {
+	format := g.signing.Format
+	if format == "" {
+		format, _ = command.RunInDir(g.rootDir, "git", "config", "--get", "gpg.format")
+	}
+	key := expandHome(g.signing.Key)
+	if key == "" {
+		key, _ = command.RunInDir(g.rootDir, "git", "config", "--get", "user.signingKey")
+	}
+
+	switch strings.TrimSpace(format) {
+	case "ssh":
+		if _, err := exec.LookPath("ssh-keygen"); err != nil {
+			return fmt.Errorf("ssh-keygen not found")
+		}
+		if key == "" {
+			return fmt.Errorf("no SSH signing key (set \"key\" in %s/%s or git config user.signingKey)", DevflowConfigDir, SigningFile)
+		}
+		if !strings.HasPrefix(key, "key::") && !strings.HasPrefix(key, "ssh-") {
+			if _, err := os.Stat(key); err != nil {
+				return fmt.Errorf("SSH signing key %s not found", key)
+			}
+		}
+	case "x509":
+		if _, err := exec.LookPath("gpgsm"); err != nil {
+			return fmt.Errorf("gpgsm not found")
+		}
+	default:
+		program, _ := command.RunInDir(g.rootDir, "git", "config", "--get", "gpg.program")
+		if program == "" {
+			program = "gpg"
+		}
+		if _, err := exec.LookPath(program); err != nil {
+			return fmt.Errorf("%s not found", program)
+		}
+	}
+	return nil
+}
+
+// pushSigned is the git client's Push with a signed annotated tag: the commit
+// and the tag (`git tag -s`) are made by devflow with gitEnv, and both
+// signatures are checked before anything is pushed.
+func (g *Go) pushSigned(message, tag string) (gitmod.PushResult, error) {
+	if tag == "" {
+		next, err := g.git.GenerateNextTag()
+		if err != nil {
+			return gitmod.PushResult{}, fmt.Errorf("failed to generate next tag: %w", err)
+		}
+		tag = next
+	}
+
+	committed, err := g.commitSigned(g.rootDir, message)
+	if err != nil {
+		return gitmod.PushResult{}, err
+	}
+	// Nothing new to release: HEAD is already tagged
+	if !committed {
+		if tags, _ := command.RunInDir(g.rootDir, "git", "tag", "--points-at", "HEAD"); tags != "" {
+			return gitmod.PushResult{Summary: "No changes to commit"}, nil
+		}
+	}
+	if _, err := runInDirEnv(g.rootDir, g.gitEnv(), "git", "tag", "-s", "-m", tag, tag); err != nil {
+		return gitmod.PushResult{}, fmt.Errorf("signed tag %s failed: %w", tag, err)
+	}
+	if err := g.VerifySignedTag(tag); err != nil {
+		command.RunInDir(g.rootDir, "git", "tag", "-d", tag)
+		return gitmod.PushResult{}, err
+	}
+
+	pulled, err := g.git.PushWithTags(tag)
+	if err != nil {
+		return gitmod.PushResult{}, fmt.Errorf("push failed: %w", err)
+	}
+	summary := fmt.Sprintf("Pushed ✅, 🔏 signed tag %s", tag)
+	if pulled {
+		summary = "🔄 Pulled remote changes, " + summary
+	}
+	return gitmod.PushResult{Summary: summary, Tag: tag}, nil
+}
+
+// pushRelease runs the tagged push: signed when signing is active.
+func (g *Go) pushRelease(message, tag string) (gitmod.PushResult, error) {
+	if g.signingActive {
+		return g.pushSigned(message, tag)
+	}
+	return g.git.Push(message, tag)
+}
+
+// VerifySignedTag returns an error unless tag is an annotated tag whose
+// signature and the signature of its commit verify (`git verify-tag`,
+// `git verify-commit`): against the keyring for GPG, against
+// gpg.ssh.allowedSignersFile for SSH.
+func (g *Go) VerifySignedTag(tag string) error {
+	kind, err := command.RunInDir(g.rootDir, "git", "cat-file", "-t", "refs/tags/"+tag)
+	if err != nil {
+		return fmt.Errorf("tag %s not found: %w", tag, err)
+	}
+	if kind != "tag" {
+		return fmt.Errorf("tag %s is a lightweight tag: signed tags must be annotated", tag)
+	}
+
+	config, cleanup, err := g.allowedSignersConfig()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if _, err := command.RunInDir(g.rootDir, "git", append(config, "verify-tag", tag)...); err != nil {
+		return fmt.Errorf("tag %s: signature not verified: %w", tag, err)
+	}
+	if _, err := command.RunInDir(g.rootDir, "git", append(config, "verify-commit", tag+"^{commit}")...); err != nil {
+		return fmt.Errorf("commit tagged %s: signature not verified: %w", tag, err)
+	}
+	return nil
+}
+
+// allowedSignersConfig returns the git -c arguments setting
+// gpg.ssh.allowedSignersFile: the configured AllowedSigners, nothing when git
+// has one, else a temporary file trusting only the SSH signing key. The
+// returned func removes the temporary file.
+func (g *Go) allowedSignersConfig() ([]string, func(), error) {
+	noop := func() {}
+	if g.signing != nil && g.signing.AllowedSigners != "" {
+		return []string{"-c", "gpg.ssh.allowedSignersFile=" + expandHome(g.signing.AllowedSigners)}, noop, nil
+	}
+	if file, _ := command.RunInDir(g.rootDir, "git", "config", "--get", "gpg.ssh.allowedSignersFile"); file != "" {
+		return nil, noop, nil
+	}
+
+	var key string
+	if g.signing != nil {
+		key = expandHome(g.signing.Key)
+	}
+	if key == "" {
+		key, _ = command.RunInDir(g.rootDir, "git", "config", "--get", "user.signingKey")
+	}
+	pub := sshPublicKey(key)
+	if pub == "" {
+		return nil, noop, nil
+	}
+
+	f, err := os.CreateTemp("", "devflow-allowed-signers-*")
+	if err != nil {
+		return nil, noop, err
+	}
+	_, err = fmt.Fprintf(f, "* namespaces=\"git\" %s\n", pub)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, noop, err
+	}
+	return []string{"-c", "gpg.ssh.allowedSignersFile=" + f.Name()}, func() { os.Remove(f.Name()) }, nil
+}
+
+// sshPublicKey returns the "<type> <base64>" SSH public key of a
+// user.signingKey value: a literal key ("key::" prefixed or not) or the path
+// of a public key, or of a private key next to its .pub. Returns "" for
+// anything else, e.g. a GPG key id.
+func sshPublicKey(key string) string {
+	literal := func(s string) string {
+		fields := strings.Fields(s)
+		if len(fields) < 2 || !(strings.HasPrefix(fields[0], "ssh-") || strings.HasPrefix(fields[0], "ecdsa-") || strings.HasPrefix(fields[0], "sk-")) {
+			return ""
+		}
+		return fields[0] + " " + fields[1]
+	}
+	key = strings.TrimPrefix(key, "key::")
+	if pub := literal(key); pub != "" {
+		return pub
+	}
+	for _, path := range []string{key, key + ".pub"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if pub := literal(string(data)); pub != "" {
+				return pub
+			}
+		}
+	}
+	return ""
+}
+
+// inheritSigning passes an active signing setup to a dependent handler, which
+// then signs without checking the setup again.
+func (g *Go) inheritSigning(dep *Go) {
+	if g.signingActive {
+		dep.signing = g.signing
+		dep.signingActive = true
+	}
+}
+
+// expandHome expands a leading "~/" to the user's home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
+
+// checkReleaseTag verifies that tag is signed and exists on origin.
+func (g *Go) checkReleaseTag(tag string) error {
+	if err := g.VerifySignedTag(tag); err != nil {
+		return err
+	}
+	out, err := command.RunInDir(g.rootDir, "git", "ls-remote", "--tags", "origin", "refs/tags/"+tag)
+	if err != nil {
+		return fmt.Errorf("could not check origin for tag %s: %w", tag, err)
+	}
+	if strings.TrimSpace(out) == "" {
+		return fmt.Errorf("signed tag %s is not pushed to origin", tag)
+	}
+	return nil
+}
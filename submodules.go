@@ -0,0 +1,313 @@
+package devflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// SubmoduleRelease is the release of an independently versioned submodule:
+// a nested go.mod tagged with its directory as prefix ("cmd/x/v0.3.1"), the
+// form the go command resolves for modules in a repository subdirectory.
+type SubmoduleRelease struct {
+	Dir        string // absolute module directory
+	ModulePath string
+	TagPrefix  string // "cmd/x/": directory relative to the repository root
+	Previous   string // latest released version, without prefix ("" = never released)
+	Next       string
+	Reason     string // commit or dependency bump that decided the release
+}
+
+// Tag returns the prefixed tag of the release, e.g. "cmd/x/v0.3.1".
+func (r SubmoduleRelease) Tag() string {
+	return r.TagPrefix + r.Next
+}
+
+// SetTagSubmodules makes Push version every nested module independently,
+// including those never tagged before. Without it only the submodules that
+// already have a prefixed tag are released.
+func (g *Go) SetTagSubmodules(tag bool) {
+	g.tagSubmodules = tag
+}
+
+// PlanSubmoduleReleases returns the independently versioned submodules that
+// changed since their latest prefixed tag, in intra-repo dependency order.
+// released maps the module paths already being released (the parent) to
+// their new version: a submodule requiring an older version of a released
+// module is released too, and so on down the chain. message is the commit
+// about to be made; it counts for the submodules it touches.
+func (g *Go) PlanSubmoduleReleases(message string, released map[string]string) ([]SubmoduleRelease, error) {
+	dirs, err := nestedModules(g.rootDir)
+	if err != nil || len(dirs) == 0 {
+		return nil, err
+	}
+	top, err := command.RunInDir(g.rootDir, "git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, nil // outside a repository there is nothing to tag
+	}
+	top = evalPath(top)
+	rels := map[string]string{} // dir → path relative to top
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(top, evalPath(dir)); err == nil && !ignoredModuleDir(rel) {
+			rels[dir] = filepath.ToSlash(rel)
+		}
+	}
+
+	candidates := map[string]*SubmoduleRelease{}
+	requires := map[string]map[string]string{}
+	var nodes []CascadeNode
+	for _, dir := range dirs {
+		rel, ok := rels[dir]
+		if !ok {
+			continue
+		}
+		gomod := NewGoModHandler()
+		gomod.SetRootDir(dir)
+		modulePath, err := gomod.GetModulePath()
+		if err != nil {
+			continue
+		}
+		prefix := submoduleTagPrefix(rel, modulePath)
+		previous := g.latestPrefixedTag(top, prefix)
+		if previous == "" && !g.tagSubmodules {
+			continue // not independently versioned
+		}
+
+		node := CascadeNode{Dir: dir, ModulePath: modulePath}
+		requires[modulePath] = map[string]string{}
+		entries, _ := gomod.GetRequires()
+		for _, req := range entries {
+			node.DependsOn = append(node.DependsOn, req.ModulePath)
+			requires[modulePath][req.ModulePath] = req.Version
+		}
+		nodes = append(nodes, node)
+		candidates[modulePath] = &SubmoduleRelease{Dir: dir, ModulePath: modulePath, TagPrefix: prefix, Previous: previous}
+	}
+
+	sorted, err := topologicalSort(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for path, v := range released {
+		versions[path] = v
+	}
+	var plan []SubmoduleRelease
+	for _, node := range sorted {
+		r := candidates[node.ModulePath]
+		pathspecs := submodulePathspecs(rels[node.Dir], node.Dir, rels)
+
+		level, trigger, changed, err := g.submoduleChanges(top, r, pathspecs, message)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range node.DependsOn {
+			if v, ok := versions[dep]; ok && requires[node.ModulePath][dep] != v {
+				if !changed {
+					level, trigger = BumpPatch, fmt.Sprintf("deps: %s %s", dep, v)
+				}
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if level == BumpMajor {
+			if r.Previous == "" || strings.HasPrefix(r.Previous, "v0.") {
+				level, trigger = BumpMinor, "breaking change on v0: "+trigger
+			} else {
+				next, _ := NextVersion(r.Previous, BumpMajor, "", nil)
+				suffix := "/" + strings.SplitN(next, ".", 2)[0]
+				if !strings.HasSuffix(r.ModulePath, suffix) {
+					return nil, fmt.Errorf("submodule %s: breaking change (%s) needs %s, but its module path has no %s suffix", r.ModulePath, trigger, next, suffix)
+				}
+			}
+		}
+
+		out, _ := command.RunInDir(top, "git", "tag", "--list", r.TagPrefix+"v*")
+		var existing []string
+		for _, t := range strings.Fields(out) {
+			existing = append(existing, strings.TrimPrefix(t, r.TagPrefix))
+		}
+		r.Next, err = NextVersion(r.Previous, level, g.preRelease, existing)
+		if err != nil {
+			return nil, fmt.Errorf("submodule %s: %w", r.ModulePath, err)
+		}
+		r.Reason = trigger
+		versions[r.ModulePath] = r.Next
+		plan = append(plan, *r)
+	}
+	return plan, nil
+}
+
+// submoduleChanges reports whether the files of r changed since its latest
+// tag (uncommitted and untracked files included) and the bump level asked
+// for by the commits touching them.
+func (g *Go) submoduleChanges(top string, r *SubmoduleRelease, pathspecs []string, message string) (BumpLevel, string, bool, error) {
+	logRange := "HEAD"
+	changed := r.Previous == ""
+	if !changed {
+		tag := r.TagPrefix + r.Previous
+		diff, err := command.RunInDir(top, "git", append([]string{"diff", "--name-only", tag, "--"}, pathspecs...)...)
+		if err != nil {
+			return 0, "", false, fmt.Errorf("could not diff %s: %w", tag, err)
+		}
+		untracked, _ := command.RunInDir(top, "git", append([]string{"ls-files", "--others", "--exclude-standard", "--"}, pathspecs...)...)
+		changed = diff != "" || untracked != ""
+		logRange = tag + "..HEAD"
+	}
+	if !changed {
+		return 0, "", false, nil
+	}
+
+	var messages []string
+	if status, _ := command.RunInDir(top, "git", append([]string{"status", "--porcelain", "--"}, pathspecs...)...); status != "" {
+		messages = append(messages, message)
+	}
+	out, _ := command.RunInDir(top, "git", append([]string{"log", "--format=%B%x1e", logRange, "--"}, pathspecs...)...)
+	for _, m := range strings.Split(out, "\x1e") {
+		if m = strings.TrimSpace(m); m != "" {
+			messages = append(messages, m)
+		}
+	}
+
+	level, trigger := BumpPatch, "files changed"
+	for i, m := range messages {
+		if l := CommitBumpLevel(m); i == 0 || l > level {
+			header, _, _ := strings.Cut(m, "\n")
+			level, trigger = l, strings.TrimSpace(header)
+		}
+	}
+	return level, trigger, true, nil
+}
+
+// latestPrefixedTag returns the highest release version tagged with prefix,
+// without the prefix.
+func (g *Go) latestPrefixedTag(top, prefix string) string {
+	out, err := command.RunInDir(top, "git", "tag", "--list", prefix+"v*")
+	if err != nil {
+		return ""
+	}
+	latest := ""
+	for _, t := range strings.Fields(out) {
+		v := strings.TrimPrefix(t, prefix)
+		if semverTagRe.MatchString(v) && strings.HasPrefix(v, "v") && (latest == "" || gitmod.CompareVersions(v, latest) > 0) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// submoduleTagPrefix returns the tag prefix of the module at rel. A major
+// version subdirectory ("tools/v2" for ".../tools/v2") is not part of it:
+// the go command looks up tools/v2.x.y.
+func submoduleTagPrefix(rel, modulePath string) string {
+	if m := majorSuffixRe.FindString(modulePath); m != "" && strings.HasSuffix("/"+rel, m) {
+		rel = strings.TrimSuffix("/"+rel, m)
+		rel = strings.TrimPrefix(rel, "/")
+	}
+	if rel == "" {
+		return ""
+	}
+	return rel + "/"
+}
+
+// submodulePathspecs returns the git pathspecs of the module at rel: its
+// directory minus the modules nested inside it.
+func submodulePathspecs(rel, dir string, rels map[string]string) []string {
+	specs := []string{rel}
+	for other, otherRel := range rels {
+		if other != dir && strings.HasPrefix(otherRel, rel+"/") {
+			specs = append(specs, ":(exclude)"+otherRel)
+		}
+	}
+	return specs
+}
+
+// ignoredModuleDir reports whether rel lies in a directory the go command
+// ignores (testdata, vendor, names starting with "." or "_").
+func ignoredModuleDir(rel string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part == "testdata" || part == "vendor" || strings.HasPrefix(part, ".") || strings.HasPrefix(part, "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// evalPath resolves symlinks so paths compare with git's toplevel.
+func evalPath(path string) string {
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		return p
+	}
+	return path
+}
+
+// prepareSubmoduleReleases plans the submodule releases for a parent release
+// at nextTag and points the submodules at the new versions of the submodules
+// they require, so the changes land in the release commit.
+func (g *Go) prepareSubmoduleReleases(message, modulePath, nextTag string) ([]SubmoduleRelease, error) {
+	released := map[string]string{}
+	if modulePath != "" && nextTag != "" {
+		released[modulePath] = nextTag
+	}
+	plan, err := g.PlanSubmoduleReleases(message, released)
+	if err != nil || len(plan) == 0 {
+		return nil, err
+	}
+
+	versions := map[string]SubmoduleRelease{}
+	for _, r := range plan {
+		versions[r.ModulePath] = r
+	}
+	dirs, err := nestedModules(g.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		for _, r := range plan {
+			if dir == r.Dir || !g.HasDependency(filepath.Join(dir, "go.mod"), r.ModulePath) {
+				continue
+			}
+			if err := g.pinInternalRequire(dir, r.Dir, r.ModulePath, versions[r.ModulePath].Next); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return plan, nil
+}
+
+// pushSubmoduleTags tags HEAD (the release commit) for every planned
+// submodule and pushes the tags. Signed when signing is active, and then
+// verified like the root tag before anything is pushed.
+func (g *Go) pushSubmoduleTags(plan []SubmoduleRelease) (string, error) {
+	var tags []string
+	for _, r := range plan {
+		tag := r.Tag()
+		flag := "-a"
+		if g.signingActive {
+			flag = "-s"
+		}
+		if _, err := runInDirEnv(g.rootDir, g.gitEnv(), "git", "tag", flag, "-m", tag, tag); err != nil {
+			return "", fmt.Errorf("tag %s failed: %w", tag, err)
+		}
+		tags = append(tags, tag)
+		if g.signingActive {
+			if err := g.VerifySignedTag(tag); err != nil {
+				for _, t := range tags {
+					command.RunInDir(g.rootDir, "git", "tag", "-d", t)
+				}
+				return "", err
+			}
+		}
+	}
+	if _, err := command.RunInDir(g.rootDir, "git", append([]string{"push", "origin"}, tags...)...); err != nil {
+		return "", fmt.Errorf("pushing submodule tags failed: %w", err)
+	}
+	return "🏷 " + strings.Join(tags, ", "), nil
+}
@@ -0,0 +1,149 @@
+package devflow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newAPIRepo creates a git repo for github.com/test/lib tagged at tag with
+// the given lib.go, then replaces lib.go in the working tree with head.
+func newAPIRepo(t *testing.T, tag, base, head string) string {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte(base), 0644)
+	os.MkdirAll(filepath.Join(dir, "internal", "x"), 0755)
+	os.WriteFile(filepath.Join(dir, "internal", "x", "x.go"), []byte("package x\n\nfunc Gone() {}\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, tag)
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte(head), 0644)
+	os.WriteFile(filepath.Join(dir, "internal", "x", "x.go"), []byte("package x\n"), 0644)
+	return dir
+}
+
+const apiBase = `package lib
+
+type T struct{ A int }
+
+func (T) M() {}
+
+type I interface{ Do() }
+
+func Foo(s string) error { return nil }
+func Bar()                {}
+`
+
+func TestDiffAPI_ClassifiesChanges(t *testing.T) {
+	dir := newAPIRepo(t, "v1.0.0", apiBase, `package lib
+
+type T struct{ A, B int }
+
+func (T) M() {}
+func (T) N() {}
+
+type I interface{ Do(); Undo() }
+
+func Foo(s string, n int) error { return nil }
+func Baz()                       {}
+`)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	diff, err := g.DiffAPI("v1.0.0")
+	if err != nil {
+		t.Fatalf("DiffAPI: %v", err)
+	}
+
+	var incompatible, compatible []string
+	for _, c := range diff.Incompatible {
+		incompatible = append(incompatible, c.Symbol)
+	}
+	for _, c := range diff.Compatible {
+		compatible = append(compatible, c.Symbol)
+	}
+	if got := strings.Join(incompatible, " "); got != "Bar Foo I" {
+		t.Errorf("incompatible = %q, want removed Bar, changed Foo and I (internal/ excluded)", got)
+	}
+	if got := strings.Join(compatible, " "); got != "Baz T.B T.N" {
+		t.Errorf("compatible = %q, want added Baz, field T.B, method T.N", got)
+	}
+	if !strings.Contains(diff.String(), "- func Bar()") {
+		t.Errorf("diff must show the removed declaration, got:\n%s", diff.String())
+	}
+}
+
+func TestPush_RefusesPatchForIncompatibleAPI(t *testing.T) {
+	dir := newAPIRepo(t, "v1.0.0", apiBase, strings.Replace(apiBase, "func Bar()                {}\n", "", 1))
+
+	mockGit := &MockGitClient{latestTag: "v1.0.0"}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	_, err := g.Push("fix: tidy", "v1.0.1", true, true, true, true, false, true, "")
+	if err == nil || !strings.Contains(err.Error(), "Bar") {
+		t.Fatalf("expected incompatible API error naming Bar, got %v", err)
+	}
+	if mockGit.LastPushTag != "" {
+		t.Errorf("nothing must be pushed, got tag %q", mockGit.LastPushTag)
+	}
+
+	g.SetSkipAPICheck(true)
+	if _, err := g.Push("fix: tidy", "v1.0.1", true, true, true, true, false, true, ""); err != nil {
+		t.Fatalf("--skip-apicheck must bypass the gate, got %v", err)
+	}
+	if mockGit.LastPushTag != "v1.0.1" {
+		t.Errorf("expected push of v1.0.1, got %q", mockGit.LastPushTag)
+	}
+}
+
+func TestPush_CompatibleAPIInSummary(t *testing.T) {
+	dir := newAPIRepo(t, "v1.0.0", apiBase, apiBase+"\nfunc Extra() {}\n")
+
+	mockGit := &MockGitClient{latestTag: "v1.0.0"}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	res, err := g.Push("feat: extra", "v1.1.0", true, true, true, true, false, true, "")
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if !strings.Contains(res.Summary, "API compatible with v1.0.0 (+1)") {
+		t.Errorf("summary must report the API check, got %q", res.Summary)
+	}
+}
+
+func TestDiffAPI_IgnoresParameterNames(t *testing.T) {
+	dir := newAPIRepo(t, "v1.0.0", apiBase+"\ntype H func(name string) (n int, err error)\n",
+		strings.Replace(apiBase, "Foo(s string)", "Foo(text string)", 1)+"\ntype H func(string) (int, error)\n")
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	diff, err := g.DiffAPI("v1.0.0")
+	if err != nil {
+		t.Fatalf("DiffAPI: %v", err)
+	}
+	if !diff.IsCompatible() || len(diff.Compatible) != 0 {
+		t.Errorf("renamed parameters must not change the API, got:\n%s", diff.String())
+	}
+}
+
+func TestPush_RefusesWhenAPICheckFails(t *testing.T) {
+	dir := newAPIRepo(t, "v1.0.0", apiBase, apiBase+"\nfunc Broken() { undefined() }\n")
+
+	mockGit := &MockGitClient{latestTag: "v1.0.0"}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	_, err := g.Push("feat: broken", "v1.1.0", true, true, true, true, false, true, "")
+	if err == nil || !strings.Contains(err.Error(), "API check against v1.0.0 failed") {
+		t.Fatalf("expected the API check to fail closed, got %v", err)
+	}
+	if mockGit.LastPushTag != "" {
+		t.Errorf("nothing must be pushed, got tag %q", mockGit.LastPushTag)
+	}
+}
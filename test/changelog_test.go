@@ -0,0 +1,195 @@
+package devflow_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/devflow"
+	gitmod "github.com/tinywasm/git"
+)
+
+func TestBuildChangelog_Categories(t *testing.T) {
+	date := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	c := devflow.BuildChangelog("v1.3.0", date, []string{
+		"feat(api): add Foo",
+		"fix: nil map in Bar",
+		"refactor!: drop Baz\n\nBREAKING CHANGE: use Qux instead",
+		gitmod.DepsCommitPrefix + "update github.com/acme/lib to v0.4.0\n\n" + gitmod.CauseLinePrefix + "feat: new router",
+		"docs: readme",
+	})
+
+	if len(c.Features) != 1 || c.Features[0] != "**api:** add Foo" {
+		t.Errorf("features = %v", c.Features)
+	}
+	if len(c.Fixes) != 1 || c.Fixes[0] != "nil map in Bar" {
+		t.Errorf("fixes = %v", c.Fixes)
+	}
+	if len(c.Breaking) != 1 || c.Breaking[0] != "drop Baz — use Qux instead" {
+		t.Errorf("breaking = %v", c.Breaking)
+	}
+	if len(c.Deps) != 1 || !strings.Contains(c.Deps[0], "feat: new router") {
+		t.Errorf("deps must carry the upstream cause, got %v", c.Deps)
+	}
+	if len(c.Other) != 1 {
+		t.Errorf("other = %v", c.Other)
+	}
+
+	md := c.Markdown()
+	for _, want := range []string{"## v1.3.0 (2026-10-18)", "### ⚠ Breaking changes", "### Features", "### Fixes", "### Dependencies", "### Other changes"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Index(md, "Breaking") > strings.Index(md, "Features") {
+		t.Errorf("breaking changes must come first:\n%s", md)
+	}
+}
+
+func TestPush_PrependsChangelogSection(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	os.WriteFile(filepath.Join(dir, devflow.ChangelogFile), []byte("# Changelog\n\n## v1.0.0 (2026-01-01)\n\n### Features\n\n- first\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v1.0.0")
+	testGitCommitMsg(t, dir, "fix: handle empty input")
+
+	mockGit := &MockGitClient{latestTag: "v1.0.0"}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	if _, err := g.Push("feat: add Foo", "v1.1.0", true, true, true, true, false, true, ""); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, devflow.ChangelogFile))
+	got := string(data)
+	if !strings.HasPrefix(got, "# Changelog\n\n## v1.1.0 (") {
+		t.Fatalf("new section must be prepended under the title, got:\n%s", got)
+	}
+	if !strings.Contains(got, "- add Foo") || !strings.Contains(got, "- handle empty input") {
+		t.Errorf("section must include the pushed message and the commits since v1.0.0:\n%s", got)
+	}
+	if !strings.Contains(got, "## v1.0.0 (2026-01-01)") || strings.Count(got, "# Changelog") != 1 {
+		t.Errorf("previous sections must be kept once:\n%s", got)
+	}
+}
+
+func TestPush_ChangelogBaseIncludesOriginTags(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "lib")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "")
+	testGitCommitMsg(t, dir, "feat: released before")
+	// v1.0.0 was published from another clone: only origin has it
+	for _, args := range [][]string{
+		{"clone", "-q", "--bare", dir, filepath.Join(tmp, "lib.git")},
+		{"-C", dir, "remote", "add", "origin", filepath.Join(tmp, "lib.git")},
+		{"-C", dir, "tag", "v1.0.0"},
+		{"-C", dir, "push", "-q", "origin", "v1.0.0"},
+		{"-C", dir, "tag", "-d", "v1.0.0"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	testGitCommitMsg(t, dir, "fix: handle empty input")
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	if _, err := g.Push("feat: add Foo", "v1.1.0", true, true, true, true, false, true, ""); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, devflow.ChangelogFile))
+	if got := string(data); !strings.Contains(got, "- handle empty input") || strings.Contains(got, "released before") {
+		t.Errorf("the section must start at origin's v1.0.0:\n%s", got)
+	}
+}
+
+func TestPush_ChangelogWrittenOnceAfterGates(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	base := "# Changelog\n\n## v1.0.0 (2026-01-01)\n\n### Features\n\n- first\n"
+	os.WriteFile(filepath.Join(dir, devflow.ChangelogFile), []byte(base), 0644)
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n\nfunc Bar() {}\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v1.0.0")
+
+	mockGit := &MockGitClient{latestTag: "v1.0.0"}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	// Removing Bar is incompatible: the API gate refuses a minor version
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n\nfunc Foo() {}\n"), 0644)
+	if _, err := g.Push("feat: add Foo", "v1.1.0", true, true, true, true, false, true, ""); err == nil || !strings.Contains(err.Error(), "incompatible API changes") {
+		t.Fatalf("expected the API gate to fail the push, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, devflow.ChangelogFile)); string(data) != base {
+		t.Fatalf("a failed gate must leave the changelog untouched, got:\n%s", data)
+	}
+
+	// A section left by an earlier failed push is replaced.
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n\nfunc Bar() {}\n\nfunc Foo() {}\n"), 0644)
+	os.WriteFile(filepath.Join(dir, devflow.ChangelogFile), []byte("# Changelog\n\n## v1.1.0 (2026-01-02)\n\n### Features\n\n- stale\n\n"+strings.TrimPrefix(base, "# Changelog\n\n")), 0644)
+	if _, err := g.Push("feat: add Foo", "v1.1.0", true, true, true, true, false, true, ""); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, devflow.ChangelogFile))
+	got := string(data)
+	if strings.Count(got, "## v1.1.0") != 1 || strings.Contains(got, "- stale") || !strings.Contains(got, "- add Foo") {
+		t.Errorf("the v1.1.0 section must be replaced, got:\n%s", got)
+	}
+	if !strings.Contains(got, "## v1.0.0 (2026-01-01)") {
+		t.Errorf("previous sections must be kept:\n%s", got)
+	}
+}
+
+func TestReleaseOnly_SetsChangelogAsReleaseNotes(t *testing.T) {
+	cleanup := createAppDir(t, "mytool", "mytool")
+	defer cleanup()
+	os.WriteFile(devflow.ChangelogFile, []byte("# Changelog\n\n## v1.1.0 (2026-10-18)\n\n### Fixes\n\n- second\n\n## v1.0.0 (2026-01-01)\n\n- first\n"), 0644)
+
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		if isRepoView(args) {
+			return `{"owner":{"login":"acme"},"name":"mytool","visibility":"PUBLIC"}`, nil
+		}
+		return "https://github.com/acme/mytool/releases/tag/v1.1.0", nil
+	}}
+
+	goHandler, _ := devflow.NewGo(&MockGitClient{latestTag: "v1.1.0"})
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+	if err := goHandler.ReleaseOnly("", newGitHubWithRunner(runner)); err != nil {
+		t.Fatalf("ReleaseOnly failed: %v", err)
+	}
+
+	edit := findCall(runner.calls, "release", "edit", "v1.1.0", "--notes")
+	if edit == nil {
+		t.Fatalf("expected `gh release edit v1.1.0 --notes ...`, calls: %v", runner.calls)
+	}
+	notes := edit[4]
+	if !strings.Contains(notes, "- second") || strings.Contains(notes, "- first") {
+		t.Errorf("notes must be the v1.1.0 section only, got:\n%s", notes)
+	}
+
+	// A release left without its notes fails the step.
+	failing := &scriptedRunner{respond: func(args []string) (string, error) {
+		if findCall([][]string{args}, "release", "edit") != nil {
+			return "", fmt.Errorf("HTTP 502")
+		}
+		return runner.respond(args)
+	}}
+	err := goHandler.ReleaseOnly("", newGitHubWithRunner(failing))
+	if err == nil || !strings.Contains(err.Error(), "notes") {
+		t.Errorf("a failed notes update must fail the release, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testGitCommitAll commits everything in dir (which must already be a repo)
+// and optionally tags the commit.
+func testGitCommitAll(t *testing.T, dir, tag string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if tag != "" {
+		if out, err := exec.Command("git", "-C", dir, "tag", tag).CombinedOutput(); err != nil {
+			t.Fatalf("git tag: %v\n%s", err, out)
+		}
+	}
+}
+
+func testGitInit(t *testing.T, dir string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@test.com"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestWorkspaceDrift_BehindReplaceDirty(t *testing.T) {
+	tmp := t.TempDir()
+
+	libDir := testWriteModule(t, tmp, "lib")
+	testGitInit(t, libDir)
+	testGitCommitAll(t, libDir, "v0.3.0")
+
+	appDir := testWriteModule(t, tmp, "app", "lib")
+	os.WriteFile(filepath.Join(appDir, "go.mod"), []byte(
+		"module github.com/test/app\n\ngo 1.20\n\nrequire github.com/test/lib v0.0.1\n\nreplace github.com/test/other => ../other\n"), 0644)
+	testGitInit(t, appDir)
+	testGitCommitAll(t, appDir, "v0.1.0")
+	os.WriteFile(filepath.Join(appDir, "new.go"), []byte("package app\n"), 0644)
+
+	g := newCascadeHandler(t, libDir)
+
+	report, err := g.WorkspaceDrift(tmp)
+	if err != nil {
+		t.Fatalf("WorkspaceDrift: %v", err)
+	}
+
+	if len(report.Modules) != 2 {
+		t.Fatalf("expected 2 modules, got %d: %+v", len(report.Modules), report.Modules)
+	}
+
+	app := report.Modules[0]
+	if app.ModulePath != "github.com/test/app" {
+		t.Fatalf("expected modules sorted by path, first is %s", app.ModulePath)
+	}
+	if len(app.Behind) != 1 || app.Behind[0].Required != "v0.0.1" || app.Behind[0].Latest != "v0.3.0" {
+		t.Errorf("expected app behind on lib v0.0.1 → v0.3.0, got %+v", app.Behind)
+	}
+	if len(app.Replaces) != 1 || app.Replaces[0].ModulePath != "github.com/test/other" {
+		t.Errorf("expected one lingering replace, got %+v", app.Replaces)
+	}
+	if !app.Dirty {
+		t.Error("expected app to be reported dirty")
+	}
+
+	lib := report.Modules[1]
+	if lib.Issues() != 0 {
+		t.Errorf("expected lib to be clean, got %+v", lib)
+	}
+
+	if report.Issues() != 3 {
+		t.Errorf("expected 3 issues, got %d", report.Issues())
+	}
+	if !report.Exceeds(2) || report.Exceeds(3) {
+		t.Errorf("Exceeds threshold mismatch for %d issues", report.Issues())
+	}
+
+	table := report.Table()
+	for _, want := range []string{"behind", "v0.0.1 → v0.3.0", "replace", "dirty", "2 modules, 3 drift issues"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("table missing %q:\n%s", want, table)
+		}
+	}
+}
+
+func TestWorkspaceDrift_SelfReplaceIsNotDrift(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "tests")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte(
+		"module github.com/test/tests\n\ngo 1.20\n\nreplace github.com/test/tests => ./\n"), 0644)
+
+	g := newCascadeHandler(t, t.TempDir())
+
+	report, err := g.WorkspaceDrift(tmp)
+	if err != nil {
+		t.Fatalf("WorkspaceDrift: %v", err)
+	}
+	if report.Issues() != 0 {
+		t.Errorf("self-referencing replace must not count as drift, got %s", report.Table())
+	}
+}
+
+func TestWorkspaceDrift_SubmoduleUsesPrefixedTags(t *testing.T) {
+	tmp := t.TempDir()
+
+	// repo: root module tagged v1.0.0, submodule sub tagged sub/v0.2.0
+	repo := filepath.Join(tmp, "repo")
+	os.MkdirAll(filepath.Join(repo, "sub"), 0755)
+	os.WriteFile(filepath.Join(repo, "go.mod"), []byte("module github.com/test/repo\n\ngo 1.20\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "sub", "go.mod"), []byte("module github.com/test/repo/sub\n\ngo 1.20\n"), 0644)
+	testGitInit(t, repo)
+	testGitCommitAll(t, repo, "v1.0.0")
+	if out, err := exec.Command("git", "-C", repo, "tag", "sub/v0.2.0").CombinedOutput(); err != nil {
+		t.Fatalf("git tag: %v\n%s", err, out)
+	}
+
+	appDir := testWriteModule(t, tmp, "app")
+	os.WriteFile(filepath.Join(appDir, "go.mod"), []byte(
+		"module github.com/test/app\n\ngo 1.20\n\nrequire (\n\tgithub.com/test/repo v1.0.0\n\tgithub.com/test/repo/sub v0.1.0\n)\n"), 0644)
+
+	g := newCascadeHandler(t, t.TempDir())
+	report, err := g.WorkspaceDrift(tmp)
+	if err != nil {
+		t.Fatalf("WorkspaceDrift: %v", err)
+	}
+	for _, m := range report.Modules {
+		if m.ModulePath != "github.com/test/app" {
+			continue
+		}
+		if len(m.Behind) != 1 || m.Behind[0].ModulePath != "github.com/test/repo/sub" || m.Behind[0].Latest != "v0.2.0" {
+			t.Errorf("expected app behind on sub v0.1.0 → v0.2.0 only, got %+v", m.Behind)
+		}
+		return
+	}
+	t.Fatalf("app not in the report: %+v", report.Modules)
+}
@@ -0,0 +1,80 @@
+package devflow_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeGenerateModule creates a committed module whose generator writes
+// gen.go with const V = 2, while the committed gen.go still says 1.
+func writeGenerateModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module github.com/test/lib\n\ngo 1.20\n",
+		"lib.go": "package lib\n\n//go:generate sh -c \"printf 'package lib\\\\n\\\\nconst V = 2\\\\n' > gen.go\"\n",
+		"gen.go": "package lib\n\nconst V = 1\n",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v0.1.0")
+	return dir
+}
+
+func TestStaleGenerated(t *testing.T) {
+	dir := writeGenerateModule(t)
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+
+	stale, err := g.StaleGenerated()
+	if err != nil {
+		t.Fatalf("StaleGenerated: %v", err)
+	}
+	if !reflect.DeepEqual(stale, []string{"gen.go"}) {
+		t.Errorf("stale = %v, want [gen.go]", stale)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "gen.go")); !strings.Contains(string(data), "V = 1") {
+		t.Errorf("working tree must not be touched, gen.go is:\n%s", data)
+	}
+
+	// Uncommitted regeneration is what gets committed: nothing is stale.
+	os.WriteFile(filepath.Join(dir, "gen.go"), []byte("package lib\n\nconst V = 2\n"), 0644)
+	if stale, err := g.StaleGenerated(); err != nil || len(stale) != 0 {
+		t.Errorf("expected no stale files after regenerating, got %v, %v", stale, err)
+	}
+}
+
+func TestPush_RefusesStaleGeneratedFiles(t *testing.T) {
+	dir := writeGenerateModule(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# lib\n"), 0644)
+
+	mockGit := &MockGitClient{}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	writeHooks(t, dir, `{"pre-tag": [{"run": "touch hooked"}]}`)
+
+	_, err := g.Push("docs: readme", "v0.1.1", true, true, true, true, false, true, "")
+	if err == nil || !strings.Contains(err.Error(), "gen.go") {
+		t.Fatalf("expected stale gen.go error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hooked")); err == nil {
+		t.Error("the check must run before the pre-tag hooks edit the tree")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "CHANGELOG.md")); len(data) != 0 {
+		t.Errorf("a refused push must not write the changelog, got:\n%s", data)
+	}
+	if mockGit.LastPushTag != "" {
+		t.Errorf("nothing must be pushed, got tag %s", mockGit.LastPushTag)
+	}
+
+	g.SetSkipGenerateCheck(true)
+	if _, err := g.Push("docs: readme", "v0.1.1", true, true, true, true, false, true, ""); err != nil {
+		t.Errorf("Push with the check skipped: %v", err)
+	}
+}
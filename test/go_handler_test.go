@@ -337,6 +337,8 @@ func TestUpdateDependentModule(t *testing.T) {
 
 	// Create myapp
 	os.WriteFile(filepath.Join(myappDir, "go.mod"), []byte("module github.com/test/myapp\n\ngo 1.20\n\nrequire github.com/test/mylib v0.0.0\nreplace github.com/test/mylib => ../mylib\n"), 0644)
+	// Imported, so go mod tidy must resolve the bumped version
+	os.WriteFile(filepath.Join(myappDir, "main.go"), []byte("package main\n\nimport _ \"github.com/test/mylib\"\n\nfunc main() {}\n"), 0644)
 
 	// Init git in myapp (needed for Push)
 	runGit := func(dir string, args ...string) {
@@ -362,7 +364,7 @@ func TestUpdateDependentModule(t *testing.T) {
 	neutralDir := t.TempDir()
 	defer testChdir(t, neutralDir)()
 
-	// Disable proxy so "go get" fails instantly without network requests
+	// Disable proxy so "go mod tidy" fails instantly without network requests
 	t.Setenv("GOPROXY", "off")
 
 	mockGit := &MockGitClient{}
@@ -371,17 +373,17 @@ func TestUpdateDependentModule(t *testing.T) {
 
 	result, err := g.UpdateDependentModule(myappDir, []gitmod.DepBump{{ModulePath: "github.com/test/mylib", NewVersion: "v0.0.1"}}, "")
 
-	// We expect a failure at "go get" because the module doesn't exist in registry
+	// We expect a failure at "go mod tidy" because the module doesn't exist in registry
 	if err == nil {
-		t.Errorf("Expected error from go get (module not in registry), got result: %+v", result)
-	} else if !strings.Contains(err.Error(), "go get failed") {
-		t.Errorf("Expected error to contain 'go get failed', got: %v", err)
+		t.Errorf("Expected error from go mod tidy (module not in registry), got result: %+v", result)
+	} else if !strings.Contains(err.Error(), "go mod tidy failed") {
+		t.Errorf("Expected error to contain 'go mod tidy failed', got: %v", err)
 	}
 
-	// However, we can verify that the replace was removed BEFORE the go get failure
+	// However, we can verify that the replace was removed BEFORE the tidy failure
 	gomodContent, _ := os.ReadFile(filepath.Join(myappDir, "go.mod"))
 	if strings.Contains(string(gomodContent), "replace github.com/test/mylib") {
-		t.Error("replace directive should have been removed even if go get failed later")
+		t.Error("replace directive should have been removed even if go mod tidy failed later")
 	}
 }
 
@@ -487,6 +489,7 @@ type MockGitClient struct {
 	statusPorcelainOut    string
 	diffShortStatOut      string
 	CommitPathsCalls      [][]string
+	nothingToCommit       bool // Commit reports no changes
 }
 
 func (m *MockGitClient) CheckRemoteAccess() error {
@@ -543,7 +546,7 @@ func (m *MockGitClient) Add() error {
 
 func (m *MockGitClient) Commit(message string) (bool, error) {
 	m.CommitCalls++
-	return true, nil
+	return !m.nothingToCommit, nil
 }
 
 func (m *MockGitClient) CreateTag(tag string) (bool, error) {
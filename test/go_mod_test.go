@@ -256,6 +256,25 @@ replace github.com/test/other => ../other
 			t.Error("expected false when no replaces exist")
 		}
 	})
+
+	t.Run("ParseErrors", func(t *testing.T) {
+		os.WriteFile(gomodPath, []byte("module test\nreplace github.com/test/lib =>\n"), 0644)
+
+		gm := devflow.NewGoModHandler()
+		gm.SetRootDir(tmp)
+		if _, err := gm.RemoveReplaceErr("github.com/test/lib"); err == nil {
+			t.Error("RemoveReplaceErr must return the parse error")
+		}
+		if _, err := gm.HasOtherReplacesErr(); err == nil {
+			t.Error("HasOtherReplacesErr must return the parse error")
+		}
+		if _, err := gm.EnsureReplaceErr("github.com/test/lib", "../lib"); err == nil {
+			t.Error("EnsureReplaceErr must return the parse error")
+		}
+		if gm.RemoveReplace("github.com/test/lib") || gm.HasOtherReplaces() {
+			t.Error("the bool variants must report false on a parse error")
+		}
+	})
 }
 
 func TestGetLocalReplacePaths(t *testing.T) {
@@ -308,3 +327,81 @@ replace github.com/test/lib4 => ../lib4
 		}
 	})
 }
+
+func TestGoModHandler_TypedEdits(t *testing.T) {
+	tmp := t.TempDir()
+	content := `// Package comment kept.
+module github.com/test/app
+
+go 1.22
+
+require (
+	github.com/test/lib v1.0.0 // pinned for the router
+	github.com/test/other v0.3.0
+)
+
+exclude github.com/test/bad v0.1.0
+`
+	os.WriteFile(filepath.Join(tmp, "go.mod"), []byte(content), 0644)
+
+	gm := devflow.NewGoModHandler()
+	gm.SetRootDir(tmp)
+
+	if err := gm.SetRequire("github.com/test/lib", "v1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.SetRequire("github.com/test/new", "v0.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.DropRequire("github.com/test/other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.DropExclude("github.com/test/bad", "v0.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.AddExclude("github.com/test/bad", "v0.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.SetToolchain("go1.25.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.SetGodebug("panicnil", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.SetRequire("github.com/test/lib", "not-a-version"); err == nil {
+		t.Error("expected an error for a non-canonical version")
+	}
+	if err := gm.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(tmp, "go.mod"))
+	got := string(data)
+	for _, want := range []string{
+		"// Package comment kept.",
+		"github.com/test/lib v1.2.0 // pinned for the router",
+		"github.com/test/new v0.1.0",
+		"exclude github.com/test/bad v0.2.0",
+		"toolchain go1.25.2",
+		"godebug panicnil=1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "github.com/test/other") || strings.Contains(got, "v0.1.0\n\nexclude github.com/test/bad v0.1.0") {
+		t.Errorf("dropped directives still present:\n%s", got)
+	}
+
+	gm = devflow.NewGoModHandler()
+	gm.SetRootDir(tmp)
+	if v := gm.GetRequire("github.com/test/lib"); v != "v1.2.0" {
+		t.Errorf("GetRequire = %q", v)
+	}
+	if gm.GetToolchain() != "go1.25.2" || gm.GetGodebug("panicnil") != "1" {
+		t.Errorf("toolchain/godebug not read back: %q %q", gm.GetToolchain(), gm.GetGodebug("panicnil"))
+	}
+	if err := gm.SetRequire("github.com/test/lib", "v1.2.0"); err != nil || gm.Modified {
+		t.Errorf("re-setting the same version must be a no-op (err=%v, modified=%v)", err, gm.Modified)
+	}
+}
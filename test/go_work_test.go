@@ -0,0 +1,147 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// testWriteGoWork writes <tmp>/go.work using the given module folders.
+func testWriteGoWork(t *testing.T, tmp string, uses ...string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("go 1.22\n\nuse (\n")
+	for _, u := range uses {
+		b.WriteString("\t./" + u + " // local checkout\n")
+	}
+	b.WriteString(")\n")
+	if err := os.WriteFile(filepath.Join(tmp, "go.work"), []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildDependentGraph_GoWorkUsesAreTheUniverse(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	tmp := t.TempDir()
+	mainDir := testWriteModule(t, tmp, "main")
+	testWriteModule(t, tmp, "b", "main")
+	testWriteModule(t, tmp, "outside", "main") // depends on main, but not in go.work
+	testWriteGoWork(t, tmp, "main", "b")
+
+	g := newCascadeHandler(t, mainDir)
+
+	nodes, err := g.BuildDependentGraph("github.com/test/main", tmp)
+	if err != nil {
+		t.Fatalf("BuildDependentGraph: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].ModulePath != "github.com/test/b" {
+		t.Fatalf("expected only workspace module b, got %+v", nodes)
+	}
+
+	deps, err := g.FindDependentModules("github.com/test/main", tmp)
+	if err != nil {
+		t.Fatalf("FindDependentModules: %v", err)
+	}
+	if len(deps) != 1 || filepath.Base(deps[0]) != "b" {
+		t.Errorf("expected dependents [b], got %v", deps)
+	}
+
+	// The workspace entries outside searchPath are left out.
+	if deps, _ := g.FindDependentModules("github.com/test/main", t.TempDir()); len(deps) != 0 {
+		t.Errorf("expected no dependents outside searchPath, got %v", deps)
+	}
+}
+
+func TestBuildDependentGraph_GoWorkOffWalksSearchPath(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	tmp := t.TempDir()
+	mainDir := testWriteModule(t, tmp, "main")
+	testWriteModule(t, tmp, "b", "main")
+	testWriteModule(t, tmp, "outside", "main")
+	testWriteGoWork(t, tmp, "main", "b")
+
+	g := newCascadeHandler(t, mainDir)
+
+	nodes, err := g.BuildDependentGraph("github.com/test/main", tmp)
+	if err != nil {
+		t.Fatalf("BuildDependentGraph: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("GOWORK=off must fall back to walking searchPath, got %+v", nodes)
+	}
+}
+
+// TestUpdateDependentModule_GoCommandsRunWithGoworkOff: the bump of a
+// dependent must compute go.mod/go.sum for the module alone, never through
+// the developer's go.work.
+func TestUpdateDependentModule_GoCommandsRunWithGoworkOff(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	tmp := t.TempDir()
+	depDir := filepath.Join(tmp, "myapp")
+	os.MkdirAll(depDir, 0755)
+	os.WriteFile(filepath.Join(depDir, "go.mod"), []byte("module github.com/test/myapp\n\ngo 1.20\n\nrequire github.com/test/mylib v0.0.0\n"), 0644)
+	os.WriteFile(filepath.Join(depDir, "wip.go"), []byte("package myapp // WIP\n"), 0644)
+	testWriteGoWork(t, tmp, "myapp")
+
+	var mu sync.Mutex
+	var goCalls []string
+	originalExec := command.Exec
+	defer func() { command.Exec = originalExec }()
+	command.Exec = func(name string, args ...string) *exec.Cmd {
+		joined := strings.Join(args, " ")
+		switch name {
+		case "git":
+			switch {
+			case strings.HasPrefix(joined, "status --porcelain"):
+				return exec.Command("echo", "?? wip.go")
+			case strings.HasPrefix(joined, "diff"):
+				return exec.Command("false")
+			default:
+				return exec.Command("true")
+			}
+		case "go", "gotest":
+			if joined == "version" {
+				return exec.Command("echo", "go version go1.20 linux/amd64")
+			}
+			if joined == "mod verify" {
+				return exec.Command("true")
+			}
+			mu.Lock()
+			goCalls = append(goCalls, name+" "+joined)
+			mu.Unlock()
+			// Fails unless the caller exported GOWORK=off.
+			return exec.Command("sh", "-c", `test "$GOWORK" = off`)
+		}
+		return originalExec(name, args...)
+	}
+
+	mockGit := &MockGitClient{}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetConsoleOutput(func(string) {})
+	g.SetRetryConfig(time.Millisecond, 1)
+
+	if _, err := g.UpdateDependentModule(depDir, []gitmod.DepBump{{ModulePath: "github.com/test/mylib", NewVersion: "v0.0.1"}}, ""); err != nil {
+		t.Fatalf("dependent update must run go commands with GOWORK=off, got: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var sawGet, sawTidy bool
+	for _, c := range goCalls {
+		sawGet = sawGet || strings.HasPrefix(c, "go get ")
+		sawTidy = sawTidy || c == "go mod tidy"
+	}
+	if sawGet || !sawTidy {
+		t.Errorf("expected go mod tidy (the requirement is edited in go.mod, no go get), got %v", goCalls)
+	}
+	if data, _ := os.ReadFile(filepath.Join(depDir, "go.mod")); !strings.Contains(string(data), "require github.com/test/mylib v0.0.1") {
+		t.Errorf("requirement must be bumped in go.mod, got:\n%s", data)
+	}
+}
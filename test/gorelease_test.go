@@ -97,9 +97,12 @@ func TestReleaseOnly_MultipleCmd(t *testing.T) {
 		t.Fatalf("ReleaseOnly failed: %v", err)
 	}
 
-	// 2 cmds * 5 platforms = 10 assets
+	// 2 cmds * 5 platforms = 10 archives, each with its SBOM
 	assetCount := 0
 	for _, arg := range fake.lastArgs {
+		if strings.HasSuffix(arg, devflow.SBOMExt) {
+			continue
+		}
 		if strings.Contains(arg, "gopush-") || strings.Contains(arg, "gotest-") {
 			assetCount++
 		}
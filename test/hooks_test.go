@@ -0,0 +1,93 @@
+package devflow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func writeHooks(t *testing.T, dir, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.HooksFile), []byte(content), 0644)
+}
+
+func TestLoadHooks_Validation(t *testing.T) {
+	dir := t.TempDir()
+	if cfg, err := devflow.LoadHooks(dir); err != nil || len(cfg) != 0 {
+		t.Fatalf("no file must mean no hooks, got %v, %v", cfg, err)
+	}
+
+	writeHooks(t, dir, `{"pre-deploy": [{"run": "true"}]}`)
+	if _, err := devflow.LoadHooks(dir); err == nil || !strings.Contains(err.Error(), "pre-deploy") {
+		t.Errorf("expected unknown hook point error, got %v", err)
+	}
+
+	writeHooks(t, dir, `{"pre-tag": [{"run": "true", "go": "./tools/gen"}]}`)
+	if _, err := devflow.LoadHooks(dir); err == nil {
+		t.Error("a hook with both run and go must be rejected")
+	}
+}
+
+func TestPush_RunsHooksAndFoldsOutput(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	writeHooks(t, dir, `{
+		"pre-tag": [{"name": "generate", "run": "echo generated > gen.txt && echo regenerated 2 files"}],
+		"post-push": [{"run": "echo \"$DEVFLOW_MODULE@$DEVFLOW_TAG\" > pushed.txt"}]
+	}`)
+
+	mockGit := &MockGitClient{}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+
+	var seen devflow.HookContext
+	g.AddHook(devflow.HookPostTest, devflow.Hook{Name: "check", Fn: func(ctx devflow.HookContext) (string, error) {
+		seen = ctx
+		return "all good", nil
+	}})
+
+	res, err := g.Push("feat: x", "v0.2.0", true, true, true, true, false, true, "")
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "gen.txt")); err != nil {
+		t.Error("pre-tag hook did not run before the push")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "pushed.txt")); strings.TrimSpace(string(data)) != "github.com/test/lib@v0.2.0" {
+		t.Errorf("post-push hook env: got %q", data)
+	}
+	if seen.Point != devflow.HookPostTest || seen.ModulePath != "github.com/test/lib" {
+		t.Errorf("in-process hook context: %+v", seen)
+	}
+	for _, want := range []string{"🪝 generate: regenerated 2 files", "🪝 check: all good"} {
+		if !strings.Contains(res.Summary, want) {
+			t.Errorf("summary missing %q: %s", want, res.Summary)
+		}
+	}
+}
+
+func TestPush_FailingPreHookAborts(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	writeHooks(t, dir, `{"pre-verify": [{"name": "openapi", "run": "echo spec out of date; exit 3"}]}`)
+
+	mockGit := &MockGitClient{}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	_, err := g.Push("feat: x", "v0.2.0", true, true, true, true, false, true, "")
+	if err == nil || !strings.Contains(err.Error(), "openapi") || !strings.Contains(err.Error(), "spec out of date") {
+		t.Fatalf("expected the pre-verify hook failure, got %v", err)
+	}
+	if mockGit.LastPushTag != "" || mockGit.CommitCalls != 0 {
+		t.Error("nothing must be committed or pushed after a failing pre-* hook")
+	}
+}
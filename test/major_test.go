@@ -0,0 +1,153 @@
+package devflow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func TestMajorPath(t *testing.T) {
+	cases := map[string]string{
+		"github.com/test/lib":     "github.com/test/lib/v2",
+		"github.com/test/lib/v2":  "github.com/test/lib/v3",
+		"github.com/test/v2x/lib": "github.com/test/v2x/lib/v2",
+	}
+	for in, want := range cases {
+		n := 2
+		if strings.HasSuffix(in, "/v2") {
+			n = 3
+		}
+		if got := devflow.MajorPath(in, n); got != want {
+			t.Errorf("MajorPath(%q, %d) = %q, want %q", in, n, got, want)
+		}
+	}
+}
+
+func TestMigrateMajor_RewritesModuleImportsAndSubmodules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module github.com/test/lib\n\ngo 1.20\n",
+		"lib.go": `package lib
+
+import (
+	"fmt" // stdlib untouched
+
+	"github.com/test/lib/util"
+)
+
+// Hello greets.
+func Hello() string { return fmt.Sprint(util.Name) }
+
+var  unformatted=1 // left as written
+`,
+		"util/util.go": "package util\n\nconst Name = \"lib\"\n",
+		"tools/go.mod": "module github.com/test/lib/tools\n\ngo 1.20\n\nrequire github.com/test/lib v1.3.0\n\nreplace github.com/test/lib => ../\n",
+		"tools/main.go": `package main
+
+import (
+	"github.com/test/lib"
+	"github.com/test/lib/tools/internal/x"
+	"github.com/test/lib/util"
+)
+
+func main() { _, _, _ = lib.Hello(), util.Name, x.Y }
+`,
+		"tools/internal/x/x.go": "package x\n\nconst Y = 1\n",
+		// Ignored like the go tool does, even when they do not parse
+		"testdata/bad.go": "package bad\n\nimport \"github.com/test/lib\"\n\nfunc {",
+		"_old/old.go":     "package old\n\nimport _ \"github.com/test/lib/util\"\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v1.3.0"})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	mig, err := g.MigrateMajor()
+	if err != nil {
+		t.Fatalf("MigrateMajor: %v", err)
+	}
+	if mig.NewPath != "github.com/test/lib/v2" || mig.Tag != "v2.0.0" || mig.Files != 2 {
+		t.Errorf("unexpected migration %+v", mig)
+	}
+
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(data)
+	}
+	if !strings.Contains(read("go.mod"), "module github.com/test/lib/v2\n") {
+		t.Errorf("module directive not rewritten:\n%s", read("go.mod"))
+	}
+	if lib := read("lib.go"); lib != strings.Replace(files["lib.go"], `"github.com/test/lib/util"`, `"github.com/test/lib/v2/util"`, 1) {
+		t.Errorf("only the root import must be rewritten:\n%s", lib)
+	}
+	main := read("tools/main.go")
+	for _, want := range []string{`"github.com/test/lib/v2"`, `"github.com/test/lib/v2/util"`, `"github.com/test/lib/tools/internal/x"`} {
+		if !strings.Contains(main, want) {
+			t.Errorf("submodule imports: missing %s in\n%s", want, main)
+		}
+	}
+	toolsMod := read("tools/go.mod")
+	if !strings.Contains(toolsMod, "github.com/test/lib/v2 v2.0.0") || strings.Contains(toolsMod, "github.com/test/lib v1.3.0") {
+		t.Errorf("submodule requirement not moved to v2:\n%s", toolsMod)
+	}
+	for _, name := range []string{"testdata/bad.go", "_old/old.go"} {
+		if read(name) != files[name] {
+			t.Errorf("%s must be left alone, got:\n%s", name, read(name))
+		}
+	}
+}
+
+func TestPush_FailureUndoesMajorMigration(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module github.com/test/lib\n\ngo 1.20\n",
+		"lib.go":       "package lib\n\nimport \"github.com/test/lib/util\"\n\nconst Name = util.Name\n",
+		"util/util.go": "package util\n\nconst Name = \"lib\"\n",
+		"tools/go.mod": "module github.com/test/lib/tools\n\ngo 1.20\n\nrequire github.com/test/lib v1.3.0\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v1.3.0", pushErr: errors.New("remote rejected")})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipAPICheck(true)
+
+	mig, err := g.MigrateMajor()
+	if err != nil {
+		t.Fatalf("MigrateMajor: %v", err)
+	}
+	if _, err := g.Push("feat!: move to v2", mig.Tag, true, true, true, true, false, true, ""); err == nil {
+		t.Fatal("expected the rejected push to fail")
+	}
+	for name, content := range files {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != content {
+			t.Errorf("%s not restored, got:\n%s", name, data)
+		}
+	}
+}
+
+func TestMigrateMajor_RefusesFromV0(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v0.9.0"})
+	g.SetRootDir(dir)
+	if _, err := g.MigrateMajor(); err == nil {
+		t.Fatal("expected v0 → v2 migration to be refused")
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if !strings.Contains(string(data), "module github.com/test/lib\n") {
+		t.Errorf("go.mod must be untouched, got:\n%s", data)
+	}
+}
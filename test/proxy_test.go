@@ -0,0 +1,127 @@
+package devflow_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+// offlineGoEnv isolates the go commands of a test from the network and from
+// the user's module cache.
+func offlineGoEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GOFLAGS", "-modcacherw")
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOWORK", "off")
+}
+
+func TestUploadToProxy_FileProxyServesTheRelease(t *testing.T) {
+	offlineGoEnv(t)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v0.2.0")
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n\n// uncommitted\n"), 0644)
+
+	proxyDir := t.TempDir()
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetRetryConfig(0, 1)
+	g.SetProxy(&devflow.ProxyConfig{Upload: "file://" + proxyDir})
+
+	if err := g.UploadToProxy(dir, "github.com/test/lib", "v0.2.0", "v0.2.0"); err != nil {
+		t.Fatalf("UploadToProxy: %v", err)
+	}
+	for _, name := range []string{"list", "v0.2.0.info", "v0.2.0.mod", "v0.2.0.zip"} {
+		if _, err := os.Stat(filepath.Join(proxyDir, "github.com", "test", "lib", "@v", name)); err != nil {
+			t.Errorf("proxy file %s missing: %v", name, err)
+		}
+	}
+
+	t.Setenv("GOPROXY", "file://"+proxyDir)
+	if err := g.WaitForVersionAvailable("github.com/test/lib", "v0.2.0"); err != nil {
+		t.Errorf("uploaded version must be available: %v", err)
+	}
+	err := g.WaitForVersionAvailable("github.com/test/lib", "v0.3.0")
+	if err == nil || !strings.Contains(err.Error(), proxyDir) {
+		t.Errorf("expected v0.3.0 to be missing on %s, got %v", proxyDir, err)
+	}
+}
+
+func TestPush_UploadsWithProxyEnvironment(t *testing.T) {
+	offlineGoEnv(t)
+	t.Setenv("GOPRIVATE", "") // restored after the test
+	os.Unsetenv("GOPRIVATE")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v0.2.0")
+
+	proxyDir := t.TempDir()
+	leaked := "unset"
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+	g.SetProxy(&devflow.ProxyConfig{GOPRIVATE: "github.com/test/*", Upload: "file://" + proxyDir})
+	g.AddHook(devflow.HookPreTag, devflow.Hook{Run: "go env GOPRIVATE > seen.txt"})
+	g.AddHook(devflow.HookPreTag, devflow.Hook{Fn: func(devflow.HookContext) (string, error) {
+		leaked, _ = os.LookupEnv("GOPRIVATE")
+		return "", nil
+	}})
+
+	res, err := g.Push("feat: x", "v0.2.0", true, true, true, true, false, true, "")
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if seen, _ := os.ReadFile(filepath.Join(dir, "seen.txt")); strings.TrimSpace(string(seen)) != "github.com/test/*" {
+		t.Errorf("GOPRIVATE of the go commands during the push = %q", seen)
+	}
+	if leaked != "" {
+		t.Errorf("the proxy configuration must not reach the process environment, GOPRIVATE = %q", leaked)
+	}
+	if !strings.Contains(res.Summary, "📡 uploaded") {
+		t.Errorf("summary must report the upload, got %q", res.Summary)
+	}
+	if _, err := os.Stat(filepath.Join(proxyDir, "github.com", "test", "lib", "@v", "v0.2.0.zip")); err != nil {
+		t.Errorf("release not uploaded: %v", err)
+	}
+}
+
+func TestUploadToProxy_SendsToken(t *testing.T) {
+	offlineGoEnv(t)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v0.2.0")
+
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetProxy(&devflow.ProxyConfig{Upload: srv.URL + "/upload"})
+	g.SetSecretStore(memStore{devflow.ProxyTokenName: "from-keyring"})
+
+	t.Setenv(devflow.ProxyTokenEnv, "")
+	if err := g.UploadToProxy(dir, "github.com/test/lib", "v0.2.0", "v0.2.0"); err != nil {
+		t.Fatalf("UploadToProxy: %v", err)
+	}
+	t.Setenv(devflow.ProxyTokenEnv, "from-env")
+	if err := g.UploadToProxy(dir, "github.com/test/lib", "v0.2.0", "v0.2.0"); err != nil {
+		t.Fatalf("UploadToProxy: %v", err)
+	}
+	if strings.Join(auth, ",") != strings.Repeat("Bearer from-keyring,", 3)+strings.TrimSuffix(strings.Repeat("Bearer from-env,", 3), ",") {
+		t.Errorf("Authorization headers = %v", auth)
+	}
+}
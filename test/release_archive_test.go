@@ -0,0 +1,118 @@
+package devflow_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func tarGzNames(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPackageArchives(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "LICENSE"), []byte("MIT\n"), 0644)
+	os.WriteFile(filepath.Join(root, "README.md"), []byte("# tool\n"), 0644)
+	bins := t.TempDir()
+	var binaries []string
+	for _, name := range []string{"my-tool-linux-amd64", "my-tool-windows-amd64.exe"} {
+		p := filepath.Join(bins, name)
+		os.WriteFile(p, []byte("binary "+name), 0755)
+		binaries = append(binaries, p)
+	}
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(root)
+
+	out := t.TempDir()
+	archives, err := g.PackageArchives(out, binaries, "v1.2.0", devflow.ArchiveConfig{})
+	if err != nil {
+		t.Fatalf("PackageArchives: %v", err)
+	}
+	want := []string{filepath.Join(out, "my-tool-linux-amd64.tar.gz"), filepath.Join(out, "my-tool-windows-amd64.zip")}
+	if !reflect.DeepEqual(archives, want) {
+		t.Fatalf("archives = %v, want %v", archives, want)
+	}
+	if names := tarGzNames(t, archives[0]); !reflect.DeepEqual(names, []string{"LICENSE", "README.md", "my-tool"}) {
+		t.Errorf("tar.gz entries = %v", names)
+	}
+	zr, err := zip.OpenReader(archives[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zipNames []string
+	for _, f := range zr.File {
+		zipNames = append(zipNames, f.Name)
+	}
+	zr.Close()
+	if !reflect.DeepEqual(zipNames, []string{"my-tool.exe", "LICENSE", "README.md"}) {
+		t.Errorf("zip entries = %v", zipNames)
+	}
+
+	// Reproducible: the same inputs give the same bytes.
+	again, _ := g.PackageArchives(t.TempDir(), binaries, "v1.2.0", devflow.ArchiveConfig{})
+	for i := range archives {
+		a, _ := os.ReadFile(archives[i])
+		b, _ := os.ReadFile(again[i])
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s is not reproducible", filepath.Base(archives[i]))
+		}
+	}
+
+	named, err := g.PackageArchives(t.TempDir(), binaries[:1], "v1.2.0", devflow.ArchiveConfig{Name: "{{.Cmd}}_{{.Version}}_{{.OS}}_{{.Arch}}", Files: []string{"LICENSE"}})
+	if err != nil || filepath.Base(named[0]) != "my-tool_v1.2.0_linux_amd64.tar.gz" {
+		t.Fatalf("templated name: %v, %v", named, err)
+	}
+	if names := tarGzNames(t, named[0]); !reflect.DeepEqual(names, []string{"LICENSE", "my-tool"}) {
+		t.Errorf("configured files: entries = %v", names)
+	}
+}
+
+func TestLoadReleaseConfig_Validation(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := devflow.LoadReleaseConfig(dir); err != nil {
+		t.Fatalf("no file must be the default config: %v", err)
+	}
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	path := filepath.Join(dir, devflow.DevflowConfigDir, devflow.ReleaseFile)
+
+	for _, bad := range []string{`{"archive": {"format": "rar"}}`, `{"archive": {"name": "{{.Cmd"}}`} {
+		os.WriteFile(path, []byte(bad), 0644)
+		if _, err := devflow.LoadReleaseConfig(dir); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
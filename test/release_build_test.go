@@ -0,0 +1,188 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func writeReleaseConfig(t *testing.T, dir, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	if err := os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.ReleaseFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseCrossTarget(t *testing.T) {
+	got, err := devflow.ParseCrossTarget("linux/arm/7")
+	if err != nil || got != (devflow.CrossTarget{GOOS: "linux", GOARCH: "arm", Variant: "7"}) {
+		t.Fatalf("linux/arm/7 = %+v, %v", got, err)
+	}
+	if got.String() != "linux/arm/7" {
+		t.Errorf("String() = %q", got.String())
+	}
+	for _, bad := range []string{"linux", "linux/", "linux/amd64/v3/x", "js/foo/1"} {
+		if _, err := devflow.ParseCrossTarget(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestReleaseConfig_BuildFor(t *testing.T) {
+	dir := t.TempDir()
+	writeReleaseConfig(t, dir, `{
+		"build": {"ldflags": ["-X main.Commit={{.Commit}}"], "env": ["A=1"]},
+		"commands": {"agent": {"targets": ["linux/arm/7"], "cgo": true, "env": ["CC=zig cc"]}}
+	}`)
+	cfg, err := devflow.LoadReleaseConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadReleaseConfig: %v", err)
+	}
+
+	agent := cfg.BuildFor("agent")
+	if len(agent.Targets) != 1 || agent.CGO == nil || !*agent.CGO || len(agent.Ldflags) != 1 {
+		t.Errorf("agent build = %+v", agent)
+	}
+	if strings.Join(agent.Env, " ") != "A=1 CC=zig cc" {
+		t.Errorf("agent env = %v", agent.Env)
+	}
+	if targets, _ := cfg.BuildFor("other").CrossTargets(); len(targets) != len(devflow.DefaultTargets()) {
+		t.Errorf("commands without settings must use DefaultTargets, got %v", targets)
+	}
+
+	for _, bad := range []string{
+		`{"build": {"targets": ["linux"]}}`,
+		`{"commands": {"x": {"ldflags": ["-X main.A={{.Nope}}"]}}}`,
+		`{"build": {"env": ["NOVALUE"]}}`,
+	} {
+		writeReleaseConfig(t, dir, bad)
+		if _, err := devflow.LoadReleaseConfig(dir); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestReleaseOnly_PerCommandTargets(t *testing.T) {
+	dir, cleanup := testCreateCmdDirs(t, "gopush", "agent")
+	defer cleanup()
+	defer testChdir(t, dir)()
+	writeReleaseConfig(t, dir, `{"commands": {"agent": {"targets": ["linux/arm/7", "wasip1/wasm"]}}}`)
+
+	calls := map[string][]devflow.CrossTarget{}
+	goHandler, _ := devflow.NewGo(&MockGitClient{latestTag: "v0.1.0"})
+	goHandler.SetCrossCompileFn(func(tmpDir string, cmds []string, targets []devflow.CrossTarget, _ string) ([]string, error) {
+		calls[strings.Join(cmds, ",")] = targets
+		var assets []string
+		for _, target := range targets {
+			for _, cmd := range cmds {
+				arch := target.GOARCH
+				if target.Variant != "" {
+					arch += "v" + target.Variant
+				}
+				p := filepath.Join(tmpDir, cmd+"-"+target.GOOS+"-"+arch)
+				os.WriteFile(p, []byte("fake binary"), 0644)
+				assets = append(assets, p)
+			}
+		}
+		return assets, nil
+	})
+
+	var created []string
+	fake := &fakeRunner{respond: func(args []string) (string, error) {
+		if len(args) >= 2 && args[0] == "repo" && args[1] == "view" {
+			return `{"owner":{"login":"org"},"name":"repo","visibility":"PUBLIC"}`, nil
+		}
+		if len(args) >= 2 && args[0] == "release" && args[1] == "create" {
+			created = args
+		}
+		return "https://github.com/org/repo/releases/tag/v0.1.0", nil
+	}}
+
+	if err := goHandler.ReleaseOnly("", newTestGitHub(fake)); err != nil {
+		t.Fatalf("ReleaseOnly failed: %v", err)
+	}
+	if len(calls["gopush"]) != 5 {
+		t.Errorf("gopush must build the default targets, got %v", calls["gopush"])
+	}
+	if len(calls["agent"]) != 2 || calls["agent"][0].String() != "linux/arm/7" {
+		t.Errorf("agent targets = %v", calls["agent"])
+	}
+	joined := strings.Join(created, " ")
+	if !strings.Contains(joined, "agent-linux-armv7.tar.gz") || strings.Contains(joined, "agent-darwin") {
+		t.Errorf("release assets = %v", created)
+	}
+}
+
+func TestReleaseOnly_UnknownCommandInConfig(t *testing.T) {
+	dir, cleanup := testCreateCmdDirs(t, "gopush")
+	defer cleanup()
+	defer testChdir(t, dir)()
+	writeReleaseConfig(t, dir, `{"commands": {"gopsuh": {"tags": ["x"]}}}`)
+
+	goHandler, _ := devflow.NewGo(&MockGitClient{latestTag: "v0.1.0"})
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+	fake := &fakeRunner{output: `{"owner":{"login":"org"},"name":"repo","visibility":"PUBLIC"}`}
+
+	err := goHandler.ReleaseOnly("", newTestGitHub(fake))
+	if err == nil || !strings.Contains(err.Error(), "gopsuh") {
+		t.Fatalf("expected the misspelled command to be rejected, got %v", err)
+	}
+}
+
+// TestBuildRelease_FlagsAndTags builds a real command with templated ldflags
+// and a build tag, and runs it.
+func TestBuildRelease_FlagsAndTags(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/tool\n\ngo 1.21\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "cmd", "tool"), 0755)
+	os.WriteFile(filepath.Join(dir, "cmd", "tool", "main.go"), []byte(`package main
+
+import "fmt"
+
+var Version, Commit string
+var edition = "default"
+
+func main() { fmt.Println(Version, Commit, edition) }
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "cmd", "tool", "pro.go"), []byte(`//go:build pro
+
+package main
+
+func init() { edition = "pro" }
+`), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v0.3.0")
+	commit, _ := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+
+	target := runtime.GOOS + "/" + runtime.GOARCH
+	cfg := devflow.ReleaseConfig{Build: devflow.BuildConfig{
+		Targets: []string{target},
+		Ldflags: []string{"-X main.Commit={{.Commit}}"},
+		Tags:    []string{"pro"},
+		Env:     []string{"GOWORK=off", "GOFLAGS=-mod=mod"},
+	}}
+
+	g, _ := devflow.NewGo(&MockGitClient{})
+	g.SetRootDir(dir)
+	assets, err := g.BuildRelease(t.TempDir(), []string{"tool"}, "v0.3.0", cfg)
+	if err != nil {
+		t.Fatalf("BuildRelease: %v", err)
+	}
+	if len(assets) != 1 {
+		t.Fatalf("assets = %v", assets)
+	}
+	out, err := exec.Command(assets[0]).Output()
+	if err != nil {
+		t.Fatalf("run %s: %v", assets[0], err)
+	}
+	want := "v0.3.0 " + strings.TrimSpace(string(commit)) + " pro"
+	if got := strings.TrimSpace(string(out)); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
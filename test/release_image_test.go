@@ -0,0 +1,192 @@
+package devflow_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+// fakeRegistry is an in-memory registry stand-in: monolithic blob uploads,
+// and manifests by tag and digest.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte // "repo@ref"
+}
+
+func newFakeRegistry(t *testing.T) (*fakeRegistry, string) {
+	r := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/blobs/uploads/"):
+		w.Header().Set("Location", req.URL.Path+"1?state=x")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && strings.Contains(path, "/blobs/uploads/"):
+		data, _ := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		if digest != sha256Digest(data) || req.URL.Query().Get("state") != "x" {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest] = data
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		data, ok := r.blobs[digest]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(data)
+	case req.Method == http.MethodPut && strings.Contains(path, "/manifests/"):
+		repo, ref, _ := strings.Cut(path, "/manifests/")
+		data, _ := io.ReadAll(req.Body)
+		r.manifests[repo+"@"+ref] = data
+		r.manifests[repo+"@"+sha256Digest(data)] = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testImageBuild builds the image of a "server" command for linux/amd64 and
+// linux/arm64 with repository as its push target.
+func testImageBuild(t *testing.T, repository string) (*devflow.Go, []devflow.ReleaseImage, devflow.ImageConfig) {
+	t.Helper()
+	tmp := t.TempDir()
+	var bins []string
+	for _, arch := range []string{"amd64", "arm64"} {
+		p := filepath.Join(tmp, "server-linux-"+arch)
+		os.WriteFile(p, []byte("server "+arch), 0755)
+		bins = append(bins, p)
+	}
+	cfg := devflow.ReleaseConfig{
+		Build: devflow.BuildConfig{Targets: []string{"linux/amd64", "linux/arm64", "darwin/arm64"}},
+		Image: &devflow.ImageConfig{Commands: []string{"server"}, Repository: repository, Ports: []string{"8080"}},
+	}
+	g, _ := devflow.NewGo(&MockGitClient{})
+	g.SetRootDir(tmp)
+	g.SetConsoleOutput(func(string) {})
+	images, err := g.BuildImages(filepath.Join(tmp, "oci"), filepath.Join(tmp, "dist"), bins, "v1.0.0", cfg)
+	if err != nil {
+		t.Fatalf("BuildImages: %v", err)
+	}
+	if len(images) != 1 || filepath.Base(images[0].Tarball) != "server"+devflow.ImageExt {
+		t.Fatalf("images = %+v", images)
+	}
+	return g, images, *cfg.Image
+}
+
+func TestPushImages_Registry(t *testing.T) {
+	reg, host := newFakeRegistry(t)
+	g, images, cfg := testImageBuild(t, host+"/acme/{{.Cmd}}")
+
+	if err := g.PushImages(images, "v1.0.0", cfg); err != nil {
+		t.Fatalf("PushImages: %v", err)
+	}
+	index := reg.manifests["acme/server@v1.0.0"]
+	if index == nil || string(reg.manifests["acme/server@latest"]) != string(index) || sha256Digest(index) != images[0].Digest {
+		t.Fatalf("tags v1.0.0 and latest must point at %s", images[0].Digest)
+	}
+
+	var idx struct {
+		Manifests []struct {
+			Digest   string
+			Platform struct{ OS, Architecture string }
+		}
+	}
+	json.Unmarshal(index, &idx)
+	if len(idx.Manifests) != 2 || idx.Manifests[1].Platform.Architecture != "arm64" {
+		t.Fatalf("index = %s", index)
+	}
+	var manifest struct {
+		Config struct{ Digest string }
+		Layers []struct{ Digest string }
+	}
+	json.Unmarshal(reg.manifests["acme/server@"+idx.Manifests[0].Digest], &manifest)
+	if len(manifest.Layers) != 1 || reg.blobs[manifest.Layers[0].Digest] == nil {
+		t.Fatalf("manifest layers not pushed: %+v", manifest)
+	}
+	var config struct {
+		Architecture string
+		Config       struct {
+			Entrypoint   []string
+			ExposedPorts map[string]struct{}
+		}
+	}
+	json.Unmarshal(reg.blobs[manifest.Config.Digest], &config)
+	if config.Architecture != "amd64" || strings.Join(config.Config.Entrypoint, " ") != "/usr/local/bin/server" {
+		t.Errorf("config = %+v", config)
+	}
+	if _, ok := config.Config.ExposedPorts["8080/tcp"]; !ok {
+		t.Errorf("port 8080/tcp not exposed: %+v", config)
+	}
+
+	// A prerelease is not latest.
+	if err := g.PushImages(images, "v1.1.0-rc.1", cfg); err != nil {
+		t.Fatalf("PushImages: %v", err)
+	}
+	if reg.manifests["acme/server@v1.1.0-rc.1"] == nil || len(reg.manifests) != 6 {
+		t.Errorf("manifests = %d", len(reg.manifests))
+	}
+}
+
+func TestPushImages_LayoutDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "images")
+	g, images, cfg := testImageBuild(t, "oci:"+dir+"/{{.Cmd}}")
+	_, again, _ := testImageBuild(t, "")
+	if images[0].Digest != again[0].Digest {
+		t.Errorf("the same binaries must give the same image: %s != %s", images[0].Digest, again[0].Digest)
+	}
+
+	if err := g.PushImages(images, "v1.0.0", cfg); err != nil {
+		t.Fatalf("PushImages: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "server", "index.json"))
+	if err != nil {
+		t.Fatalf("layout not written: %v", err)
+	}
+	var index struct {
+		Manifests []struct {
+			Digest      string
+			Annotations map[string]string
+		}
+	}
+	json.Unmarshal(data, &index)
+	var refs []string
+	for _, m := range index.Manifests {
+		if m.Digest != images[0].Digest {
+			t.Errorf("%s points at %s", m.Annotations["org.opencontainers.image.ref.name"], m.Digest)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "server", "blobs", "sha256", strings.TrimPrefix(m.Digest, "sha256:"))); err != nil {
+			t.Errorf("index blob missing: %v", err)
+		}
+		refs = append(refs, m.Annotations["org.opencontainers.image.ref.name"])
+	}
+	if strings.Join(refs, ",") != "v1.0.0,latest" {
+		t.Errorf("refs = %v", refs)
+	}
+}
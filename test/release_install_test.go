@@ -0,0 +1,91 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+// testLocalRelease writes a local release of a "tool" command for the
+// running platform, published from github.com/acme/tool, and returns its
+// directory. The binary holds the text "tool <tag>".
+func testLocalRelease(t *testing.T, tag string) string {
+	t.Helper()
+	dir, cleanup := testCreateCmdDirs(t, "tool")
+	t.Cleanup(cleanup)
+	testGitInit(t, dir)
+	if out, err := exec.Command("git", "-C", dir, "remote", "add", "origin", "https://github.com/acme/tool.git").CombinedOutput(); err != nil {
+		t.Fatalf("git remote add: %v\n%s", err, out)
+	}
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: tag})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetCrossCompileFn(func(tmpDir string, cmds []string, _ []devflow.CrossTarget, _ string) ([]string, error) {
+		ext := ""
+		if runtime.GOOS == "windows" {
+			ext = ".exe"
+		}
+		p := filepath.Join(tmpDir, "tool-"+runtime.GOOS+"-"+runtime.GOARCH+ext)
+		return []string{p}, os.WriteFile(p, []byte("tool "+tag), 0755)
+	})
+
+	out := filepath.Join(t.TempDir(), "dist")
+	if _, err := g.ReleaseLocal(tag, out); err != nil {
+		t.Fatalf("ReleaseLocal: %v", err)
+	}
+	return out
+}
+
+// TestInstallScript runs install.sh with a curl that serves the local release.
+func TestInstallScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("install.sh needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		if _, err := exec.LookPath("shasum"); err != nil {
+			t.Skip("sha256sum or shasum is required")
+		}
+	}
+	release := testLocalRelease(t, "v0.2.0")
+
+	script, err := os.ReadFile(filepath.Join(release, devflow.InstallScriptFile))
+	if err != nil {
+		t.Fatalf("install.sh missing: %v", err)
+	}
+	if !strings.Contains(string(script), `REPO="acme/tool"`) || !strings.Contains(string(script), "tool-"+runtime.GOOS+"-"+runtime.GOARCH+".tar.gz") {
+		t.Errorf("install.sh = %s", script)
+	}
+
+	fakeBin := t.TempDir()
+	curl := "#!/bin/sh\n# curl -fsSL -o <file> <url>\ncp \"$RELEASE_DIR/$(basename \"$4\")\" \"$3\"\n"
+	os.WriteFile(filepath.Join(fakeBin, "curl"), []byte(curl), 0755)
+
+	binDir := t.TempDir()
+	cmd := exec.Command("sh", filepath.Join(release, devflow.InstallScriptFile))
+	cmd.Env = append(os.Environ(), "PATH="+fakeBin+":"+os.Getenv("PATH"), "RELEASE_DIR="+release, "BIN_DIR="+binDir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("install.sh: %v\n%s", err, out)
+	}
+	if data, err := os.ReadFile(filepath.Join(binDir, "tool")); err != nil || string(data) != "tool v0.2.0" {
+		t.Errorf("installed binary = %q, %v", data, err)
+	}
+
+	// A tampered asset is not installed.
+	archive := filepath.Join(release, "tool-"+runtime.GOOS+"-"+runtime.GOARCH+".tar.gz")
+	os.WriteFile(archive, []byte("tampered"), 0644)
+	os.Remove(filepath.Join(binDir, "tool"))
+	cmd = exec.Command("sh", filepath.Join(release, devflow.InstallScriptFile))
+	cmd.Env = append(os.Environ(), "PATH="+fakeBin+":"+os.Getenv("PATH"), "RELEASE_DIR="+release, "BIN_DIR="+binDir)
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "does not match checksums.txt") {
+		t.Errorf("expected a checksum failure, got %v\n%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(binDir, "tool")); err == nil {
+		t.Error("a tampered binary must not be installed")
+	}
+}
@@ -0,0 +1,88 @@
+package devflow_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func TestReleaseLocal(t *testing.T) {
+	dir, cleanup := testCreateCmdDirs(t, "tool")
+	defer cleanup()
+
+	goHandler := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v0.2.0"})
+	goHandler.SetRootDir(dir)
+	goHandler.SetConsoleOutput(func(string) {})
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+
+	out := filepath.Join(t.TempDir(), "dist")
+	m, err := goHandler.ReleaseLocal("", out)
+	if err != nil {
+		t.Fatalf("ReleaseLocal: %v", err)
+	}
+	if m.Tag != "v0.2.0" {
+		t.Errorf("tag = %q", m.Tag)
+	}
+
+	names := map[string]bool{}
+	for _, a := range m.Assets {
+		names[a.Name] = true
+		if _, err := os.Stat(filepath.Join(out, a.Name)); err != nil {
+			t.Errorf("asset %s: %v", a.Name, err)
+		}
+	}
+	for _, want := range []string{"tool-linux-amd64.tar.gz", "tool-linux-amd64.tar.gz.spdx.json", "checksums.txt", devflow.ProvenanceFile} {
+		if !names[want] {
+			t.Errorf("asset %s missing from the manifest: %+v", want, m.Assets)
+		}
+	}
+	if len(m.Binaries) != 1 || m.Binaries[0].Name != "bin/tool-linux-amd64" || m.Binaries[0].OS != "linux" || m.Binaries[0].Cmd != "tool" {
+		t.Errorf("binaries = %+v", m.Binaries)
+	}
+
+	var written devflow.ReleaseManifest
+	data, err := os.ReadFile(filepath.Join(out, devflow.ManifestFile))
+	if err != nil || json.Unmarshal(data, &written) != nil || len(written.Assets) != len(m.Assets) {
+		t.Errorf("manifest.json = %s, %v", data, err)
+	}
+
+	if _, err := goHandler.ReleaseLocal("v0.2.1", out); err == nil {
+		t.Error("a non-empty directory must be refused")
+	}
+}
+
+func TestReleaseLocal_WasmOnly(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "web", "client"), 0755)
+	os.WriteFile(filepath.Join(dir, "web", "client", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	config := `{"build": {"env": ["GOWORK=off", "GOFLAGS=-mod=mod"]}, "wasm": {"packages": ["web/client"], "compress": []}}`
+	os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.ReleaseFile), []byte(config), 0644)
+
+	goHandler := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v0.2.0"})
+	goHandler.SetRootDir(dir)
+	goHandler.SetConsoleOutput(func(string) {})
+
+	out := filepath.Join(t.TempDir(), "dist")
+	m, err := goHandler.ReleaseLocal("", out)
+	if err != nil {
+		t.Fatalf("ReleaseLocal of a repository without cmd/: %v", err)
+	}
+	var names []string
+	for _, a := range m.Assets {
+		names = append(names, a.Name)
+	}
+	if strings.Join(names, " ") != "client.wasm "+devflow.WasmExecFile+" checksums.txt "+devflow.ProvenanceFile || len(m.Binaries) != 0 {
+		t.Errorf("assets = %v, binaries = %+v", names, m.Binaries)
+	}
+
+	os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.ReleaseFile), []byte(`{"homebrew": {"repo": "acme/homebrew-tap"}}`), 0644)
+	if _, err := goHandler.ReleaseLocal("", t.TempDir()); err == nil || !strings.Contains(err.Error(), "no cmd/ found") {
+		t.Errorf("expected no cmd/ found without wasm packages, got %v", err)
+	}
+}
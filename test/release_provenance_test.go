@@ -0,0 +1,173 @@
+package devflow_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/devflow"
+	"github.com/tinywasm/devflow/selfupdate"
+)
+
+func TestReleaseOnly_UploadsProvenance(t *testing.T) {
+	cleanup := createAppDir(t, "mytool", "mytool")
+	defer cleanup()
+
+	var checksums string
+	var provenance devflow.Provenance
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		if isRepoView(args) {
+			return `{"owner":{"login":"acme"},"name":"mytool","visibility":"PUBLIC"}`, nil
+		}
+		if len(args) >= 2 && args[0] == "release" && args[1] == "create" {
+			// The assets are removed once ReleaseOnly returns.
+			for _, a := range args {
+				switch filepath.Base(a) {
+				case "checksums.txt":
+					data, _ := os.ReadFile(a)
+					checksums = string(data)
+				case devflow.ProvenanceFile:
+					data, _ := os.ReadFile(a)
+					if err := json.Unmarshal(data, &provenance); err != nil {
+						t.Errorf("invalid provenance: %v", err)
+					}
+				}
+			}
+		}
+		return "https://github.com/acme/mytool/releases/tag/v1.0.0", nil
+	}}
+
+	goHandler, _ := devflow.NewGo(&MockGitClient{latestTag: "v1.0.0"})
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+
+	if err := goHandler.ReleaseOnly("", newGitHubWithRunner(runner)); err != nil {
+		t.Fatalf("ReleaseOnly failed: %v", err)
+	}
+
+	if provenance.PredicateType != "https://slsa.dev/provenance/v1" || len(provenance.Subject) == 0 {
+		t.Fatalf("provenance = %+v", provenance)
+	}
+	var want strings.Builder
+	for _, subject := range provenance.Subject {
+		want.WriteString(subject.Digest["sha256"] + "  " + subject.Name + "\n")
+	}
+	if checksums != want.String() {
+		t.Errorf("subjects must match checksums.txt: %q, want %q", checksums, want.String())
+	}
+	params := provenance.Predicate.BuildDefinition.ExternalParameters
+	if params.Ref != "refs/tags/v1.0.0" || len(params.Builds) != len(devflow.DefaultTargets()) {
+		t.Errorf("external parameters = %+v", params)
+	}
+	args := strings.Join(params.Builds[0].Args, " ")
+	if !strings.Contains(args, "-trimpath") || !strings.Contains(args, "-buildvcs=") || !strings.Contains(args, "main.Version=v1.0.0") ||
+		!strings.Contains(args, selfupdate.TargetSymbol+"="+devflow.DefaultTargets()[0].String()) {
+		t.Errorf("build args = %v", args)
+	}
+	if provenance.Predicate.RunDetails.Builder.ID != devflow.ProvenanceBuilderID {
+		t.Errorf("builder = %+v", provenance.Predicate.RunDetails.Builder)
+	}
+}
+
+func TestVerifyReproducible(t *testing.T) {
+	dir, cleanup := testCreateCmdDirs(t, "tool")
+	defer cleanup()
+
+	goHandler := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v0.1.0"})
+	goHandler.SetRootDir(dir)
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+
+	report, err := goHandler.VerifyReproducible("")
+	if err != nil {
+		t.Fatalf("VerifyReproducible: %v\n%s", err, report)
+	}
+	if !strings.Contains(report, "✓ tool-linux-amd64.tar.gz") {
+		t.Errorf("report = %q", report)
+	}
+
+	// A binary embedding its build directory differs between builds.
+	goHandler.SetCrossCompileFn(func(tmpDir string, cmds []string, _ []devflow.CrossTarget, _ string) ([]string, error) {
+		p := filepath.Join(tmpDir, cmds[0]+"-linux-amd64")
+		return []string{p}, os.WriteFile(p, []byte(tmpDir), 0755)
+	})
+	report, err = goHandler.VerifyReproducible("v0.1.0")
+	if err == nil || !strings.Contains(err.Error(), "tool-linux-amd64.tar.gz") {
+		t.Fatalf("expected the asset to be reported, got %v", err)
+	}
+	if !strings.Contains(report, "✗ tool-linux-amd64.tar.gz") {
+		t.Errorf("report = %q", report)
+	}
+}
+
+func TestVerifyReproducible_RefusesAnotherSource(t *testing.T) {
+	dir, cleanup := testCreateCmdDirs(t, "tool")
+	defer cleanup()
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "v0.1.0")
+	os.WriteFile(filepath.Join(dir, "cmd", "tool", "extra.go"), []byte("package main\n"), 0644)
+
+	goHandler := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v0.1.0"})
+	goHandler.SetRootDir(dir)
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+
+	if _, err := goHandler.VerifyReproducible("v0.1.0"); err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Fatalf("expected a dirty tree to be refused, got %v", err)
+	}
+
+	testGitCommitAll(t, dir, "")
+	if _, err := goHandler.VerifyReproducible("v0.1.0"); err == nil || !strings.Contains(err.Error(), "HEAD is not at v0.1.0") {
+		t.Fatalf("expected an older tag to be refused, got %v", err)
+	}
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		if isRepoView(args) {
+			return `{"owner":{"login":"acme"},"name":"tool","visibility":"PUBLIC"}`, nil
+		}
+		return "", nil
+	}}
+	if err := goHandler.ReleaseOnly("v0.1.0", newGitHubWithRunner(runner)); err == nil || !strings.Contains(err.Error(), "refusing to build v0.1.0") {
+		t.Fatalf("expected ReleaseOnly to refuse, got %v", err)
+	}
+	if _, err := goHandler.VerifyReproducible("v0.2.0"); err != nil {
+		t.Errorf("a tag not created yet builds HEAD: %v", err)
+	}
+}
+
+// TestBuildRelease_SourceDateEpoch builds a real command twice and checks the
+// binaries are identical and timestamped with SOURCE_DATE_EPOCH.
+func TestBuildRelease_SourceDateEpoch(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/tool\n\ngo 1.21\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "cmd", "tool"), 0755)
+	os.WriteFile(filepath.Join(dir, "cmd", "tool", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	cfg := devflow.ReleaseConfig{Build: devflow.BuildConfig{
+		Targets: []string{runtime.GOOS + "/" + runtime.GOARCH},
+		Env:     []string{"GOWORK=off", "GOFLAGS=-mod=mod"},
+	}}
+	g, _ := devflow.NewGo(&MockGitClient{})
+	g.SetRootDir(dir)
+
+	var sums []string
+	for range 2 {
+		assets, err := g.BuildRelease(t.TempDir(), []string{"tool"}, "v0.1.0", cfg)
+		if err != nil {
+			t.Fatalf("BuildRelease: %v", err)
+		}
+		info, err := os.Stat(assets[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("mtime = %v, want SOURCE_DATE_EPOCH", info.ModTime())
+		}
+		data, _ := os.ReadFile(assets[0])
+		sums = append(sums, string(data))
+	}
+	if sums[0] != sums[1] {
+		t.Error("two builds of the same source must be identical")
+	}
+}
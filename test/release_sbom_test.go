@@ -0,0 +1,140 @@
+package devflow_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+type testSPDX struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name        string `json:"name"`
+		SPDXID      string `json:"SPDXID"`
+		VersionInfo string `json:"versionInfo"`
+	} `json:"packages"`
+	Relationships []struct {
+		From string `json:"spdxElementId"`
+		Type string `json:"relationshipType"`
+		To   string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+// TestWriteSBOMs builds a real command depending on a local module and reads
+// its modules back from the SBOM.
+func TestWriteSBOMs(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "lib")
+	os.MkdirAll(lib, 0755)
+	os.WriteFile(filepath.Join(lib, "go.mod"), []byte("module example.com/lib\n\ngo 1.21\n"), 0644)
+	os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib\n\nfunc Name() string { return \"lib\" }\n"), 0644)
+
+	dir := filepath.Join(root, "tool")
+	os.MkdirAll(filepath.Join(dir, "cmd", "tool"), 0755)
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/tool\n\ngo 1.21\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../lib\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "cmd", "tool", "main.go"), []byte(`package main
+
+import "example.com/lib"
+
+func main() { println(lib.Name()) }
+`), 0644)
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	g, _ := devflow.NewGo(&MockGitClient{})
+	g.SetRootDir(dir)
+	cfg := devflow.ReleaseConfig{Build: devflow.BuildConfig{Targets: []string{runtime.GOOS + "/" + runtime.GOARCH}}}
+	binaries, err := g.BuildRelease(t.TempDir(), []string{"tool"}, "v1.2.0", cfg)
+	if err != nil {
+		t.Fatalf("BuildRelease: %v", err)
+	}
+
+	out := t.TempDir()
+	sboms, err := g.WriteSBOMs(out, binaries, []string{"tool.tar.gz"}, "v1.2.0")
+	if err != nil {
+		t.Fatalf("WriteSBOMs: %v", err)
+	}
+	if len(sboms) != 1 || filepath.Base(sboms[0]) != "tool.tar.gz"+devflow.SBOMExt {
+		t.Fatalf("sboms = %v", sboms)
+	}
+	data, _ := os.ReadFile(sboms[0])
+	var doc testSPDX
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid SBOM: %v", err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" {
+		t.Errorf("spdxVersion = %q", doc.SPDXVersion)
+	}
+
+	ids := map[string]string{}
+	for _, p := range doc.Packages {
+		ids[p.Name] = p.SPDXID
+		if p.Name == "example.com/tool" && p.VersionInfo != "v1.2.0" {
+			t.Errorf("main module version = %q, want the tag", p.VersionInfo)
+		}
+	}
+	for _, name := range []string{"example.com/tool", "example.com/lib", "stdlib"} {
+		if ids[name] == "" {
+			t.Errorf("package %s missing from %s", name, data)
+		}
+	}
+	dependsOn := false
+	for _, r := range doc.Relationships {
+		if r.From == ids["example.com/tool"] && r.Type == "DEPENDS_ON" && r.To == ids["example.com/lib"] {
+			dependsOn = true
+		}
+	}
+	if !dependsOn {
+		t.Errorf("expected example.com/tool DEPENDS_ON example.com/lib, got %+v", doc.Relationships)
+	}
+
+	// The SBOM is reproducible.
+	again, err := g.WriteSBOMs(t.TempDir(), binaries, []string{"tool.tar.gz"}, "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data2, _ := os.ReadFile(again[0]); string(data2) != string(data) {
+		t.Error("two SBOMs of the same binary must be identical")
+	}
+}
+
+func TestReleaseOnly_ChecksumsCoverSBOMs(t *testing.T) {
+	cleanup := createAppDir(t, "mytool", "mytool")
+	defer cleanup()
+
+	var checksums string
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		if isRepoView(args) {
+			return `{"owner":{"login":"acme"},"name":"mytool","visibility":"PUBLIC"}`, nil
+		}
+		for _, a := range args {
+			if filepath.Base(a) == "checksums.txt" {
+				data, _ := os.ReadFile(a)
+				checksums = string(data)
+			}
+		}
+		return "https://github.com/acme/mytool/releases/tag/v1.0.0", nil
+	}}
+
+	goHandler, _ := devflow.NewGo(&MockGitClient{latestTag: "v1.0.0"})
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+	if err := goHandler.ReleaseOnly("", newGitHubWithRunner(runner)); err != nil {
+		t.Fatalf("ReleaseOnly failed: %v", err)
+	}
+	if !strings.Contains(checksums, "  mytool-linux-amd64.tar.gz.spdx.json\n") {
+		t.Errorf("checksums.txt must list the SBOM, got %q", checksums)
+	}
+	if !strings.Contains(strings.Join(runner.lastReleaseCreateArgs(), " "), "mytool-linux-amd64.tar.gz.spdx.json") {
+		t.Errorf("the SBOM must be a release asset, got %v", runner.lastReleaseCreateArgs())
+	}
+
+	writeReleaseConfig(t, ".", `{"sbom": "cyclonedx"}`)
+	if _, err := devflow.LoadReleaseConfig("."); err == nil {
+		t.Error("expected an unknown sbom format to be rejected")
+	}
+}
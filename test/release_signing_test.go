@@ -0,0 +1,110 @@
+package devflow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func TestReleaseKey_SignVerify(t *testing.T) {
+	store := memStore{}
+	root := t.TempDir()
+	key, err := devflow.GenerateReleaseKeyFor(root, store)
+	if err != nil {
+		t.Fatalf("GenerateReleaseKeyFor: %v", err)
+	}
+	if _, err := devflow.GenerateReleaseKeyFor(root, store); err == nil {
+		t.Error("an existing key must not be replaced")
+	}
+	loaded, err := devflow.LoadReleaseKey(store)
+	if err != nil || loaded.KeyID() != key.KeyID() {
+		t.Fatalf("LoadReleaseKey = %v, %v", loaded.KeyID(), err)
+	}
+
+	pub := devflow.ReleasePublicKey(root)
+	data := []byte("abc  tool-linux-amd64.tar.gz\n")
+	sig := key.Sign(data, "timestamp:0\tfile:checksums.txt")
+	if string(sig) != string(loaded.Sign(data, "timestamp:0\tfile:checksums.txt")) {
+		t.Error("signatures must be deterministic")
+	}
+	comment, err := devflow.VerifySignature(pub, data, sig)
+	if err != nil || comment != "timestamp:0\tfile:checksums.txt" {
+		t.Fatalf("VerifySignature = %q, %v", comment, err)
+	}
+	if _, err := devflow.VerifySignature(pub, []byte("tampered"), sig); err == nil {
+		t.Error("a tampered file must fail verification")
+	}
+	forged := strings.Replace(string(sig), "file:checksums.txt", "file:other.txt", 1)
+	if _, err := devflow.VerifySignature(pub, data, []byte(forged)); err == nil {
+		t.Error("a tampered trusted comment must fail verification")
+	}
+	other, _ := devflow.GenerateReleaseKey()
+	if _, err := devflow.VerifySignature(other.PublicKey(), data, sig); err == nil {
+		t.Error("another key must fail verification")
+	}
+}
+
+func TestReleaseOnly_SignsChecksums(t *testing.T) {
+	cleanup := createAppDir(t, "mytool", "mytool")
+	defer cleanup()
+	writeReleaseConfig(t, ".", `{"sign": true}`)
+
+	goHandler, _ := devflow.NewGo(&MockGitClient{latestTag: "v1.0.0"})
+	goHandler.SetCrossCompileFn(fakeCrossCompile)
+
+	// Without a key nothing is built.
+	t.Setenv(devflow.ReleaseKeyEnv, "")
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		return `{"owner":{"login":"acme"},"name":"mytool","visibility":"PUBLIC"}`, nil
+	}}
+	if err := goHandler.ReleaseOnly("", newGitHubWithRunner(runner)); err == nil || !strings.Contains(err.Error(), "keygen") {
+		t.Fatalf("expected a missing key error, got %v", err)
+	}
+
+	store := memStore{}
+	key, _ := devflow.GenerateReleaseKeyFor(".", store)
+	goHandler.SetSecretStore(store)
+
+	download := t.TempDir()
+	runner = &scriptedRunner{respond: func(args []string) (string, error) {
+		if isRepoView(args) {
+			return `{"owner":{"login":"acme"},"name":"mytool","visibility":"PUBLIC"}`, nil
+		}
+		if len(args) >= 2 && args[0] == "release" && args[1] == "create" {
+			for _, a := range args {
+				if data, err := os.ReadFile(a); err == nil {
+					os.WriteFile(filepath.Join(download, filepath.Base(a)), data, 0644)
+				}
+			}
+		}
+		return "https://github.com/acme/mytool/releases/tag/v1.0.0", nil
+	}}
+	if err := goHandler.ReleaseOnly("", newGitHubWithRunner(runner)); err != nil {
+		t.Fatalf("ReleaseOnly failed: %v", err)
+	}
+
+	report, err := devflow.VerifyRelease(download, key.PublicKey())
+	if err != nil {
+		t.Fatalf("VerifyRelease: %v\n%s", err, report)
+	}
+	if !strings.Contains(report, "good signature") || !strings.Contains(report, "✓ mytool-linux-amd64.tar.gz") {
+		t.Errorf("report = %q", report)
+	}
+
+	// Only the asset of one platform downloaded: the others are skipped.
+	os.Remove(filepath.Join(download, "mytool-linux-amd64.tar.gz.spdx.json"))
+	if _, err := devflow.VerifyRelease(download, key.PublicKey()); err != nil {
+		t.Errorf("missing assets must be skipped: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(download, "mytool-linux-amd64.tar.gz"), []byte("tampered"), 0644)
+	if _, err := devflow.VerifyRelease(download, key.PublicKey()); err == nil || !strings.Contains(err.Error(), "mytool-linux-amd64.tar.gz") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := devflow.VerifyRelease(download, ""); err == nil {
+		t.Error("a signed checksums.txt needs a key to be verified")
+	}
+}
@@ -0,0 +1,153 @@
+package devflow_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+// testBareRepo creates a bare repository with an initial commit on main and
+// returns its path.
+func testBareRepo(t *testing.T) string {
+	t.Helper()
+	tmp := t.TempDir()
+	seed := filepath.Join(tmp, "seed")
+	os.MkdirAll(seed, 0755)
+	testGitInit(t, seed)
+	os.WriteFile(filepath.Join(seed, "README.md"), []byte("# tap\n"), 0644)
+	bare := filepath.Join(tmp, "tap.git")
+	for _, args := range [][]string{
+		{"-C", seed, "checkout", "-q", "-b", "main"},
+		{"-C", seed, "add", "."},
+		{"-C", seed, "commit", "-q", "-m", "init"},
+		{"clone", "-q", "--bare", seed, bare},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return bare
+}
+
+func TestReleaseOnly_UpdatesTaps(t *testing.T) {
+	cleanup := createAppDir(t, "tool", "tool")
+	defer cleanup()
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(v, "Test")
+	}
+	for _, v := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "test@test.com")
+	}
+
+	tap := testBareRepo(t)
+	os.MkdirAll(devflow.DevflowConfigDir, 0755)
+	os.WriteFile(filepath.Join(devflow.DevflowConfigDir, devflow.ReleaseFile), []byte(`{
+		"homebrew": {"repo": "`+tap+`", "description": "Does \"tool\" things"},
+		"scoop": {"repo": "`+tap+`", "dir": "scoop", "license": "MIT"}
+	}`), 0644)
+
+	sums := map[string]string{}
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		if isRepoView(args) {
+			return `{"owner":{"login":"acme"},"name":"tool","visibility":"PUBLIC"}`, nil
+		}
+		if len(args) >= 2 && args[0] == "release" && args[1] == "create" {
+			for _, a := range args {
+				if filepath.Base(a) == "checksums.txt" {
+					data, _ := os.ReadFile(a)
+					for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+						sum, name, _ := strings.Cut(line, "  ")
+						sums[name] = sum
+					}
+				}
+			}
+		}
+		return "https://github.com/acme/tool/releases/tag/v1.0.0", nil
+	}}
+
+	goHandler, _ := devflow.NewGo(&MockGitClient{latestTag: "v1.0.0"})
+	goHandler.SetConsoleOutput(func(string) {})
+	goHandler.SetCrossCompileFn(func(tmpDir string, cmds []string, targets []devflow.CrossTarget, _ string) ([]string, error) {
+		var bins []string
+		for _, target := range targets {
+			for _, cmd := range cmds {
+				name := cmd + "-" + target.GOOS + "-" + target.GOARCH
+				if target.GOOS == "windows" {
+					name += ".exe"
+				}
+				p := filepath.Join(tmpDir, name)
+				os.WriteFile(p, []byte(name), 0755)
+				bins = append(bins, p)
+			}
+		}
+		return bins, nil
+	})
+
+	if err := goHandler.ReleaseOnly("", newGitHubWithRunner(runner)); err != nil {
+		t.Fatalf("ReleaseOnly failed: %v", err)
+	}
+
+	show := func(file string) string {
+		out, err := exec.Command("git", "-C", tap, "show", "main:"+file).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s not committed to the tap: %v\n%s", file, err, out)
+		}
+		return string(out)
+	}
+	download := "https://github.com/acme/tool/releases/download/v1.0.0/"
+
+	formula := show("Formula/tool.rb")
+	for _, want := range []string{
+		"class Tool < Formula",
+		`desc "Does \"tool\" things"`,
+		`version "1.0.0"`,
+		`url "` + download + `tool-darwin-arm64.tar.gz"`,
+		`sha256 "` + sums["tool-darwin-arm64.tar.gz"] + `"`,
+		`url "` + download + `tool-linux-amd64.tar.gz"`,
+		`sha256 "` + sums["tool-linux-amd64.tar.gz"] + `"`,
+		`bin.install "tool"`,
+	} {
+		if !strings.Contains(formula, want) {
+			t.Errorf("formula lacks %s:\n%s", want, formula)
+		}
+	}
+	if strings.Contains(formula, "windows") {
+		t.Errorf("formula must not reference windows assets:\n%s", formula)
+	}
+
+	var manifest struct {
+		Version      string
+		License      string
+		Homepage     string
+		Bin          string
+		Architecture map[string]struct{ URL, Hash string }
+	}
+	if err := json.Unmarshal([]byte(show("scoop/tool.json")), &manifest); err != nil {
+		t.Fatalf("invalid scoop manifest: %v", err)
+	}
+	win := manifest.Architecture["64bit"]
+	if manifest.Version != "1.0.0" || manifest.License != "MIT" || manifest.Bin != "tool.exe" ||
+		manifest.Homepage != "https://github.com/acme/tool" ||
+		win.URL != download+"tool-windows-amd64.zip" || win.Hash != sums["tool-windows-amd64.zip"] || win.Hash == "" {
+		t.Errorf("scoop manifest = %+v", manifest)
+	}
+
+	out, _ := exec.Command("git", "-C", tap, "log", "--format=%s", "main").CombinedOutput()
+	if log := strings.Fields(string(out)); strings.Count(string(out), "Update tool to v1.0.0") != 2 || log[len(log)-1] != "init" {
+		t.Errorf("tap log = %q", out)
+	}
+}
+
+func TestLoadReleaseConfig_TapRepoRequired(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.ReleaseFile), []byte(`{"scoop": {"dir": "bucket"}}`), 0644)
+	if _, err := devflow.LoadReleaseConfig(dir); err == nil || !strings.Contains(err.Error(), "scoop: repo is required") {
+		t.Errorf("expected a missing repo error, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package devflow_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/devflow"
+)
+
+func TestBuildWasm(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "web", "client"), 0755)
+	os.WriteFile(filepath.Join(dir, "web", "client", "main.go"), []byte("package main\n\nvar Version string\n\nfunc main() { println(Version) }\n"), 0644)
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	cfg := devflow.ReleaseConfig{
+		Build: devflow.BuildConfig{Env: []string{"GOWORK=off", "GOFLAGS=-mod=mod"}},
+		Wasm:  &devflow.WasmConfig{Packages: []string{"web/client"}},
+	}
+	g, _ := devflow.NewGo(&MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	var builds [][]byte
+	for range 2 {
+		dist := t.TempDir()
+		wasm, assets, err := g.BuildWasm(dist, "v0.1.0", cfg)
+		if err != nil {
+			t.Fatalf("BuildWasm: %v", err)
+		}
+		var names []string
+		for _, a := range assets {
+			names = append(names, filepath.Base(a))
+		}
+		if strings.Join(names, " ") != "client.wasm client.wasm.gz client.wasm.br "+devflow.WasmExecFile {
+			t.Fatalf("assets = %v", names)
+		}
+		if len(wasm) != 1 || wasm[0].Compiler != "go" || wasm[0].Size == 0 || wasm[0].Gzip == 0 || wasm[0].Brotli == 0 || wasm[0].Built != 0 {
+			t.Fatalf("wasm = %+v", wasm)
+		}
+		if wasm[0].Gzip >= wasm[0].Size || wasm[0].Brotli >= wasm[0].Gzip {
+			t.Errorf("sizes = %+v, expected br < gzip < wasm", wasm[0])
+		}
+
+		data, _ := os.ReadFile(assets[0])
+		if !bytes.HasPrefix(data, []byte("\x00asm")) {
+			t.Fatal("client.wasm is not a wasm module")
+		}
+		gz, _ := os.Open(assets[1])
+		r, err := gzip.NewReader(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unzipped, _ := io.ReadAll(r)
+		gz.Close()
+		if !bytes.Equal(unzipped, data) {
+			t.Error("client.wasm.gz does not hold client.wasm")
+		}
+		for _, a := range assets {
+			if info, _ := os.Stat(a); !info.ModTime().Equal(time.Unix(1700000000, 0)) {
+				t.Errorf("%s mtime = %v, want SOURCE_DATE_EPOCH", filepath.Base(a), info.ModTime())
+			}
+		}
+		compressed, _ := os.ReadFile(assets[2])
+		builds = append(builds, append(unzipped, compressed...))
+	}
+	if !bytes.Equal(builds[0], builds[1]) {
+		t.Error("two builds of the same source must be identical")
+	}
+
+	cfg.Wasm.Compress = []string{}
+	if _, assets, err := g.BuildWasm(t.TempDir(), "v0.1.0", cfg); err != nil || len(assets) != 2 {
+		t.Errorf("no compression: assets = %v, err = %v", assets, err)
+	}
+}
+
+func TestBuildWasm_TinygoLdflags(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "web", "client"), 0755)
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "targets"), 0755)
+	os.WriteFile(filepath.Join(root, "targets", devflow.WasmExecFile), []byte("// tinygo"), 0644)
+
+	// tinygo env TINYGOROOT, then tinygo build -o <out> ...: the args are kept
+	argsFile := filepath.Join(t.TempDir(), "args")
+	fakeBin := t.TempDir()
+	tinygo := "#!/bin/sh\nif [ \"$1\" = env ]; then echo " + root + "; exit 0; fi\necho \"$@\" > " + argsFile + "\nprintf '\\000asm' > \"$3\"\n"
+	os.WriteFile(filepath.Join(fakeBin, "tinygo"), []byte(tinygo), 0755)
+	t.Setenv("PATH", fakeBin+":"+os.Getenv("PATH"))
+
+	cfg := devflow.ReleaseConfig{
+		Build: devflow.BuildConfig{Ldflags: []string{"-X main.Target={{.OS}}/{{.Arch}}"}},
+		Wasm:  &devflow.WasmConfig{Packages: []string{"web/client"}, Compress: []string{}},
+	}
+	g, _ := devflow.NewGo(&MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.UseTinygo(true)
+
+	wasm, _, err := g.BuildWasm(t.TempDir(), "v0.1.0", cfg)
+	if err != nil {
+		t.Fatalf("BuildWasm: %v", err)
+	}
+	if len(wasm) != 1 || wasm[0].Compiler != "tinygo" {
+		t.Fatalf("wasm = %+v", wasm)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "-ldflags=-X main.Version=v0.1.0 -X main.Target=js/wasm ./web/client") {
+		t.Errorf("tinygo args = %s, expected the ldflags without -s -w", args)
+	}
+}
+
+func TestLoadReleaseConfig_Wasm(t *testing.T) {
+	for config, want := range map[string]string{
+		`{"wasm": {}}`: "wasm: packages is required",
+		`{"wasm": {"packages": ["web/client", "app/client"]}}`:     "would both give client.wasm",
+		`{"wasm": {"packages": ["../client"]}}`:                    "must be a directory of the repository",
+		`{"wasm": {"packages": ["client"], "compress": ["zstd"]}}`: `unknown wasm compression "zstd"`,
+		`{"wasm": {"packages": ["client"], "wasm_opt": ["-Oz"]}}`:  "",
+	} {
+		dir := t.TempDir()
+		os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+		os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.ReleaseFile), []byte(config), 0644)
+		cfg, err := devflow.LoadReleaseConfig(dir)
+		if want == "" {
+			if err != nil || len(cfg.Wasm.WasmOpt) != 1 {
+				t.Errorf("%s: %+v, %v", config, cfg.Wasm, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", config, want, err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/command"
+	"github.com/tinywasm/devflow"
+	gitmod "github.com/tinywasm/git"
+)
+
+// fakeProxyHasVersion makes `go list -m mod@version` succeed so
+// WaitForVersionAvailable returns immediately.
+func fakeProxyHasVersion(t *testing.T) {
+	t.Helper()
+	originalExec := command.Exec
+	t.Cleanup(func() { command.Exec = originalExec })
+	command.Exec = func(name string, args ...string) *exec.Cmd {
+		if name == "go" && len(args) > 1 && args[0] == "list" {
+			return exec.Command("true")
+		}
+		return originalExec(name, args...)
+	}
+}
+
+func findCall(calls [][]string, prefix ...string) []string {
+	for _, c := range calls {
+		if len(c) >= len(prefix) && strings.Join(c[:len(prefix)], " ") == strings.Join(prefix, " ") {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestBumpRemoteDependents_OpensPRFromFileIndex(t *testing.T) {
+	fakeProxyHasVersion(t)
+	tmp := t.TempDir()
+	index := filepath.Join(tmp, "importers.txt")
+	os.WriteFile(index, []byte(`# module repo [dir]
+github.com/test/mylib  acme/webapp
+github.com/test/mylib  acme/tools   cmd/tool
+github.com/test/other  acme/unrelated
+`), 0644)
+
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		joined := strings.Join(args, " ")
+		switch {
+		case strings.HasSuffix(joined, "status --porcelain"):
+			return " M go.mod", nil
+		case strings.HasPrefix(joined, "pr create"):
+			return "https://github.com/" + args[3] + "/pull/7\n", nil
+		}
+		return "", nil
+	}}
+	gh := &gitmod.GitHub{SecretRunner: runner}
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetConsoleOutput(func(string) {})
+	g.SetRetryConfig(time.Millisecond, 1)
+	g.SetRemoteDependents(devflow.NewFileIndex(index), gh)
+
+	entries, err := g.BumpRemoteDependents("github.com/test/mylib", "v0.2.0", "")
+	if err != nil {
+		t.Fatalf("BumpRemoteDependents: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 remote dependents, got %+v", entries)
+	}
+	for _, e := range entries {
+		if e.Status != devflow.CascadeStatusPROpened || !strings.HasSuffix(e.Detail, "/pull/7") {
+			t.Errorf("expected PR opened for %s, got %+v", e.ModulePath, e)
+		}
+	}
+	if entries[1].ModulePath != "acme/tools/cmd/tool" {
+		t.Errorf("expected subdirectory dependent, got %s", entries[1].ModulePath)
+	}
+
+	if findCall(runner.calls, "repo", "clone", "acme/webapp") == nil {
+		t.Errorf("expected gh repo clone of acme/webapp, calls: %v", runner.calls)
+	}
+	pr := findCall(runner.calls, "pr", "create", "--repo", "acme/webapp")
+	if pr == nil {
+		t.Fatalf("expected gh pr create on acme/webapp, calls: %v", runner.calls)
+	}
+	if !strings.Contains(strings.Join(pr, " "), "--head deps/mylib-v0.2.0") {
+		t.Errorf("expected bump branch as PR head, got %v", pr)
+	}
+	for _, c := range runner.calls {
+		if len(c) > 3 && c[2] == "get" && c[3] != "github.com/test/mylib@v0.2.0" {
+			t.Errorf("unexpected go get target %v", c)
+		}
+	}
+}
+
+func TestBumpRemoteDependents_UpToDateIsSkipped(t *testing.T) {
+	fakeProxyHasVersion(t)
+	tmp := t.TempDir()
+	index := filepath.Join(tmp, "importers.txt")
+	os.WriteFile(index, []byte("github.com/test/mylib acme/webapp\n"), 0644)
+
+	runner := &scriptedRunner{respond: func(args []string) (string, error) { return "", nil }}
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetConsoleOutput(func(string) {})
+	g.SetRetryConfig(time.Millisecond, 1)
+	g.SetRemoteDependents(devflow.NewFileIndex(index), &gitmod.GitHub{SecretRunner: runner})
+
+	entries, err := g.BumpRemoteDependents("github.com/test/mylib", "v0.2.0", "")
+	if err != nil {
+		t.Fatalf("BumpRemoteDependents: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Status != devflow.CascadeStatusSkipped {
+		t.Fatalf("expected skipped entry, got %+v", entries)
+	}
+	if findCall(runner.calls, "pr", "create") != nil {
+		t.Error("no PR must be opened when go.mod did not change")
+	}
+}
+
+func TestGitHubSearchIndex_ParsesCodeSearch(t *testing.T) {
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		return `[
+  {"path":"go.mod","repository":{"nameWithOwner":"acme/webapp"}},
+  {"path":"go.mod","repository":{"nameWithOwner":"acme/webapp"}},
+  {"path":"cmd/tool/go.mod","repository":{"nameWithOwner":"acme/tools"}},
+  {"path":"docs/go.mod.txt","repository":{"nameWithOwner":"acme/docs"}}
+]`, nil
+	}}
+
+	deps, err := devflow.NewGitHubSearchIndex(runner, "acme").Dependents("github.com/test/mylib")
+	if err != nil {
+		t.Fatalf("Dependents: %v", err)
+	}
+	want := []devflow.RemoteDependent{{Repo: "acme/webapp"}, {Repo: "acme/tools", Dir: "cmd/tool"}}
+	if len(deps) != len(want) || deps[0] != want[0] || deps[1] != want[1] {
+		t.Errorf("expected %+v, got %+v", want, deps)
+	}
+	if c := findCall(runner.calls, "search", "code", "github.com/test/mylib"); c == nil || !strings.Contains(strings.Join(c, " "), "--owner acme") {
+		t.Errorf("expected owner-scoped code search, got %v", runner.calls)
+	}
+}
+
+func TestLoadDependentsIndex_FileSource(t *testing.T) {
+	tmp := t.TempDir()
+	os.MkdirAll(filepath.Join(tmp, ".devflow"), 0755)
+	os.WriteFile(filepath.Join(tmp, ".devflow", "dependents.json"), []byte(`{"source":"file","path":"importers.txt"}`), 0644)
+	os.WriteFile(filepath.Join(tmp, "importers.txt"), []byte("github.com/test/mylib acme/webapp\n"), 0644)
+
+	idx, err := devflow.LoadDependentsIndex(tmp, nil)
+	if err != nil {
+		t.Fatalf("LoadDependentsIndex: %v", err)
+	}
+	deps, err := idx.Dependents("github.com/test/mylib")
+	if err != nil || len(deps) != 1 || deps[0].Repo != "acme/webapp" {
+		t.Errorf("expected acme/webapp from importers file, got %+v (%v)", deps, err)
+	}
+
+	os.WriteFile(filepath.Join(tmp, ".devflow", "dependents.json"), []byte(`{"source":"proxy"}`), 0644)
+	if _, err := devflow.LoadDependentsIndex(tmp, nil); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
+
+func TestBumpRemoteDependents_SkipsSelfAndOpenPRs(t *testing.T) {
+	fakeProxyHasVersion(t)
+	tmp := t.TempDir()
+	index := filepath.Join(tmp, "importers.txt")
+	os.WriteFile(index, []byte(`github.com/test/mylib/v2  test/mylib
+github.com/test/mylib/v2  acme/webapp
+github.com/test/mylib/v2  acme/webapp  cmd/tool
+`), 0644)
+
+	runner := &scriptedRunner{respond: func(args []string) (string, error) {
+		joined := strings.Join(args, " ")
+		switch {
+		case strings.HasPrefix(joined, "pr list") && strings.Contains(joined, "--head deps/v2-v2.1.0 "):
+			return "https://github.com/acme/webapp/pull/3\n", nil
+		case strings.HasSuffix(joined, "status --porcelain"):
+			return " M go.mod", nil
+		case strings.HasPrefix(joined, "pr create"):
+			return "https://github.com/acme/webapp/pull/4\n", nil
+		}
+		return "", nil
+	}}
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetConsoleOutput(func(string) {})
+	g.SetRetryConfig(time.Millisecond, 1)
+	g.SetRemoteDependents(devflow.NewFileIndex(index), &gitmod.GitHub{SecretRunner: runner})
+
+	entries, err := g.BumpRemoteDependents("github.com/test/mylib/v2", "v2.1.0", "")
+	if err != nil {
+		t.Fatalf("BumpRemoteDependents: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("the module's own repository must be skipped, got %+v", entries)
+	}
+	if entries[0].Status != devflow.CascadeStatusSkipped || entries[0].Detail != "PR already open: https://github.com/acme/webapp/pull/3" {
+		t.Errorf("a rerun must reuse the open PR, got %+v", entries[0])
+	}
+	if entries[1].Status != devflow.CascadeStatusPROpened || entries[1].ModulePath != "acme/webapp/cmd/tool" {
+		t.Errorf("expected a PR for the subdirectory, got %+v", entries[1])
+	}
+	if findCall(runner.calls, "repo", "clone", "test/mylib") != nil {
+		t.Error("the module's own repository must not be cloned")
+	}
+	if findCall(runner.calls, "pr", "create", "--repo", "acme/webapp", "--head", "deps/v2-v2.1.0-cmd-tool") == nil {
+		t.Errorf("each go.mod directory needs its own branch, calls: %v", runner.calls)
+	}
+	pushes := 0
+	for _, c := range runner.calls {
+		if len(c) > 2 && c[0] == "-C" && c[2] == "push" {
+			pushes++
+			if !strings.Contains(strings.Join(c, " "), "--force") {
+				t.Errorf("a branch left by a failed run must be replaced, got %v", c)
+			}
+		}
+	}
+	if pushes != 1 {
+		t.Errorf("expected one branch push, calls: %v", runner.calls)
+	}
+}
+
+func TestPush_RemoteDependentsSkipLocalCheckouts(t *testing.T) {
+	fakeProxyHasVersion(t)
+	root := t.TempDir()
+	lib := filepath.Join(root, "mylib")
+	os.MkdirAll(lib, 0755)
+	os.WriteFile(filepath.Join(lib, "go.mod"), []byte("module github.com/test/mylib\n\ngo 1.20\n"), 0644)
+
+	// A local checkout of acme/webapp, left untouched by its active session.
+	webapp := filepath.Join(root, "webapp")
+	os.MkdirAll(filepath.Join(webapp, "docs"), 0755)
+	os.WriteFile(filepath.Join(webapp, "go.mod"), []byte("module github.com/acme/webapp\n\ngo 1.20\n\nrequire github.com/test/mylib v0.1.0\n"), 0644)
+	os.WriteFile(filepath.Join(webapp, "docs", "PLAN.md"), []byte("---\nPLAN: \"test\"\nSTATUS: running\n---\n"), 0644)
+	testGitInit(t, webapp)
+	if out, err := exec.Command("git", "-C", webapp, "remote", "add", "origin", "https://github.com/acme/webapp.git").CombinedOutput(); err != nil {
+		t.Fatalf("git remote add: %v\n%s", err, out)
+	}
+
+	index := filepath.Join(root, "importers.txt")
+	os.WriteFile(index, []byte("github.com/test/mylib acme/webapp\ngithub.com/test/mylib acme/tools\n"), 0644)
+	runner := &scriptedRunner{respond: func(args []string) (string, error) { return "", nil }}
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{pushResult: gitmod.PushResult{Summary: "ok", Tag: "v0.2.0"}})
+	g.SetRootDir(lib)
+	g.SetConsoleOutput(func(string) {})
+	g.SetRetryConfig(time.Millisecond, 1)
+	g.SetRemoteDependents(devflow.NewFileIndex(index), &gitmod.GitHub{SecretRunner: runner})
+
+	if _, err := g.Push("feat: x", "v0.2.0", true, true, false, true, false, true, root); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if findCall(runner.calls, "repo", "clone", "acme/webapp") != nil {
+		t.Error("a dependent checked out locally must not get a remote PR too")
+	}
+	if findCall(runner.calls, "repo", "clone", "acme/tools") == nil {
+		t.Errorf("expected the remote-only dependent to be cloned, calls: %v", runner.calls)
+	}
+}
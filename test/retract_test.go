@@ -0,0 +1,112 @@
+package devflow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func TestAddRetract_SingleLineAndBlock(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+
+	m := devflow.NewGoModHandler()
+	m.SetRootDir(dir)
+	if !m.AddRetract("v1.4.2", "", "Published with a broken build tag.") {
+		t.Fatal("AddRetract single version failed")
+	}
+	if m.AddRetract("v1.4.2", "v1.4.2", "again") {
+		t.Error("duplicate retraction must be rejected")
+	}
+	m.Save()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if !strings.Contains(string(data), "// Published with a broken build tag.\nretract v1.4.2\n") {
+		t.Fatalf("unexpected go.mod:\n%s", data)
+	}
+
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n\nretract (\n\t// Old.\n\tv1.0.0\n)\n"), 0644)
+	m = devflow.NewGoModHandler()
+	m.SetRootDir(dir)
+	if !m.AddRetract("v1.3.0", "v1.3.4", "Data race in Pool.") {
+		t.Fatal("AddRetract range failed")
+	}
+	m.Save()
+
+	entries, err := m.GetRetracts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Low != "v1.0.0" || entries[0].Rationale != "Old." {
+		t.Errorf("entry 0 = %+v", entries[0])
+	}
+	if entries[1].String() != "[v1.3.0, v1.3.4]" || entries[1].Rationale != "Data race in Pool." {
+		t.Errorf("entry 1 = %+v", entries[1])
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "go.mod"))
+	if strings.Count(string(data), "retract") != 1 {
+		t.Errorf("range must be added to the existing block:\n%s", data)
+	}
+}
+
+func TestRetract_Validation(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v1.4.2"})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+
+	cases := []struct{ low, high, rationale string }{
+		{"1.4.2", "", "no v prefix"},
+		{"v1.4.2", "v1.4.0", "inverted range"},
+		{"v1.4.2", "", ""},
+	}
+	for _, c := range cases {
+		if _, err := g.Retract(c.low, c.high, c.rationale, false, ""); err == nil {
+			t.Errorf("Retract(%q, %q, %q) should fail", c.low, c.high, c.rationale)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if strings.Contains(string(data), "retract") {
+		t.Errorf("go.mod must be untouched on validation errors:\n%s", data)
+	}
+}
+
+func TestRetract_ReadsSigningAndProxyConfig(t *testing.T) {
+	t.Setenv("GOPRIVATE", "")
+	os.Unsetenv("GOPRIVATE")
+	newRepo := func() (*devflow.Go, string) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+		g := newGoHandlerWithMockBackup(t, &MockGitClient{latestTag: "v1.4.2"})
+		g.SetRootDir(dir)
+		g.SetConsoleOutput(func(string) {})
+		g.SetSkipChangelog(true)
+		return g, dir
+	}
+
+	g, dir := newRepo()
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.SigningFile), []byte(`{"format": "ssh", "key": "/nonexistent/key", "required": true}`), 0644)
+	if _, err := g.Retract("v1.4.2", "", "broken", false, ""); err == nil || !strings.Contains(err.Error(), "signing is required") {
+		t.Errorf("a retraction must honor signing.json, got %v", err)
+	}
+
+	g, dir = newRepo()
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.ProxyFile), []byte(`{"goprivate": "github.com/test/*"}`), 0644)
+	writeHooks(t, dir, `{"pre-tag": [{"run": "go env GOPRIVATE > seen.txt"}]}`)
+	if _, err := g.Retract("v1.4.2", "", "broken", false, ""); err != nil {
+		t.Fatalf("Retract: %v", err)
+	}
+	if seen, _ := os.ReadFile(filepath.Join(dir, "seen.txt")); strings.TrimSpace(string(seen)) != "github.com/test/*" {
+		t.Errorf("a retraction must honor proxy.json, GOPRIVATE = %q", seen)
+	}
+}
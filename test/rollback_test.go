@@ -0,0 +1,240 @@
+package devflow_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func TestRollback_DeletesUnpublishedTag(t *testing.T) {
+	t.Setenv("GOPROXY", "off") // nothing is published
+	dir := testGitRepoWithOrigin(t, t.TempDir(), "lib", map[string]string{
+		"go.mod": "module github.com/test/lib\n\ngo 1.20\n",
+		"lib.go": "package lib\n",
+	})
+	// The release the mocked push stands for
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n\nconst X = 1\n"), 0644)
+	testGitCommitAll(t, dir, "v0.2.0")
+	testGit(t, "-C", dir, "push", "-q", "origin", "HEAD", "v0.2.0")
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+	if _, err := g.Push("feat: x", "v0.2.0", true, true, true, true, false, true, ""); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	rec, err := devflow.LoadPushRecord(dir)
+	if err != nil || rec == nil {
+		t.Fatalf("push not recorded: %v", err)
+	}
+	if head := testGit(t, "-C", dir, "rev-parse", "HEAD"); rec.Commit != head || rec.Tag != "v0.2.0" || rec.ModulePath != "github.com/test/lib" {
+		t.Errorf("unexpected record %+v (HEAD %s)", rec, head)
+	}
+
+	plan, err := g.PlanRollback()
+	if err != nil {
+		t.Fatalf("PlanRollback: %v", err)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[0].Action != devflow.RollbackDeleteTag || plan.Steps[1].Action != devflow.RollbackRevert || !strings.Contains(plan.String(), "delete tag v0.2.0") {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+
+	if _, err := g.Rollback(plan, ""); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if out := testGit(t, "-C", dir, "ls-remote", "--tags", "origin"); strings.Contains(out, "v0.2.0") {
+		t.Errorf("remote tag not deleted:\n%s", out)
+	}
+	if out := testGit(t, "-C", dir, "tag", "--list"); out != "" {
+		t.Errorf("local tag not deleted: %s", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "lib.go")); string(data) != "package lib\n" {
+		t.Errorf("the release commit must be reverted, lib.go:\n%s", data)
+	}
+	if rec, _ := devflow.LoadPushRecord(dir); rec != nil {
+		t.Error("the record must be removed after the rollback")
+	}
+}
+
+func TestRollback_RevertsDependentBumps(t *testing.T) {
+	t.Setenv("GOPROXY", "off")
+	tmp := t.TempDir()
+	lib := testGitRepoWithOrigin(t, tmp, "lib", map[string]string{"go.mod": "module github.com/test/lib\n\ngo 1.20\n"})
+	app := testGitRepoWithOrigin(t, tmp, "app", map[string]string{"go.mod": "module github.com/test/app\n\ngo 1.20\n\nrequire github.com/test/lib v0.1.0\n"})
+
+	os.WriteFile(filepath.Join(app, "go.mod"), []byte("module github.com/test/app\n\ngo 1.20\n\nrequire github.com/test/lib v0.2.0\n"), 0644)
+	testGitCommitAll(t, app, "")
+	os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib\n"), 0644)
+	testGitCommitAll(t, lib, "")
+
+	rec := devflow.PushRecord{
+		PushedModule: devflow.PushedModule{Dir: lib, ModulePath: "github.com/test/lib", Commit: testGit(t, "-C", lib, "rev-parse", "HEAD")},
+		Dependents:   []devflow.PushedModule{{Dir: app, ModulePath: "github.com/test/app", Commit: testGit(t, "-C", app, "rev-parse", "HEAD")}},
+	}
+	data, _ := json.Marshal(rec)
+	os.MkdirAll(filepath.Join(lib, ".git", "devflow"), 0755)
+	os.WriteFile(filepath.Join(lib, ".git", "devflow", devflow.PushRecordFile), data, 0644)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(lib)
+	g.SetConsoleOutput(func(string) {})
+
+	plan, err := g.PlanRollback()
+	if err != nil {
+		t.Fatalf("PlanRollback: %v", err)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[0].Dir != app || plan.Steps[1].Action != devflow.RollbackRevert {
+		t.Fatalf("expected the dependent reverted before the root, got:\n%s", plan)
+	}
+	if _, err := g.Rollback(plan, ""); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(app, "go.mod")); !strings.Contains(string(data), "github.com/test/lib v0.1.0") {
+		t.Errorf("dependent bump not reverted:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(lib, "lib.go")); !os.IsNotExist(err) {
+		t.Error("root commit not reverted")
+	}
+}
+
+func TestPlanRollback_ReadsTheProxyListOnly(t *testing.T) {
+	var requests []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path == "/github.com/test/lib/@v/list" {
+			w.Write([]byte("v0.1.0\nv0.2.0\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer proxy.Close()
+	t.Setenv("GOPROXY", proxy.URL+",direct")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+
+	dir := testGitRepoWithOrigin(t, t.TempDir(), "lib", map[string]string{"go.mod": "module github.com/test/lib\n\ngo 1.20\n"})
+	head := testGit(t, "-C", dir, "rev-parse", "HEAD")
+	writeRecord := func(tag string) {
+		data, _ := json.Marshal(devflow.PushRecord{PushedModule: devflow.PushedModule{Dir: dir, ModulePath: "github.com/test/lib", Commit: head, Tag: tag}})
+		os.MkdirAll(filepath.Join(dir, ".git", "devflow"), 0755)
+		os.WriteFile(filepath.Join(dir, ".git", "devflow", devflow.PushRecordFile), data, 0644)
+	}
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+
+	for tag, want := range map[string]devflow.RollbackAction{"v0.2.0": devflow.RollbackRetract, "v0.3.0": devflow.RollbackDeleteTag} {
+		writeRecord(tag)
+		plan, err := g.PlanRollback()
+		if err != nil {
+			t.Fatalf("PlanRollback: %v", err)
+		}
+		if len(plan.Steps) == 0 || plan.Steps[0].Action != want {
+			t.Errorf("%s: expected %s, got:\n%s", tag, want, plan)
+		}
+	}
+	for _, path := range requests {
+		if path != "/github.com/test/lib/@v/list" {
+			t.Errorf("the check must not make the proxy fetch a version, requested %s", path)
+		}
+	}
+
+	// A private module is not looked up on the proxy.
+	requests = nil
+	t.Setenv("GOPRIVATE", "github.com/test/*")
+	writeRecord("v0.2.0")
+	if plan, _ := g.PlanRollback(); len(plan.Steps) == 0 || plan.Steps[0].Action != devflow.RollbackDeleteTag || len(requests) != 0 {
+		t.Errorf("private module: requests %v, plan:\n%s", requests, plan)
+	}
+}
+
+func TestRollback_KeepsANewerRecord(t *testing.T) {
+	dir := testGitRepoWithOrigin(t, t.TempDir(), "lib", map[string]string{"go.mod": "module github.com/test/lib\n\ngo 1.20\n"})
+	// The record of the retraction release Rollback itself pushed
+	data, _ := json.Marshal(devflow.PushRecord{PushedModule: devflow.PushedModule{Dir: dir, Commit: "new", Tag: "v0.2.1"}})
+	os.MkdirAll(filepath.Join(dir, ".git", "devflow"), 0755)
+	os.WriteFile(filepath.Join(dir, ".git", "devflow", devflow.PushRecordFile), data, 0644)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	plan := devflow.RollbackPlan{Record: devflow.PushRecord{PushedModule: devflow.PushedModule{Dir: dir, Commit: "old", Tag: "v0.2.0"}}}
+	if _, err := g.Rollback(plan, ""); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if rec, _ := devflow.LoadPushRecord(dir); rec == nil || rec.Tag != "v0.2.1" {
+		t.Errorf("the retraction's record must be kept, got %+v", rec)
+	}
+}
+
+// publishedRelease makes dir's v0.2.0 a published release adding New to the
+// API of v0.1.0, and records its push.
+func publishedRelease(t *testing.T, dir string) string {
+	t.Helper()
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n\nfunc New() {}\n"), 0644)
+	testGitCommitAll(t, dir, "v0.2.0")
+	head := testGit(t, "-C", dir, "rev-parse", "HEAD")
+	data, _ := json.Marshal(devflow.PushRecord{PushedModule: devflow.PushedModule{Dir: dir, ModulePath: "github.com/test/lib", Commit: head, Tag: "v0.2.0"}})
+	os.MkdirAll(filepath.Join(dir, ".git", "devflow"), 0755)
+	os.WriteFile(filepath.Join(dir, ".git", "devflow", devflow.PushRecordFile), data, 0644)
+
+	proxy := t.TempDir()
+	os.MkdirAll(filepath.Join(proxy, "github.com", "test", "lib", "@v"), 0755)
+	os.WriteFile(filepath.Join(proxy, "github.com", "test", "lib", "@v", "list"), []byte("v0.1.0\nv0.2.0\n"), 0644)
+	t.Setenv("GOPROXY", "file://"+proxy)
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+	return head
+}
+
+func TestRollback_RetractionSkipsTheAPIGate(t *testing.T) {
+	dir := testGitRepoWithOrigin(t, t.TempDir(), "lib", map[string]string{"go.mod": "module github.com/test/lib\n\ngo 1.20\n", "lib.go": "package lib\n"})
+	testGit(t, "-C", dir, "tag", "v0.1.0")
+	publishedRelease(t, dir)
+
+	mock := &MockGitClient{latestTag: "v0.2.0"}
+	g := newGoHandlerWithMockBackup(t, mock)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+
+	plan, err := g.PlanRollback()
+	if err != nil || len(plan.Steps) != 1 || plan.Steps[0].Action != devflow.RollbackRetract {
+		t.Fatalf("expected a retraction, got %v:\n%s", err, plan)
+	}
+	if _, err := g.Rollback(plan, "broken"); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if mock.LastPushTag != "v0.2.1" {
+		t.Errorf("the retraction must be the next patch release, pushed %q", mock.LastPushTag)
+	}
+}
+
+func TestRollback_FailedRetractionUndoesTheRevert(t *testing.T) {
+	dir := testGitRepoWithOrigin(t, t.TempDir(), "lib", map[string]string{"go.mod": "module github.com/test/lib\n\ngo 1.20\n", "lib.go": "package lib\n"})
+	head := publishedRelease(t, dir)
+	writeHooks(t, dir, `{"pre-tag": [{"run": "false"}]}`)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+
+	plan, _ := g.PlanRollback()
+	if _, err := g.Rollback(plan, "broken"); err == nil {
+		t.Fatal("expected the failing hook to stop the retraction")
+	}
+	if now := testGit(t, "-C", dir, "rev-parse", "HEAD"); now != head {
+		t.Errorf("the revert must be undone, HEAD %s (was %s)", now, head)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "go.mod")); strings.Contains(string(data), "retract") {
+		t.Errorf("the retraction must be undone:\n%s", data)
+	}
+}
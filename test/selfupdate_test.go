@@ -0,0 +1,159 @@
+package devflow_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+	"github.com/tinywasm/devflow/selfupdate"
+)
+
+// testReleaseServer serves the files of a local release as the latest GitHub
+// release of acme/tool.
+func testReleaseServer(t *testing.T, dir, tag string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/acme/tool/releases/latest" {
+			entries, _ := os.ReadDir(dir)
+			type asset struct {
+				Name string `json:"name"`
+				URL  string `json:"browser_download_url"`
+			}
+			release := struct {
+				Tag    string  `json:"tag_name"`
+				Assets []asset `json:"assets"`
+			}{Tag: tag}
+			for _, e := range entries {
+				if !e.IsDir() {
+					release.Assets = append(release.Assets, asset{e.Name(), srv.URL + "/download/" + e.Name()})
+				}
+			}
+			json.NewEncoder(w).Encode(release)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(dir, strings.TrimPrefix(r.URL.Path, "/download/")))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSelfUpdate(t *testing.T) {
+	release := testLocalRelease(t, "v0.2.0")
+	srv := testReleaseServer(t, release, "v0.2.0")
+
+	exe := filepath.Join(t.TempDir(), "tool")
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	os.WriteFile(exe, []byte("tool v0.1.0"), 0755)
+
+	// The test binary is a default build: Current() must find the asset of a
+	// default target.
+	u := &selfupdate.Updater{Repo: "acme/tool", Version: "v0.1.0", Executable: exe, APIURL: srv.URL}
+	tag, err := u.Update()
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if tag != "v0.2.0" {
+		t.Errorf("tag = %q", tag)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "tool v0.2.0" {
+		t.Errorf("executable = %q, want the released binary", data)
+	}
+
+	u.Version = "v0.2.0"
+	if tag, err := u.Update(); err != nil || tag != "" {
+		t.Errorf("up to date: Update = %q, %v", tag, err)
+	}
+
+	// A tampered asset is refused and the executable kept.
+	os.WriteFile(filepath.Join(release, "tool-"+runtime.GOOS+"-"+runtime.GOARCH+".tar.gz"), []byte("tampered"), 0644)
+	u.Version = "v0.1.0"
+	if _, err := u.Update(); err == nil || !strings.Contains(err.Error(), "checksums.txt") {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "tool v0.2.0" {
+		t.Errorf("executable = %q, must be kept", data)
+	}
+}
+
+func TestSelfUpdate_Signature(t *testing.T) {
+	release := testLocalRelease(t, "v0.2.0")
+	srv := testReleaseServer(t, release, "v0.2.0")
+	key, _ := devflow.GenerateReleaseKey()
+	other, _ := devflow.GenerateReleaseKey()
+
+	exe := filepath.Join(t.TempDir(), "tool")
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	os.WriteFile(exe, []byte("tool v0.1.0"), 0755)
+	u := &selfupdate.Updater{Repo: "acme/tool", Version: "v0.1.0", Executable: exe, APIURL: srv.URL, PublicKey: key.PublicKey()}
+
+	if _, err := u.Update(); err == nil || !strings.Contains(err.Error(), "no checksums.txt.sig") {
+		t.Errorf("expected an unsigned release to be refused, got %v", err)
+	}
+
+	checksums, _ := os.ReadFile(filepath.Join(release, "checksums.txt"))
+	os.WriteFile(filepath.Join(release, "checksums.txt"+devflow.SignatureExt), other.Sign(checksums, "file:checksums.txt"), 0644)
+	if _, err := u.Update(); err == nil || !strings.Contains(err.Error(), "checksums.txt of v0.2.0") {
+		t.Errorf("expected a signature of another key to be refused, got %v", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "tool v0.1.0" {
+		t.Errorf("executable = %q, must be kept", data)
+	}
+
+	os.WriteFile(filepath.Join(release, "checksums.txt"+devflow.SignatureExt), key.Sign(checksums, "file:checksums.txt"), 0644)
+	if tag, err := u.Update(); err != nil || tag != "v0.2.0" {
+		t.Fatalf("Update = %q, %v", tag, err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "tool v0.2.0" {
+		t.Errorf("executable = %q, want the released binary", data)
+	}
+}
+
+func TestSelfUpdate_TargetNames(t *testing.T) {
+	for target, want := range map[selfupdate.Target]string{
+		{GOOS: "linux", GOARCH: "arm", Variant: "7"}:    "armv7",
+		{GOOS: "linux", GOARCH: "amd64", Variant: "v3"}: "amd64v3",
+		{GOOS: "linux", GOARCH: "arm64"}:                "arm64",
+	} {
+		if got := target.ArchName(); got != want {
+			t.Errorf("%+v.ArchName() = %q, want %q", target, got, want)
+		}
+	}
+	if ext := (selfupdate.Target{GOOS: "windows", GOARCH: "amd64"}).BinaryExt(); ext != ".exe" {
+		t.Errorf("windows BinaryExt = %q", ext)
+	}
+}
+
+func TestSelfUpdate_Current(t *testing.T) {
+	if got := selfupdate.Current(); got.GOOS != runtime.GOOS || got.GOARCH != runtime.GOARCH {
+		t.Fatalf("Current() = %+v", got)
+	}
+	for _, v := range []string{"GOAMD64", "GOARM", "GOARM64", "GO386"} {
+		if os.Getenv(v) != "" {
+			t.Skipf("%s is set, the test binary is not a default build", v)
+		}
+	}
+	if arch := selfupdate.Current().ArchName(); arch != runtime.GOARCH {
+		t.Errorf("default build: ArchName() = %q, want %q", arch, runtime.GOARCH)
+	}
+
+	defer func(v string) { selfupdate.BuildTarget = v }(selfupdate.BuildTarget)
+	selfupdate.BuildTarget = runtime.GOOS + "/" + runtime.GOARCH + "/9"
+	if arch := selfupdate.Current().ArchName(); arch != runtime.GOARCH+"v9" {
+		t.Errorf("BuildTarget %s: ArchName() = %q", selfupdate.BuildTarget, arch)
+	}
+	selfupdate.BuildTarget = "plan9/mips/softfloat"
+	if arch := selfupdate.Current().ArchName(); arch != runtime.GOARCH {
+		t.Errorf("BuildTarget of another platform must be ignored, got %q", arch)
+	}
+}
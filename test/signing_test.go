@@ -0,0 +1,115 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+func TestLoadSigningConfig(t *testing.T) {
+	dir := t.TempDir()
+	if cfg, err := devflow.LoadSigningConfig(dir); err != nil || cfg != nil {
+		t.Fatalf("no config file must mean signing off, got %+v, %v", cfg, err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, devflow.DevflowConfigDir), 0755)
+	os.WriteFile(filepath.Join(dir, devflow.DevflowConfigDir, devflow.SigningFile), []byte(`{"format":"ssh","key":"~/.ssh/id.pub","required":true}`), 0644)
+	cfg, err := devflow.LoadSigningConfig(dir)
+	if err != nil || cfg == nil || cfg.Format != "ssh" || cfg.Key != "~/.ssh/id.pub" || !cfg.Required {
+		t.Fatalf("unexpected config %+v, %v", cfg, err)
+	}
+}
+
+func TestPush_RefusesWhenSigningRequiredButUnavailable(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+
+	mockGit := &MockGitClient{}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSigning(&devflow.SigningConfig{Format: "ssh", Key: filepath.Join(dir, "missing.pub"), Required: true})
+
+	_, err := g.Push("fix: x", "v0.1.0", true, true, true, true, false, true, "")
+	if err == nil || !strings.Contains(err.Error(), "signing is required") {
+		t.Fatalf("expected a signing error, got %v", err)
+	}
+	if mockGit.CommitCalls != 0 || mockGit.LastPushTag != "" {
+		t.Errorf("nothing must be committed or pushed (commits=%d, tag=%q)", mockGit.CommitCalls, mockGit.LastPushTag)
+	}
+}
+
+func TestPush_CreatesSignedAnnotatedTag(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	dir := t.TempDir()
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/test/lib\n\ngo 1.20\n"), 0644)
+	testGitInit(t, dir)
+	signed := []string{"-C", dir, "-c", "gpg.format=ssh", "-c", "user.signingKey=" + key + ".pub", "-c", "commit.gpgSign=true"}
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "init"}} {
+		if out, err := exec.Command("git", append(signed, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	exec.Command("git", "-C", dir, "tag", "v0.1.0").Run()
+
+	mockGit := &MockGitClient{}
+	g := newGoHandlerWithMockBackup(t, mockGit)
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+
+	if err := g.VerifySignedTag("v0.1.0"); err == nil {
+		t.Error("a lightweight tag must not pass as signed")
+	}
+
+	g.SetSigning(&devflow.SigningConfig{Format: "ssh", Key: key + ".pub", Required: true})
+	os.WriteFile(filepath.Join(dir, "lib.go"), []byte("package lib\n"), 0644)
+	res, err := g.Push("fix: x", "v0.2.0", true, true, true, true, false, true, "")
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if res.Tag != "v0.2.0" || mockGit.LastPushTag != "" {
+		t.Errorf("the tag must be created by devflow, not the git client's Push (res=%+v, client tag=%q)", res, mockGit.LastPushTag)
+	}
+	if err := g.VerifySignedTag("v0.2.0"); err != nil {
+		t.Errorf("VerifySignedTag: %v", err)
+	}
+	if mockGit.CommitCalls != 0 {
+		t.Error("the signed commit must be made by devflow, not the git client")
+	}
+	if _, ok := os.LookupEnv("GIT_CONFIG_COUNT"); ok {
+		t.Error("the signing git config must not be set in the process environment")
+	}
+
+	// Nothing committed on an already tagged HEAD: no new tag.
+	res, err = g.Push("fix: x", "v0.3.0", true, true, true, true, false, true, "")
+	if err != nil || res.Tag != "" {
+		t.Errorf("expected no tag without changes, got %+v, %v", res, err)
+	}
+	if exec.Command("git", "-C", dir, "rev-parse", "--verify", "refs/tags/v0.3.0").Run() == nil {
+		t.Error("v0.3.0 must not be created")
+	}
+
+	// A signature by a key that is not trusted does not verify.
+	other := filepath.Join(t.TempDir(), "id_other")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", other).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+	if out, err := exec.Command("git", "-C", dir, "-c", "gpg.format=ssh", "-c", "user.signingKey="+other+".pub", "tag", "-s", "-m", "v0.9.0", "v0.9.0").CombinedOutput(); err != nil {
+		t.Fatalf("git tag -s: %v\n%s", err, out)
+	}
+	if err := g.VerifySignedTag("v0.9.0"); err == nil || !strings.Contains(err.Error(), "signature not verified") {
+		t.Errorf("a tag signed by another key must not verify, got %v", err)
+	}
+}
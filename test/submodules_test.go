@@ -0,0 +1,136 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+)
+
+// writeMonorepo creates a repository with a root module and two
+// independently versioned submodules, x (x/v0.3.0) and tools (tools/v1.0.0,
+// requires x), plus an untagged submodule extra. origin is a bare clone.
+func writeMonorepo(t *testing.T) string {
+	t.Helper()
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "repo")
+	files := map[string]string{
+		"go.mod":         "module github.com/test/repo\n\ngo 1.20\n",
+		"repo.go":        "package repo\n",
+		"x/go.mod":       "module github.com/test/repo/x\n\ngo 1.20\n",
+		"x/x.go":         "package x\n\nconst N = 1\n",
+		"tools/go.mod":   "module github.com/test/repo/tools\n\ngo 1.20\n\nrequire github.com/test/repo/x v0.3.0\n\nreplace github.com/test/repo/x => ../x\n",
+		"tools/tools.go": "package tools\n\nimport \"github.com/test/repo/x\"\n\nconst N = x.N\n",
+		"extra/go.mod":   "module github.com/test/repo/extra\n\ngo 1.20\n",
+		"extra/extra.go": "package extra\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "x/v0.3.0")
+	for _, args := range [][]string{
+		{"-C", dir, "tag", "tools/v1.0.0"},
+		{"clone", "-q", "--bare", dir, filepath.Join(tmp, "origin.git")},
+		{"-C", dir, "remote", "add", "origin", filepath.Join(tmp, "origin.git")},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestPlanSubmoduleReleases(t *testing.T) {
+	dir := writeMonorepo(t)
+	os.WriteFile(filepath.Join(dir, "x", "x.go"), []byte("package x\n\nconst N = 2\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "extra", "extra.go"), []byte("package extra\n\nconst E = 1\n"), 0644)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+
+	plan, err := g.PlanSubmoduleReleases("feat: bigger N", nil)
+	if err != nil {
+		t.Fatalf("PlanSubmoduleReleases: %v", err)
+	}
+	var tags []string
+	for _, r := range plan {
+		tags = append(tags, r.Tag())
+	}
+	// x changed (feat → minor), tools only follows x; extra was never tagged.
+	if got := strings.Join(tags, " "); got != "x/v0.4.0 tools/v1.0.1" {
+		t.Errorf("plan = %q, want x/v0.4.0 tools/v1.0.1", got)
+	}
+
+	g.SetTagSubmodules(true)
+	plan, _ = g.PlanSubmoduleReleases("feat: bigger N", nil)
+	found := false
+	for _, r := range plan {
+		found = found || r.Tag() == "extra/v0.1.0"
+	}
+	if !found {
+		t.Errorf("SetTagSubmodules must release the untagged extra module, got %+v", plan)
+	}
+}
+
+func TestPush_TagsChangedSubmodules(t *testing.T) {
+	dir := writeMonorepo(t)
+	os.WriteFile(filepath.Join(dir, "x", "x.go"), []byte("package x\n\nconst N = 2\n"), 0644)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+
+	res, err := g.Push("fix: N", "v0.1.0", true, true, true, true, false, true, "")
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if !strings.Contains(res.Summary, "x/v0.3.1") || !strings.Contains(res.Summary, "tools/v1.0.1") {
+		t.Errorf("summary must list the submodule tags, got %q", res.Summary)
+	}
+
+	out, _ := exec.Command("git", "-C", dir, "ls-remote", "--tags", "origin").CombinedOutput()
+	for _, tag := range []string{"refs/tags/x/v0.3.1", "refs/tags/tools/v1.0.1"} {
+		if !strings.Contains(string(out), tag) {
+			t.Errorf("%s not pushed, origin has:\n%s", tag, out)
+		}
+	}
+	if strings.Contains(string(out), "extra/") {
+		t.Errorf("unchanged submodule must not be tagged:\n%s", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "tools", "go.mod")); !strings.Contains(string(data), "github.com/test/repo/x v0.3.1") {
+		t.Errorf("tools must require the new x version:\n%s", data)
+	}
+}
+
+func TestPush_SignsAndVerifiesSubmoduleTags(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+	dir := writeMonorepo(t)
+	os.WriteFile(filepath.Join(dir, "x", "x.go"), []byte("package x\n\nconst N = 2\n"), 0644)
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	g.SetConsoleOutput(func(string) {})
+	g.SetSkipChangelog(true)
+	g.SetSigning(&devflow.SigningConfig{Format: "ssh", Key: key + ".pub", Required: true})
+
+	if _, err := g.Push("fix: N", "v0.1.0", true, true, true, true, false, true, ""); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	for _, tag := range []string{"x/v0.3.1", "tools/v1.0.1"} {
+		if err := g.VerifySignedTag(tag); err != nil {
+			t.Errorf("%s: %v", tag, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package devflow_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/devflow"
+	gitmod "github.com/tinywasm/git"
+)
+
+func TestCommitBumpLevel(t *testing.T) {
+	cases := []struct {
+		msg  string
+		want devflow.BumpLevel
+	}{
+		{"fix: nil map", devflow.BumpPatch},
+		{"chore(ci): cache", devflow.BumpPatch},
+		{"deps: update x to v1.2.3", devflow.BumpPatch},
+		{"update readme", devflow.BumpPatch},
+		{"feat: add Foo", devflow.BumpMinor},
+		{"feat(api): add Foo", devflow.BumpMinor},
+		{"feat!: drop Bar", devflow.BumpMajor},
+		{"refactor(core)!: rename", devflow.BumpMajor},
+		{"fix: x\n\nBREAKING CHANGE: Bar now returns an error", devflow.BumpMajor},
+	}
+	for _, c := range cases {
+		if got := devflow.CommitBumpLevel(c.msg); got != c.want {
+			t.Errorf("CommitBumpLevel(%q) = %s, want %s", c.msg, got, c.want)
+		}
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	existing := []string{"v1.2.3", "v1.3.0-rc.1", "v1.3.0-rc.2", "v1.3.0-beta.1"}
+	cases := []struct {
+		latest string
+		level  devflow.BumpLevel
+		pre    string
+		want   string
+	}{
+		{"", devflow.BumpPatch, "", "v0.0.1"},
+		{"v1.2.3", devflow.BumpPatch, "", "v1.2.4"},
+		{"v1.2.3", devflow.BumpMinor, "", "v1.3.0"},
+		{"v1.2.3", devflow.BumpMajor, "", "v2.0.0"},
+		{"v1.2.3", devflow.BumpMinor, "rc", "v1.3.0-rc.3"},
+		{"v1.2.3", devflow.BumpMinor, "beta", "v1.3.0-beta.2"},
+		{"v1.2.3", devflow.BumpPatch, "rc", "v1.2.4-rc.1"},
+	}
+	for _, c := range cases {
+		got, err := devflow.NextVersion(c.latest, c.level, c.pre, existing)
+		if err != nil || got != c.want {
+			t.Errorf("NextVersion(%q, %s, %q) = %q, %v; want %q", c.latest, c.level, c.pre, got, err, c.want)
+		}
+	}
+}
+
+// testGitCommitMsg commits everything in dir with the given message.
+func testGitCommitMsg(t *testing.T, dir, msg string) {
+	t.Helper()
+	os.WriteFile(filepath.Join(dir, "change.txt"), []byte(msg), 0644)
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", msg}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func newVersionRepo(t *testing.T, modulePath, tag string) (*devflow.Go, string) {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module "+modulePath+"\n\ngo 1.20\n"), 0644)
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, tag)
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	return g, dir
+}
+
+// testGitRepoWithOrigin creates a committed repository in tmp/name whose
+// origin is a bare clone.
+func testGitRepoWithOrigin(t *testing.T, tmp, name string, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(tmp, name)
+	for file, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755)
+		os.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+	}
+	testGitInit(t, dir)
+	testGitCommitAll(t, dir, "")
+	testGit(t, "clone", "-q", "--bare", dir, filepath.Join(tmp, name+".git"))
+	testGit(t, "-C", dir, "remote", "add", "origin", filepath.Join(tmp, name+".git"))
+	return dir
+}
+
+func testGit(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestPlanNextVersion_HighestCommitSinceLatestTagWins(t *testing.T) {
+	g, dir := newVersionRepo(t, "github.com/test/lib", "v1.2.3")
+	testGitCommitMsg(t, dir, "fix: one")
+	testGitCommitMsg(t, dir, "feat(api): add Foo")
+	testGitCommitMsg(t, dir, "chore: tidy")
+
+	plan, err := g.PlanNextVersion("docs: readme")
+	if err != nil {
+		t.Fatalf("PlanNextVersion: %v", err)
+	}
+	if plan.Next != "v1.3.0" || plan.Level != devflow.BumpMinor || plan.Trigger != "feat(api): add Foo" {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if !strings.Contains(plan.Explain(), "v1.2.3 → v1.3.0") {
+		t.Errorf("explanation must show the transition, got %q", plan.Explain())
+	}
+
+	g.SetPreRelease("rc")
+	if plan, _ := g.PlanNextVersion("docs: readme"); plan.Next != "v1.3.0-rc.1" {
+		t.Errorf("expected v1.3.0-rc.1, got %q", plan.Next)
+	}
+}
+
+func TestPlanNextVersion_BreakingChange(t *testing.T) {
+	g, _ := newVersionRepo(t, "github.com/test/lib", "v0.4.1")
+	plan, err := g.PlanNextVersion("feat!: drop Bar")
+	if err != nil || plan.Next != "v0.5.0" {
+		t.Errorf("breaking change on v0 must bump minor, got %+v, %v", plan, err)
+	}
+
+	g, _ = newVersionRepo(t, "github.com/test/lib", "v1.4.1")
+	if _, err := g.PlanNextVersion("feat!: drop Bar"); err == nil || !strings.Contains(err.Error(), "/v2") {
+		t.Errorf("breaking change on v1 without /v2 path must be refused, got %v", err)
+	}
+
+	g, _ = newVersionRepo(t, "github.com/test/lib/v2", "v1.4.1")
+	if plan, err := g.PlanNextVersion("feat!: drop Bar"); err != nil || plan.Next != "v2.0.0" {
+		t.Errorf("expected v2.0.0 for a /v2 module path, got %+v, %v", plan, err)
+	}
+}
+
+func TestPlanNextVersion_CountsTagsOnlyOnOrigin(t *testing.T) {
+	tmp := t.TempDir()
+	dir := testGitRepoWithOrigin(t, tmp, "lib", map[string]string{"go.mod": "module github.com/test/lib\n\ngo 1.20\n"})
+	testGit(t, "-C", dir, "tag", "v1.0.0")
+	testGit(t, "-C", dir, "push", "-q", "origin", "v1.0.0")
+
+	// Another clone released v1.1.0; this one has not fetched it
+	other := filepath.Join(tmp, "other")
+	testGit(t, "clone", "-q", filepath.Join(tmp, "lib.git"), other)
+	testGit(t, "-C", other, "-c", "user.name=Test", "-c", "user.email=test@test.com", "commit", "-q", "--allow-empty", "-m", "feat: add Foo")
+	testGit(t, "-C", other, "tag", "v1.1.0")
+	testGit(t, "-C", other, "push", "-q", "origin", "HEAD", "v1.1.0")
+	testGit(t, "-C", dir, "pull", "-q", "--no-tags", "origin", "HEAD")
+
+	g := newGoHandlerWithMockBackup(t, &MockGitClient{})
+	g.SetRootDir(dir)
+	plan, err := g.PlanNextVersion("fix: bar")
+	if err != nil || plan.Previous != "v1.1.0" || plan.Next != "v1.1.1" {
+		t.Errorf("expected v1.1.0 → v1.1.1 from the tag on origin, got %+v, %v", plan, err)
+	}
+}
+
+func TestPush_RefusedDependentBumpStopsTheRelease(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "mylib")
+	os.MkdirAll(lib, 0755)
+	os.WriteFile(filepath.Join(lib, "go.mod"), []byte("module github.com/test/mylib\n\ngo 1.20\n"), 0644)
+
+	// A v1 dependent with an unreleased breaking change and no /v2 path
+	app := filepath.Join(root, "app")
+	os.MkdirAll(app, 0755)
+	os.WriteFile(filepath.Join(app, "go.mod"), []byte("module github.com/test/app\n\ngo 1.20\n\nrequire github.com/test/mylib v0.1.0\n"), 0644)
+	testGitInit(t, app)
+	testGitCommitAll(t, app, "v1.2.0")
+	testGitCommitMsg(t, app, "feat!: drop Run")
+	// A release only origin has is neither fetched nor counted: the check
+	// reads the dependent's local tags.
+	for _, args := range [][]string{
+		{"clone", "-q", "--bare", app, filepath.Join(root, "app.git")},
+		{"-C", app, "remote", "add", "origin", filepath.Join(root, "app.git")},
+		{"-C", app, "tag", "v1.3.0"},
+		{"-C", app, "push", "-q", "origin", "v1.3.0"},
+		{"-C", app, "tag", "-d", "v1.3.0"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	mock := &MockGitClient{pushResult: gitmod.PushResult{Summary: "ok", Tag: "v0.2.0"}}
+	g := newGoHandlerWithMockBackup(t, mock)
+	g.SetRootDir(lib)
+	g.SetConsoleOutput(func(string) {})
+
+	_, err := g.Push("feat: x", "v0.2.0", true, true, false, true, false, true, root)
+	if err == nil || !strings.Contains(err.Error(), "app") || !strings.Contains(err.Error(), "/v2") {
+		t.Fatalf("expected the push to be refused because of app, got %v", err)
+	}
+	if mock.LastPushTag != "" {
+		t.Errorf("nothing must be released, pushed %q", mock.LastPushTag)
+	}
+	if out, _ := exec.Command("git", "-C", app, "tag", "--list", "v1.3.0").Output(); len(out) > 0 {
+		t.Error("the check must not fetch the dependent's tags")
+	}
+
+	if _, err := g.Push("feat: x", "v0.2.0", true, true, true, true, false, true, root); err != nil {
+		t.Errorf("--no-cascade must not check dependents, got %v", err)
+	}
+}
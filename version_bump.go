@@ -0,0 +1,265 @@
+package devflow
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tinywasm/command"
+	gitmod "github.com/tinywasm/git"
+)
+
+// BumpLevel is the semver component a release increments.
+type BumpLevel int
+
+const (
+	BumpPatch BumpLevel = iota
+	BumpMinor
+	BumpMajor
+)
+
+func (l BumpLevel) String() string {
+	switch l {
+	case BumpMajor:
+		return "major"
+	case BumpMinor:
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// VersionBump explains how the next version was chosen from the commits since
+// the latest release.
+type VersionBump struct {
+	Previous string    // latest release tag ("" if none)
+	Next     string    // version to publish
+	Level    BumpLevel // level applied to Previous
+	Trigger  string    // header of the commit that decided Level
+}
+
+// Explain returns a one-line, human-readable reason for the bump,
+// e.g. "📈 minor v1.2.3 → v1.3.0 (feat(api): add X)".
+func (b VersionBump) Explain() string {
+	prev := b.Previous
+	if prev == "" {
+		prev = "none"
+	}
+	return fmt.Sprintf("📈 %s %s → %s (%s)", b.Level, prev, b.Next, b.Trigger)
+}
+
+// conventionalHeaderRe matches "type(scope)!: subject".
+var conventionalHeaderRe = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?:`)
+
+// CommitBumpLevel returns the bump a Conventional Commits message asks for:
+// "!" after the type or a BREAKING CHANGE footer → major, feat → minor,
+// anything else (fix, chore, deps, ...) → patch.
+func CommitBumpLevel(message string) BumpLevel {
+	header, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	m := conventionalHeaderRe.FindStringSubmatch(strings.TrimSpace(header))
+	if m != nil && m[3] == "!" {
+		return BumpMajor
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			return BumpMajor
+		}
+	}
+	if m != nil && strings.EqualFold(m[1], "feat") {
+		return BumpMinor
+	}
+	return BumpPatch
+}
+
+// NextVersion applies level to the release tag latest ("" = no release yet).
+// preLabel ("rc", "beta", ...) produces vX.Y.Z-<label>.N, with N one past the
+// highest matching pre-release among existing tags.
+func NextVersion(latest string, level BumpLevel, preLabel string, existing []string) (string, error) {
+	major, minor, patch := 0, 0, 0
+	if latest != "" {
+		if !semverTagRe.MatchString(latest) {
+			return "", fmt.Errorf("latest tag %q is not a release version", latest)
+		}
+		parts := strings.Split(strings.TrimPrefix(latest, "v"), ".")
+		major, _ = strconv.Atoi(parts[0])
+		minor, _ = strconv.Atoi(parts[1])
+		patch, _ = strconv.Atoi(parts[2])
+	}
+
+	switch level {
+	case BumpMajor:
+		major, minor, patch = major+1, 0, 0
+	case BumpMinor:
+		minor, patch = minor+1, 0
+	default:
+		patch++
+	}
+	next := fmt.Sprintf("v%d.%d.%d", major, minor, patch)
+	if preLabel == "" {
+		return next, nil
+	}
+
+	prefix := next + "-" + preLabel + "."
+	n := 0
+	for _, t := range existing {
+		if rest, ok := strings.CutPrefix(t, prefix); ok {
+			if v, err := strconv.Atoi(rest); err == nil && v > n {
+				n = v
+			}
+		}
+	}
+	return fmt.Sprintf("%s%d", prefix, n+1), nil
+}
+
+// SetPreRelease makes Push publish pre-releases (e.g. "rc" → v1.3.0-rc.1)
+// when no tag is given. Empty restores final releases.
+func (g *Go) SetPreRelease(label string) {
+	g.preRelease = label
+}
+
+// PlanNextVersion picks the next version from message plus every commit since
+// the latest release tag. Tags published on origin count even when this clone
+// has not fetched them. A zero VersionBump (Next == "") means the history
+// could not be read and the caller should fall back to GenerateNextTag.
+//
+// Breaking changes on v0 bump the minor version (v0 makes no compatibility
+// promise). On v1+ they need a new major module path: without the matching
+// /vN suffix the bump is refused rather than silently published as minor.
+func (g *Go) PlanNextVersion(message string) (VersionBump, error) {
+	latest, tags, err := g.latestRelease()
+	if err != nil {
+		g.log("Version planning skipped, cannot list the tags:", err)
+		return VersionBump{}, nil
+	}
+	return g.planVersion(message, latest, tags)
+}
+
+// latestRelease returns the latest release tag ("" when none) and every v*
+// tag of the repository and of origin. A release only origin has is fetched:
+// the commits since it are read from the tag.
+func (g *Go) latestRelease() (string, []string, error) {
+	tags, remoteOnly, err := g.releaseTags()
+	if err != nil {
+		return "", nil, err
+	}
+	latest := latestReleaseTag(tags)
+	if remoteOnly[latest] {
+		command.RunInDir(g.rootDir, "git", "fetch", "-q", "--no-tags", "origin", "tag", latest)
+	}
+	return latest, tags, nil
+}
+
+// latestReleaseTag returns the highest vX.Y.Z tag of tags ("" when none).
+func latestReleaseTag(tags []string) string {
+	latest := ""
+	for _, t := range tags {
+		if semverTagRe.MatchString(t) && strings.HasPrefix(t, "v") && (latest == "" || gitmod.CompareVersions(t, latest) > 0) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// planVersion is PlanNextVersion once the tags are known: latest is the
+// release the commits are counted from, tags the ones the next version must
+// not collide with.
+func (g *Go) planVersion(message, latest string, tags []string) (VersionBump, error) {
+	messages := []string{message}
+	if history, err := g.commitMessagesBetween(latest, "HEAD"); err == nil {
+		messages = append(messages, history...)
+	}
+
+	// The first message of the highest level wins: the current one, then the
+	// newest commits.
+	bump := VersionBump{Previous: latest}
+	for i, m := range messages {
+		if level := CommitBumpLevel(m); i == 0 || level > bump.Level {
+			header, _, _ := strings.Cut(m, "\n")
+			bump.Level = level
+			bump.Trigger = strings.TrimSpace(header)
+		}
+	}
+
+	if bump.Level == BumpMajor {
+		if latest == "" || strings.HasPrefix(latest, "v0.") {
+			bump.Level = BumpMinor
+			bump.Trigger = "breaking change on v0: " + bump.Trigger
+		} else {
+			next, _ := NextVersion(latest, BumpMajor, "", nil)
+			suffix := "/" + strings.SplitN(next, ".", 2)[0]
+			modulePath, _ := g.GetModulePath()
+			if !strings.HasSuffix(modulePath, suffix) {
+				return VersionBump{}, fmt.Errorf("breaking change (%s) needs %s, but module path %s has no %s suffix: migrate the module path or pass the tag explicitly", bump.Trigger, next, modulePath, suffix)
+			}
+		}
+	}
+
+	var err error
+	bump.Next, err = NextVersion(latest, bump.Level, g.preRelease, tags)
+	if err != nil {
+		return VersionBump{}, err
+	}
+	return bump, nil
+}
+
+// releaseTags lists the v* tags of the local repository and of origin. The
+// ones missing locally are also returned in remoteOnly. An unreachable origin
+// leaves the local tags only.
+func (g *Go) releaseTags() (tags []string, remoteOnly map[string]bool, err error) {
+	out, err := command.RunInDir(g.rootDir, "git", "tag", "--list", "v*")
+	if err != nil {
+		return nil, nil, err
+	}
+	tags = strings.Fields(out)
+
+	local := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		local[t] = true
+	}
+	remoteOnly = map[string]bool{}
+	out, err = command.RunInDir(g.rootDir, "git", "ls-remote", "--tags", "--refs", "origin", "refs/tags/v*")
+	if err != nil {
+		return tags, remoteOnly, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if t := strings.TrimPrefix(fields[1], "refs/tags/"); !local[t] && !remoteOnly[t] {
+			tags = append(tags, t)
+			remoteOnly[t] = true
+		}
+	}
+	return tags, remoteOnly, nil
+}
+
+// checkDependentVersions plans the release of every local dependent of
+// modulePath the cascade would publish. A dependent whose own commits carry a
+// breaking change its module path cannot take would otherwise be refused in
+// the middle of the cascade, after this module was released. Only the local
+// tags of the dependents are read: the check never touches their remotes.
+func (g *Go) checkDependentVersions(modulePath, searchPath string) error {
+	dependents, err := g.FindDependentModules(modulePath, searchPath)
+	if err != nil {
+		return nil // the cascade reports it
+	}
+	var refused []string
+	for _, dir := range dependents {
+		out, err := command.RunInDir(dir, "git", "tag", "--list", "v*")
+		if err != nil {
+			g.log("Version check skipped for", dependentDisplayName(dir)+":", err)
+			continue
+		}
+		tags := strings.Fields(out)
+		dep := &Go{rootDir: dir, log: g.log}
+		if _, err := dep.planVersion("deps: update "+modulePath, latestReleaseTag(tags), tags); err != nil {
+			refused = append(refused, fmt.Sprintf("%s: %v", dependentDisplayName(dir), err))
+		}
+	}
+	if len(refused) > 0 {
+		return fmt.Errorf("dependents cannot be released, fix them or use --no-cascade:\n  %s", strings.Join(refused, "\n  "))
+	}
+	return nil
+}